@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// parseNPMAlias splits an npm alias specifier such as "npm:chalk@5.6.1" or
+// "npm:@ctrl/tinycolor@^4.1.0" into the real package name and the version or
+// range requested
+func parseNPMAlias(spec string) (string, string, bool) {
+	target, ok := strings.CutPrefix(spec, "npm:")
+	if !ok || target == "" {
+		return "", "", false
+	}
+	name := yarnSpecName(target)
+	if !isValidPackageName(name) {
+		return "", "", false
+	}
+	return name, strings.TrimPrefix(target[len(name):], "@"), true
+}
+
+// registryTarballPackage returns the package a registry tarball URL such as
+// "https://registry.npmjs.org/@ctrl/tinycolor/-/tinycolor-4.1.1.tgz" belongs
+// to, whatever the install name
+func registryTarballPackage(tarballURL string) (string, bool) {
+	parsed, err := url.Parse(tarballURL)
+	if err != nil {
+		return "", false
+	}
+	path, _, ok := strings.Cut(strings.TrimPrefix(parsed.Path, "/"), "/-/")
+	if !ok {
+		return "", false
+	}
+	// Registries nested under a path (Artifactory, Nexus) come first
+	segments := strings.Split(path, "/")
+	name := segments[len(segments)-1]
+	if len(segments) > 1 && strings.HasPrefix(segments[len(segments)-2], "@") {
+		name = segments[len(segments)-2] + "/" + name
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return name, isValidPackageName(name)
+}
+
+// registryTarballVersion returns the version in the file name of a registry
+// tarball URL of package name: "tinycolor-4.1.1.tgz" for "@ctrl/tinycolor"
+func registryTarballVersion(tarballURL, name string) (string, bool) {
+	parsed, err := url.Parse(tarballURL)
+	if err != nil {
+		return "", false
+	}
+	file := parsed.Path[strings.LastIndex(parsed.Path, "/")+1:]
+	base := name[strings.LastIndex(name, "/")+1:]
+	version, ok := strings.CutPrefix(strings.TrimSuffix(file, ".tgz"), base+"-")
+	return version, ok && version != ""
+}
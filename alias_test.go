@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseNPMAlias(t *testing.T) {
+	tests := []struct {
+		spec          string
+		name, version string
+		ok            bool
+	}{
+		{"npm:chalk@5.6.1", "chalk", "5.6.1", true},
+		{"npm:chalk@^5.0.0", "chalk", "^5.0.0", true},
+		{"npm:@ctrl/tinycolor@^4.1.0", "@ctrl/tinycolor", "^4.1.0", true},
+		{"npm:chalk", "chalk", "", true},
+		{"npm:", "", "", false},
+		{"npm:Not Valid@1.0.0", "", "", false},
+		{"chalk@5.6.1", "", "", false},
+		{"^5.6.1", "", "", false},
+	}
+	for _, tt := range tests {
+		name, version, ok := parseNPMAlias(tt.spec)
+		if name != tt.name || version != tt.version || ok != tt.ok {
+			t.Errorf("parseNPMAlias(%q) = %q, %q, %v, want %q, %q, %v", tt.spec, name, version, ok, tt.name, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestRegistryTarballPackage(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+		ok   bool
+	}{
+		{"https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz", "chalk", true},
+		{"https://registry.npmjs.org/@ctrl/tinycolor/-/tinycolor-4.1.1.tgz", "@ctrl/tinycolor", true},
+		{"https://registry.yarnpkg.com/@ctrl%2ftinycolor/-/tinycolor-4.1.1.tgz#abc", "@ctrl/tinycolor", true},
+		{"https://artifactory.example.com/api/npm/npm-remote/@ctrl/tinycolor/-/tinycolor-4.1.1.tgz", "@ctrl/tinycolor", true},
+		{"https://nexus.example.com/repository/npm/chalk/-/chalk-5.6.1.tgz", "chalk", true},
+		{"https://codeload.github.com/chalk/chalk/tar.gz/abc123", "", false},
+		{"file:../chalk", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := registryTarballPackage(tt.url)
+		if ok != tt.ok || ok && got != tt.want {
+			t.Errorf("registryTarballPackage(%q) = %q, %v, want %q, %v", tt.url, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestLockfileAliases installs chalk@5.6.1 as "colors-safe" in every
+// lockfile format; each must see through the alias and name both
+func TestLockfileAliases(t *testing.T) {
+	setTestIOCs(t, CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}})
+
+	tests := []struct {
+		name string
+		file string
+		scan func(*os.File, string, func(Finding), bool)
+		lock string
+	}{
+		{"package-lock v1", "package-lock.json", scanPackageLockJson, `{"lockfileVersion": 1, "dependencies": {
+  "colors-safe": {"version": "npm:chalk@5.6.1", "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz"}
+}}`},
+		{"package-lock v1, tarball only", "package-lock.json", scanPackageLockJson, `{"lockfileVersion": 1, "dependencies": {
+  "colors-safe": {"version": "5.6.1", "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz"}
+}}`},
+		{"package-lock v3", "package-lock.json", scanPackageLockJson, `{"lockfileVersion": 3, "packages": {
+  "": {"dependencies": {"colors-safe": "npm:chalk@^5.0.0"}},
+  "node_modules/colors-safe": {"name": "chalk", "version": "5.6.1"}
+}}`},
+		{"package-lock v3, tarball only", "package-lock.json", scanPackageLockJson, `{"lockfileVersion": 3, "packages": {
+  "node_modules/colors-safe": {"version": "5.6.1", "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz"}
+}}`},
+		{"yarn classic", "yarn.lock", scanYarnLock, `# yarn lockfile v1
+
+
+"colors-safe@npm:chalk@^5.0.0":
+  version "5.6.1"
+  resolved "https://registry.yarnpkg.com/chalk/-/chalk-5.6.1.tgz"
+`},
+		{"yarn berry", "yarn.lock", scanYarnLock, `__metadata:
+  version: 8
+
+"colors-safe@npm:chalk@^5.0.0":
+  version: 5.6.1
+  resolution: "chalk@npm:5.6.1"
+`},
+		{"pnpm v9", "pnpm-lock.yaml", scanPnpmLock, `lockfileVersion: '9.0'
+
+importers:
+
+  .:
+    dependencies:
+      colors-safe:
+        specifier: npm:chalk@^5.0.0
+        version: chalk@5.6.1
+
+packages:
+
+  chalk@5.6.1:
+    resolution: {integrity: sha512-AAAA}
+
+snapshots:
+
+  chalk@5.6.1: {}
+`},
+		{"bun.lock", "bun.lock", scanBunLock, `{"lockfileVersion": 1, "packages": {
+  "colors-safe": ["chalk@5.6.1", "", {}, "sha512-AAAA"],
+}}`},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.file)
+		if err := os.WriteFile(path, []byte(tt.lock), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var findings []Finding
+		tt.scan(file, path, func(f Finding) { findings = append(findings, f) }, false)
+		file.Close()
+		if len(findings) != 1 || findings[0].Package != "chalk" || findings[0].Version != "5.6.1" || findings[0].Alias != "colors-safe" {
+			t.Errorf("%s: got %+v, want chalk@5.6.1 as colors-safe", tt.name, findings)
+		}
+	}
+}
+
+func TestRegistryTarballVersion(t *testing.T) {
+	tests := []struct {
+		url, name, want string
+	}{
+		{"https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz", "chalk", "5.6.1"},
+		{"https://registry.npmjs.org/@ctrl/tinycolor/-/tinycolor-4.1.1.tgz", "@ctrl/tinycolor", "4.1.1"},
+		{"https://registry.npmjs.org/chalk/-/chalk-5.6.1-beta.1.tgz?cache=1", "chalk", "5.6.1-beta.1"},
+		{"https://registry.npmjs.org/chalk/-/chalk.tgz", "chalk", ""},
+		{"https://registry.npmjs.org/chalk/-/chalk-.tgz", "chalk", ""},
+		{"https://registry.npmjs.org/debug/-/debug-4.4.2.tgz", "chalk", ""},
+	}
+	for _, tt := range tests {
+		got, ok := registryTarballVersion(tt.url, tt.name)
+		if !ok {
+			got = ""
+		}
+		if got != tt.want {
+			t.Errorf("registryTarballVersion(%q, %q) = %q, %v, want %q", tt.url, tt.name, got, ok, tt.want)
+		}
+	}
+}
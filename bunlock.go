@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// bunLock is the text bun.lock format, JSON with trailing commas. Each
+// package is an array of ["name@version", registry, metadata, integrity],
+// keyed by its install path such as "chalk" or "some-dep/chalk". Workspaces
+// are keyed by folder, "" being the project itself.
+type bunLock struct {
+	LockfileVersion int                          `json:"lockfileVersion"`
+	Workspaces      map[string]packageManifest   `json:"workspaces"`
+	Packages        map[string][]json.RawMessage `json:"packages"`
+}
+
+// depGraph links package keys through the dependencies in their metadata,
+// with each workspace as a root keyed "workspace:<folder>". A dependency
+// resolves to the nearest key nested under the dependent, as in
+// node_modules.
+func (lock *bunLock) depGraph(dir string) *depGraph {
+	graph := newDepGraph()
+	links := make(map[string]string) // workspace package key -> root
+	for key, entry := range lock.Packages {
+		var locator string
+		if len(entry) > 0 {
+			json.Unmarshal(entry[0], &locator)
+		}
+		name := yarnSpecName(locator)
+		if folder, ok := strings.CutPrefix(strings.TrimPrefix(locator, name+"@"), "workspace:"); ok {
+			links[key] = "workspace:" + folder
+			continue
+		}
+		graph.addNode(key, name)
+	}
+	resolve := func(from, dep string) string {
+		for prefix := from; ; prefix = bunParentKey(prefix) {
+			candidate := dep
+			if prefix != "" {
+				candidate = prefix + "/" + dep
+			}
+			if target, ok := links[candidate]; ok {
+				return target
+			}
+			if _, ok := lock.Packages[candidate]; ok {
+				return candidate
+			}
+			if prefix == "" {
+				return ""
+			}
+		}
+	}
+
+	for folder, workspace := range lock.Workspaces {
+		id := "workspace:" + folder
+		label := workspace.Name
+		if label == "" {
+			label = projectLabel(filepath.Join(dir, folder))
+		}
+		graph.addRoot(id, label)
+		// Packages only one workspace uses are nested under its name
+		for _, dep := range sortedKeys(workspace.allDependencies()) {
+			if to := resolve(workspace.Name, dep); to != "" {
+				graph.addEdge(id, to)
+			}
+		}
+	}
+	for key, entry := range lock.Packages {
+		var metadata packageManifest
+		if len(entry) < 3 || json.Unmarshal(entry[2], &metadata) != nil {
+			continue
+		}
+		for _, dep := range sortedKeys(metadata.allDependencies()) {
+			if to := resolve(key, dep); to != "" {
+				graph.addEdge(key, to)
+			}
+		}
+	}
+	return graph
+}
+
+// bunParentKey returns the key a nested bun.lock key is installed under,
+// "a" for "a/chalk" and "" for "chalk"
+func bunParentKey(key string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(key, bunInstallName(key)), "/")
+}
+
+func scanBunLock(file *os.File, filePath string, addFinding func(Finding), verbose bool) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return
+	}
+	var lock bunLock
+	if err := json.Unmarshal(stripJSONTrailingCommas(data), &lock); err != nil {
+		if verbose {
+			fmt.Printf("  ⚠️  Could not parse %s (%v), scanning it as text\n", filePath, err)
+		}
+		scanFile(filePath, addFinding, verbose)
+		return
+	}
+
+	keys := make([]string, 0, len(lock.Packages))
+	for key := range lock.Packages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	graph := lock.depGraph(filepath.Dir(filePath))
+
+	for _, key := range keys {
+		entry := lock.Packages[key]
+		if len(entry) == 0 {
+			continue
+		}
+		var locator, integrity string
+		json.Unmarshal(entry[0], &locator)
+		if len(entry) > 3 {
+			json.Unmarshal(entry[3], &integrity)
+		}
+
+		// Workspace, link, file and git entries use "name@protocol:..."
+		name := yarnSpecName(locator)
+		version := strings.TrimPrefix(locator, name+"@")
+		pkg := activeMatcher.lookup(name)
+		reportLockedIntegrity(pkg, version, integrity, filePath, key, addFinding, verbose)
+		if pkg == nil || strings.Contains(version, ":") || !pkg.matchesVersion(version) {
+			continue
+		}
+		alias := ""
+		if installName := bunInstallName(key); installName != name {
+			alias = installName
+		}
+		addFinding(Finding{
+			Package:    pkg.Name,
+			Version:    version,
+			Alias:      alias,
+			File:       filePath,
+			Location:   key,
+			Type:       "resolved",
+			IOC:        pkg,
+			Paths:      graph.paths(key, pkg.Name+"@"+version),
+			Dependents: graph.dependents(key),
+		})
+		if verbose {
+			fmt.Printf("  Found resolved %s@%s at %s in %s\n", pkg.Name, version, key, filePath)
+		}
+	}
+}
+
+// bunInstallName returns the folder name a bun.lock key installs to, the
+// last path segment or the last two for scoped packages
+func bunInstallName(key string) string {
+	segments := strings.Split(key, "/")
+	name := segments[len(segments)-1]
+	if len(segments) > 1 && strings.HasPrefix(segments[len(segments)-2], "@") {
+		name = segments[len(segments)-2] + "/" + name
+	}
+	return name
+}
+
+// stripJSONTrailingCommas removes commas directly before a closing bracket
+// so JSON5-style files can be decoded with encoding/json
+func stripJSONTrailingCommas(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+		}
+		if c == ',' {
+			j := i + 1
+			for j < len(data) && (data[j] == ' ' || data[j] == '\t' || data[j] == '\n' || data[j] == '\r') {
+				j++
+			}
+			if j < len(data) && (data[j] == '}' || data[j] == ']') {
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
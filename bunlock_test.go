@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStripJSONTrailingCommas(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`{"a": 1,}`, `{"a": 1}`},
+		{"[1, 2,\n\t]", "[1, 2\n\t]"},
+		{`{"a": [1,], "b": {"c": 2,},}`, `{"a": [1], "b": {"c": 2}}`},
+		// Commas inside strings are data
+		{`{"a": ",}", "b": "\",]"}`, `{"a": ",}", "b": "\",]"}`},
+		{`[1, 2]`, `[1, 2]`},
+	}
+	for _, tt := range tests {
+		if got := string(stripJSONTrailingCommas([]byte(tt.in))); got != tt.want {
+			t.Errorf("stripJSONTrailingCommas(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+const bunLockText = `{
+  "lockfileVersion": 1,
+  "workspaces": {
+    "": {
+      "name": "app",
+      "dependencies": {
+        "chalk": "^5.0.0",
+        "foo": "^1.0.0",
+      },
+    },
+    "packages/web": {
+      "name": "@acme/web",
+    },
+  },
+  "packages": {
+    "@acme/web": ["@acme/web@workspace:packages/web"],
+    "chalk": ["chalk@5.6.1", "", {}, "sha512-AAAA"],
+    "debug": ["debug@3.2.7", "", {}, "sha512-BBBB"],
+    "foo": ["foo@1.0.0", "", { "dependencies": { "debug": "^4.0.0" } }, "sha512-CCCC"],
+    "foo/debug": ["debug@4.4.2", "", {}, "sha512-DDDD"],
+    "local": ["local@file:../local", {}],
+  }
+}
+`
+
+func TestScanBunLock(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		// Ranges are checked against each locked version, not the name
+		CompromisedPackage{Name: "debug", Versions: []string{">=4.4.2 <4.5.0"}},
+		CompromisedPackage{Name: "local", Versions: []string{"*"}},
+	)
+	path := filepath.Join(t.TempDir(), "bun.lock")
+	if err := os.WriteFile(path, []byte(bunLockText), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var got []string
+	scanBunLock(file, path, func(f Finding) {
+		got = append(got, fmt.Sprintf("%s %s@%s (%s)", f.Type, f.Package, f.Version, f.Location))
+	}, false)
+
+	// Entries are reported at their install path; workspace and file
+	// entries are not registry packages, whatever their name
+	want := []string{
+		"resolved chalk@5.6.1 (chalk)",
+		"resolved debug@4.4.2 (foo/debug)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
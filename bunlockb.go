@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// bunLockbMagic starts every binary bun.lockb
+const bunLockbMagic = "#!/usr/bin/env bun\nbun-lockfile-format-v0\n"
+
+// Resolution tags of bun.lockb packages
+const (
+	bunResolutionRoot      = 1
+	bunResolutionNPM       = 2
+	bunResolutionWorkspace = 72
+)
+
+// bunLockbFields are the sizes of the Package fields bun.lockb stores, one
+// column after another, in the order they are written: name_hash,
+// resolution, dependencies, resolutions, meta, bin, name, scripts
+var bunLockbFields = [...]int{8, 64, 8, 8, 88, 20, 8, 49}
+
+// Offsets within a bun.lockb resolution (a tag, then the npm URL and
+// version) and within a package's meta (the integrity tag and digest)
+const (
+	bunResolutionValue   = 8
+	bunResolutionVersion = 16
+	bunMetaIntegrity     = 20
+)
+
+// bunIntegrityAlgorithms are the integrity tags of bun.lockb, by number
+var bunIntegrityAlgorithms = map[byte]string{1: "sha1", 2: "sha256", 3: "sha384", 4: "sha512"}
+
+// bunLockbPackage is a package of a binary bun.lockb. Version is only set
+// for registry packages; Folder only for workspace packages. Dependencies
+// are the IDs, indexes in the package table, its dependencies resolved to.
+type bunLockbPackage struct {
+	Name         string
+	Version      string
+	Resolution   byte
+	Folder       string
+	Integrity    string
+	Dependencies []uint32
+}
+
+// parseBunLockb reads the package table of a binary bun.lockb: the magic
+// header, format version, meta hash and size, then a table with one column
+// per Package field, then length-prefixed buffers: the package IDs each
+// dependency resolved to, and the string bytes that long names and version
+// tags point into.
+func parseBunLockb(data []byte) ([]bunLockbPackage, error) {
+	if !bytes.HasPrefix(data, []byte(bunLockbMagic)) {
+		return nil, errors.New("not a bun.lockb")
+	}
+	r := bunLockbReader{data: data, pos: len(bunLockbMagic)}
+	format := r.u32()
+	r.pos += 32 // meta hash
+	r.u64()     // file size
+	count, _, fields, begin, end := r.u64(), r.u64(), r.u64(), r.u64(), r.u64()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if fields != uint64(len(bunLockbFields)) {
+		return nil, fmt.Errorf("format %d has %d package fields, want %d", format, fields, len(bunLockbFields))
+	}
+	rowSize := 0
+	for _, size := range bunLockbFields {
+		rowSize += size
+	}
+	if begin > end || end > uint64(len(data)) || count > (end-begin)/uint64(rowSize) || end-begin != count*uint64(rowSize) {
+		return nil, fmt.Errorf("format %d package table does not have %d-byte rows", format, rowSize)
+	}
+
+	// Buffers follow the table, each as [start, end) offsets, a type
+	// description and the data: trees, hoisted_dependencies, resolutions,
+	// dependencies, extern_strings and string_bytes
+	buffers := make([][]byte, 6)
+	r.pos = int(end)
+	for i := range buffers {
+		start, stop := r.u64(), r.u64()
+		if r.err != nil || start > stop || stop > uint64(len(data)) || start < uint64(r.pos) {
+			return nil, errors.New("truncated buffers")
+		}
+		buffers[i], r.pos = data[start:stop], int(stop)
+	}
+	ids, strs := buffers[2], buffers[5]
+
+	column := func(field int) int {
+		off := int(begin)
+		for _, size := range bunLockbFields[:field] {
+			off += int(count) * size
+		}
+		return off
+	}
+	resolutions, resolved, metas, names := column(1), column(3), column(4), column(6)
+	packages := make([]bunLockbPackage, count)
+	for i := range packages {
+		pkg := &packages[i]
+		pkg.Name = bunLockbString(data[names+i*8:names+i*8+8], strs)
+
+		res := data[resolutions+i*bunLockbFields[1] : resolutions+(i+1)*bunLockbFields[1]]
+		pkg.Resolution = res[0]
+		switch res[0] {
+		case bunResolutionNPM:
+			pkg.Version = bunLockbVersion(res[bunResolutionVersion:], strs)
+		case bunResolutionWorkspace:
+			pkg.Folder = bunLockbString(res[bunResolutionValue:bunResolutionValue+8], strs)
+		}
+
+		// The package's slice of the resolutions buffer
+		off := uint64(binary.LittleEndian.Uint32(data[resolved+i*8:]))
+		n := uint64(binary.LittleEndian.Uint32(data[resolved+i*8+4:]))
+		if (off+n)*4 > uint64(len(ids)) {
+			return nil, fmt.Errorf("package %d resolutions out of range", i)
+		}
+		for j := off; j < off+n; j++ {
+			// Unresolved dependencies, such as optional ones for other
+			// platforms, have an invalid ID
+			if id := binary.LittleEndian.Uint32(ids[j*4:]); uint64(id) < count {
+				pkg.Dependencies = append(pkg.Dependencies, id)
+			}
+		}
+
+		meta := data[metas+i*bunLockbFields[4] : metas+(i+1)*bunLockbFields[4]]
+		if algo, ok := bunIntegrityAlgorithms[meta[bunMetaIntegrity]]; ok {
+			digest := meta[bunMetaIntegrity+1 : bunMetaIntegrity+1+integrityDigestSizes[algo]]
+			pkg.Integrity = algo + "-" + base64.StdEncoding.EncodeToString(digest)
+		}
+	}
+	return packages, nil
+}
+
+// bunLockbString decodes bun's 8-byte strings: up to 8 bytes inline, or an
+// offset and length into the string bytes, flagged by the length's top bit
+func bunLockbString(b, strs []byte) string {
+	if b[7]&0x80 == 0 {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			return string(b[:i])
+		}
+		return string(b)
+	}
+	off := binary.LittleEndian.Uint32(b[0:4])
+	n := binary.LittleEndian.Uint32(b[4:8]) &^ (1 << 31)
+	if uint64(off)+uint64(n) > uint64(len(strs)) {
+		return ""
+	}
+	return string(strs[off : off+n])
+}
+
+// bunLockbVersion decodes a semver version: major, minor and patch as
+// uint32s, padding, then the prerelease and build tags as 16-byte external
+// strings
+func bunLockbVersion(b, strs []byte) string {
+	version := fmt.Sprintf("%d.%d.%d", binary.LittleEndian.Uint32(b[0:4]), binary.LittleEndian.Uint32(b[4:8]), binary.LittleEndian.Uint32(b[8:12]))
+	if pre := bunLockbString(b[16:24], strs); pre != "" {
+		version += "-" + pre
+	}
+	if build := bunLockbString(b[32:40], strs); build != "" {
+		version += "+" + build
+	}
+	return version
+}
+
+// bunLockbReader reads little-endian integers, remembering the first read
+// past the end of data
+type bunLockbReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bunLockbReader) u32() uint32 {
+	if r.err != nil || r.pos < 0 || r.pos+4 > len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	r.pos += 4
+	return binary.LittleEndian.Uint32(r.data[r.pos-4:])
+}
+
+func (r *bunLockbReader) u64() uint64 {
+	if r.err != nil || r.pos < 0 || r.pos+8 > len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	r.pos += 8
+	return binary.LittleEndian.Uint64(r.data[r.pos-8:])
+}
+
+// bunLockbGraph links bun.lockb packages, keyed by ID, through their
+// resolved dependencies. The root package and workspace packages are the
+// roots.
+func bunLockbGraph(packages []bunLockbPackage) *depGraph {
+	graph := newDepGraph()
+	for i, pkg := range packages {
+		id := strconv.Itoa(i)
+		if pkg.Resolution == bunResolutionRoot || pkg.Resolution == bunResolutionWorkspace {
+			graph.addRoot(id, pkg.Name)
+		} else {
+			graph.addNode(id, pkg.Name)
+		}
+	}
+	for i, pkg := range packages {
+		for _, dep := range pkg.Dependencies {
+			graph.addEdge(strconv.Itoa(i), strconv.Itoa(int(dep)))
+		}
+	}
+	return graph
+}
+
+// scanBunLockb reads the legacy binary bun.lockb without running bun, which
+// would load the scanned project's bunfig.toml. Lockfiles whose package
+// table does not have the expected layout are matched on the registry
+// tarball URLs and raw integrity digests they contain instead.
+func scanBunLockb(file *os.File, filePath string, addFinding func(Finding), verbose bool) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return
+	}
+	packages, err := parseBunLockb(data)
+	if err != nil {
+		if verbose {
+			fmt.Printf("  ⚠️  Could not parse %s (%v), matching tarball URLs and digests only; convert it with bun install --save-text-lockfile\n", filePath, err)
+		}
+		scanBunLockbText(data, filePath, addFinding, verbose)
+		return
+	}
+
+	graph := bunLockbGraph(packages)
+	for i, locked := range packages {
+		if locked.Resolution != bunResolutionNPM {
+			continue
+		}
+		id := locked.Name + "@" + locked.Version
+		pkg := activeMatcher.lookup(locked.Name)
+		reportLockedIntegrity(pkg, locked.Version, locked.Integrity, filePath, id, addFinding, verbose)
+		if pkg == nil || !pkg.matchesVersion(locked.Version) {
+			continue
+		}
+		addFinding(Finding{
+			Package:    pkg.Name,
+			Version:    locked.Version,
+			File:       filePath,
+			Type:       "resolved",
+			IOC:        pkg,
+			Paths:      graph.paths(strconv.Itoa(i), id),
+			Dependents: graph.dependents(strconv.Itoa(i)),
+		})
+		if verbose {
+			fmt.Printf("  Found resolved %s in %s\n", id, filePath)
+		}
+	}
+}
+
+// scanBunLockbText matches the registry tarball URLs and raw sha512
+// integrity digests stored in a bun.lockb it could not parse
+func scanBunLockbText(data []byte, filePath string, addFinding func(Finding), verbose bool) {
+	// Integrity digests are stored as raw bytes
+	for digest, match := range knownIntegrity {
+		algo, encoded, _ := strings.Cut(digest, "-")
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || algo != "sha512" || !bytes.Contains(data, raw) {
+			continue
+		}
+		addIntegrityFinding(match, filePath, "", addFinding, verbose)
+	}
+
+	text := string(data)
+	reported := make(map[string]bool)
+	activeMatcher.findPackages(text, func(pkg *CompromisedPackage, start, end int) {
+		version := tarballVersionAt(text, pkg, end)
+		if version == "" || reported[pkg.Name+"@"+version] || !pkg.matchesVersion(version) {
+			return
+		}
+		reported[pkg.Name+"@"+version] = true
+		addFinding(Finding{
+			Package: pkg.Name,
+			Version: version,
+			File:    filePath,
+			Type:    "resolved",
+			IOC:     pkg,
+		})
+		if verbose {
+			fmt.Printf("  Found resolved %s@%s in %s\n", pkg.Name, version, filePath)
+		}
+	})
+}
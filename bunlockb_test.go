@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// bunLockbWriter lays out a bun.lockb the way bun writes it
+type bunLockbWriter struct {
+	strs []byte
+}
+
+// str encodes s as a bun string, inline if it fits in 8 bytes
+func (w *bunLockbWriter) str(s string) []byte {
+	b := make([]byte, 8)
+	if len(s) <= 8 {
+		copy(b, s)
+		return b
+	}
+	binary.LittleEndian.PutUint32(b[0:], uint32(len(w.strs)))
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(s))|1<<31)
+	w.strs = append(w.strs, s...)
+	return b
+}
+
+// bunLockbFile returns a bun.lockb holding packages, the first of which is
+// the root project
+func bunLockbFile(t *testing.T, packages []bunLockbPackage) []byte {
+	t.Helper()
+	w := &bunLockbWriter{}
+	var ids []byte
+	columns := make([][]byte, len(bunLockbFields))
+	for _, pkg := range packages {
+		row := make([][]byte, len(bunLockbFields))
+		for i, size := range bunLockbFields {
+			row[i] = make([]byte, size)
+		}
+		res := row[1]
+		res[0] = pkg.Resolution
+		switch pkg.Resolution {
+		case bunResolutionNPM:
+			core, build, _ := strings.Cut(pkg.Version, "+")
+			core, pre, _ := strings.Cut(core, "-")
+			for i, part := range strings.Split(core, ".") {
+				n, err := strconv.ParseUint(part, 10, 32)
+				if err != nil {
+					t.Fatal(err)
+				}
+				binary.LittleEndian.PutUint32(res[bunResolutionVersion+i*4:], uint32(n))
+			}
+			copy(res[bunResolutionVersion+16:], w.str(pre))
+			copy(res[bunResolutionVersion+32:], w.str(build))
+		case bunResolutionWorkspace:
+			copy(res[bunResolutionValue:], w.str(pkg.Folder))
+		}
+		if algo, encoded, ok := strings.Cut(pkg.Integrity, "-"); ok {
+			for tag, name := range bunIntegrityAlgorithms {
+				if name == algo {
+					row[4][bunMetaIntegrity] = tag
+				}
+			}
+			digest, _ := base64.StdEncoding.DecodeString(encoded)
+			copy(row[4][bunMetaIntegrity+1:], digest)
+		}
+		binary.LittleEndian.PutUint32(row[3][0:], uint32(len(ids)/4))
+		binary.LittleEndian.PutUint32(row[3][4:], uint32(len(pkg.Dependencies)))
+		for _, id := range pkg.Dependencies {
+			ids = binary.LittleEndian.AppendUint32(ids, id)
+		}
+		copy(row[6], w.str(pkg.Name))
+		for i := range columns {
+			columns[i] = append(columns[i], row[i]...)
+		}
+	}
+
+	var out bytes.Buffer
+	u64 := func(n int) { binary.Write(&out, binary.LittleEndian, uint64(n)) }
+	out.WriteString(bunLockbMagic)
+	binary.Write(&out, binary.LittleEndian, uint32(2))
+	out.Write(make([]byte, 32))
+	u64(0)
+	u64(len(packages))
+	u64(8)
+	u64(len(bunLockbFields))
+	begin := out.Len() + 16
+	u64(begin)
+	u64(begin + len(bytes.Join(columns, nil)))
+	out.Write(bytes.Join(columns, nil))
+
+	// trees, hoisted_dependencies, resolutions, dependencies, extern_strings
+	// and string_bytes
+	types := []string{"install.lockfile.Tree.External", "u32", "u32", "[26]u8", "semver.ExternalString", "u8"}
+	for i, typ := range types {
+		var data []byte
+		switch i {
+		case 2:
+			data = ids
+		case len(types) - 1:
+			data = w.strs
+		}
+		header := out.Len()
+		out.Write(make([]byte, 16))
+		fmt.Fprintf(&out, "\n<%s> 1 sizeof, 1 alignof\n", typ)
+		start := out.Len()
+		out.Write(data)
+		b := out.Bytes()
+		binary.LittleEndian.PutUint64(b[header:], uint64(start))
+		binary.LittleEndian.PutUint64(b[header+8:], uint64(out.Len()))
+	}
+	u64(0)
+	return out.Bytes()
+}
+
+// bunLockbPackages is a workspace, app and @acme/web, depending on chalk
+// and @ctrl/tinycolor, which depend on debug and ansi-styles
+var bunLockbPackages = []bunLockbPackage{
+	{Name: "app", Resolution: bunResolutionRoot, Dependencies: []uint32{1, 2}},
+	{Name: "@acme/web", Resolution: bunResolutionWorkspace, Folder: "packages/web", Dependencies: []uint32{3}},
+	{Name: "chalk", Resolution: bunResolutionNPM, Version: "5.6.1", Integrity: sha512SRI("chalk"), Dependencies: []uint32{4}},
+	{Name: "@ctrl/tinycolor", Resolution: bunResolutionNPM, Version: "4.1.1", Integrity: sha512SRI("tinycolor"), Dependencies: []uint32{2, 5}},
+	{Name: "debug", Resolution: bunResolutionNPM, Version: "4.4.2-beta.1+build.5", Integrity: sha1SRI("debug")},
+	{Name: "ansi-styles", Resolution: bunResolutionNPM, Version: "6.2.1"},
+}
+
+func TestParseBunLockb(t *testing.T) {
+	got, err := parseBunLockb(bunLockbFile(t, bunLockbPackages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, bunLockbPackages) {
+		t.Errorf("got\n  %+v\nwant\n  %+v", got, bunLockbPackages)
+	}
+
+	// Other files, and tables of another layout, are errors rather than
+	// misread packages
+	data := bunLockbFile(t, bunLockbPackages)
+	fewer := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint64(fewer[len(bunLockbMagic)+4+32+8:], uint64(len(bunLockbPackages)-1))
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"text", []byte(`{"lockfileVersion": 1}`), "not a bun.lockb"},
+		{"truncated header", data[:len(bunLockbMagic)+20], "unexpected EOF"},
+		{"truncated table", data[:len(data)/2], "does not have"},
+		{"other row size", fewer, "does not have"},
+	}
+	for _, tt := range tests {
+		if _, err := parseBunLockb(tt.data); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestScanBunLockb(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "@ctrl/tinycolor", Versions: []string{">=4.1.1 <4.1.3"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+		CompromisedPackage{
+			Name:     "ansi-regex",
+			Versions: []string{"6.2.1"},
+			Tarballs: []TarballIOC{{Version: "6.2.1", Integrity: sha512SRI("ansi")}},
+		},
+		CompromisedPackage{Name: "@acme/web", Versions: []string{"*"}},
+	)
+	saved := knownIntegrity
+	knownIntegrity = newIntegrityIndex(compromisedPackages)
+	t.Cleanup(func() { knownIntegrity = saved })
+
+	packages := append([]bunLockbPackage{}, bunLockbPackages...)
+	// A copy of the malicious ansi-regex tarball under another name
+	packages[5].Integrity = sha512SRI("ansi")
+	path := filepath.Join(t.TempDir(), "bun.lockb")
+	if err := os.WriteFile(path, bunLockbFile(t, packages), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var got []string
+	scanBunLockb(file, path, func(f Finding) {
+		s := fmt.Sprintf("%s %s@%s (%s)", f.Type, f.Package, f.Version, f.Location)
+		if len(f.Paths) > 0 {
+			s += fmt.Sprintf(" via %q from %q", f.Paths, f.Dependents)
+		}
+		got = append(got, s)
+	}, false)
+
+	// Versions are range-checked, so prereleases of 4.4.2 are not 4.4.2;
+	// the root and workspace packages are not registry packages
+	want := []string{
+		`resolved chalk@5.6.1 () via ["app > chalk@5.6.1" "@acme/web > @ctrl/tinycolor > chalk@5.6.1"] from ["@acme/web" "app"]`,
+		`resolved @ctrl/tinycolor@4.1.1 () via ["@acme/web > @ctrl/tinycolor@4.1.1"] from ["@acme/web"]`,
+		"integrity ansi-regex@6.2.1 (ansi-styles@6.2.1)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestScanBunLockbUnparsed(t *testing.T) {
+	setTestIOCs(t, CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}})
+	path := filepath.Join(t.TempDir(), "bun.lockb")
+	data := bunLockbMagic + "\x02\x00\x00\x00garbage https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var got []string
+	scanBunLockb(file, path, func(f Finding) { got = append(got, f.Package+"@"+f.Version) }, false)
+	if want := []string{"chalk@5.6.1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
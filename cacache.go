@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacacheIndexDir is the folder of npm's _cacache holding the index
+// buckets, one file per hashed key: index-v5/xx/yy/<rest of hash>
+const cacacheIndexDir = "index-v5"
+
+// cacacheEntry is one line of an index bucket. Lines are appended, so the
+// last one for a key wins, and a null integrity marks a removed entry.
+type cacacheEntry struct {
+	Key       string  `json:"key"`
+	Integrity *string `json:"integrity"`
+	Time      int64   `json:"time"` // milliseconds since the epoch
+	Size      int64   `json:"size"`
+}
+
+// tarballURL returns the registry tarball URL an entry caches. npm keys
+// responses as "make-fetch-happen:request-cache:<url>"; npm 6 also keyed
+// manifests as "pacote:version-manifest:<url>:<integrity>".
+func (entry *cacacheEntry) tarballURL() (string, bool) {
+	start := strings.Index(entry.Key, "http")
+	if start < 0 {
+		return "", false
+	}
+	url := entry.Key[start:]
+	end := strings.Index(url, ".tgz")
+	if end < 0 {
+		// Packuments list every version, compromised or not
+		return "", false
+	}
+	return url[:end+len(".tgz")], true
+}
+
+// contentPath returns where an entry's content is stored:
+// content-v2/<algorithm>/xx/yy/<rest of the hex digest>
+func (entry *cacacheEntry) contentPath(cacheDir string) (string, bool) {
+	if entry.Integrity == nil {
+		return "", false
+	}
+	// Multiple digests may be listed; the first is enough to find the file
+	sri := strings.Fields(*entry.Integrity)
+	if len(sri) == 0 {
+		return "", false
+	}
+	algorithm, digest, ok := strings.Cut(sri[0], "-")
+	if !ok {
+		return "", false
+	}
+	digest, _, _ = strings.Cut(digest, "?")
+	raw, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil || len(raw) < 3 {
+		return "", false
+	}
+	sum := hex.EncodeToString(raw)
+	return filepath.Join(cacheDir, "content-v2", algorithm, sum[:2], sum[2:4], sum[4:]), true
+}
+
+// scanCacacheIndex reports the compromised tarballs npm's _cacache in
+// cacheDir holds, with when they were fetched. Content is addressed by
+// digest, so only the index says which package a blob is. It returns every
+// content file the index resolved, so the content folder walk does not
+// unpack blobs the index already identified.
+func scanCacacheIndex(cacheDir string, addFinding func(Finding), verbose bool) map[string]bool {
+	resolved := make(map[string]bool)
+	buckets := 0
+	filepath.Walk(filepath.Join(cacheDir, cacacheIndexDir), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		buckets++
+		for _, entry := range readCacacheBucket(path) {
+			file := path
+			if content, ok := entry.contentPath(cacheDir); ok {
+				if _, err := os.Stat(content); err == nil {
+					file = content
+					resolved[content] = true
+				}
+			}
+			url, ok := entry.tarballURL()
+			if !ok {
+				continue
+			}
+			location := url
+			if entry.Time > 0 {
+				location += ", fetched " + time.UnixMilli(entry.Time).UTC().Format("2006-01-02 15:04:05 MST")
+			}
+
+			name, ok := registryTarballPackage(url)
+			version, hasVersion := registryTarballVersion(url, name)
+			pkg := activeMatcher.lookup(name)
+			if !ok || !hasVersion || pkg == nil || !pkg.matchesVersion(version) {
+				// Known-bad tarballs may be cached under any name
+				if entry.Integrity != nil {
+					reportIntegrity(*entry.Integrity, file, location, addFinding, verbose)
+				}
+				continue
+			}
+			addFinding(Finding{
+				Package:  pkg.Name,
+				Version:  version,
+				File:     file,
+				Location: location,
+				Type:     "cache",
+				IOC:      pkg,
+			})
+			if verbose {
+				fmt.Printf("  Found %s@%s in npm cache (%s)\n", pkg.Name, version, location)
+			}
+		}
+		return nil
+	})
+	if verbose {
+		fmt.Printf("  📦 Read %d npm cache index buckets in %s\n", buckets, cacheDir)
+	}
+	return resolved
+}
+
+// readCacacheBucket returns the live entries of an index bucket. Each line
+// is "<sha1 of the JSON>\t<JSON>"; lines whose hash does not match were cut
+// short by an interrupted write and are skipped, as npm does.
+func readCacacheBucket(path string) []cacacheEntry {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	latest := make(map[string]cacacheEntry)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		hash, data, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		sum := sha1.Sum([]byte(data))
+		if hex.EncodeToString(sum[:]) != hash {
+			continue
+		}
+		var entry cacacheEntry
+		if json.Unmarshal([]byte(data), &entry) != nil || entry.Key == "" {
+			continue
+		}
+		latest[entry.Key] = entry
+	}
+
+	entries := make([]cacacheEntry, 0, len(latest))
+	for _, entry := range latest {
+		if entry.Integrity != nil {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// packTarball returns a gzipped npm tarball holding only a package.json
+func packTarball(t *testing.T, name, version string) string {
+	t.Helper()
+	manifest := fmt.Sprintf(`{"name": %q, "version": %q}`, name, version)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: "package/package.json", Mode: 0o644, Size: int64(len(manifest))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte(manifest))
+	tw.Close()
+	gz.Close()
+	return buf.String()
+}
+
+// cacacheLine returns an index bucket line for data, hashed as npm does
+func cacacheLine(data string) string {
+	sum := sha1.Sum([]byte(data))
+	return hex.EncodeToString(sum[:]) + "\t" + data + "\n"
+}
+
+// writeCacacheEntry stores content in a _cacache under key, writing the
+// index bucket line and the content-v2 file it points at, and returns the
+// content file
+func writeCacacheEntry(t *testing.T, cacheDir, key, content string) string {
+	t.Helper()
+	integrity := sha512SRI(content)
+	keySum := sha1.Sum([]byte(key))
+	bucket := hex.EncodeToString(keySum[:])
+	entry := cacacheEntry{Key: key, Integrity: &integrity}
+	contentPath, ok := entry.contentPath(cacheDir)
+	if !ok {
+		t.Fatalf("no content path for %s", integrity)
+	}
+	files := map[string]string{
+		filepath.Join(cacheDir, cacacheIndexDir, bucket[:2], bucket[2:4], bucket[4:]): cacacheLine(
+			fmt.Sprintf(`{"key":%q,"integrity":%q,"time":1757350800000,"size":%d}`, key, integrity, len(content))),
+		contentPath: content,
+	}
+	for path, data := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return contentPath
+}
+
+func TestCacacheEntryContentPath(t *testing.T) {
+	integrity := func(s string) *string { return &s }
+	tests := []struct {
+		integrity *string
+		want      string
+	}{
+		// The base64 digest becomes hex, split as xx/yy/rest
+		{integrity("sha512-q83vEjRWeJA="), "content-v2/sha512/ab/cd/ef1234567890"},
+		{integrity("sha1-q83vEjRWeJA=?foo sha512-AAAA"), "content-v2/sha1/ab/cd/ef1234567890"},
+		{integrity("sha512-!!"), ""},
+		{integrity("sha512"), ""},
+		{integrity(""), ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		entry := cacacheEntry{Integrity: tt.integrity}
+		got, ok := entry.contentPath("/cache")
+		if tt.want == "" {
+			if ok {
+				t.Errorf("contentPath(%v) = %q, want none", *tt.integrity, got)
+			}
+			continue
+		}
+		if want := filepath.Join("/cache", filepath.FromSlash(tt.want)); got != want {
+			t.Errorf("contentPath(%q) = %q, want %q", *tt.integrity, got, want)
+		}
+	}
+}
+
+func TestCacacheEntryTarballURL(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"make-fetch-happen:request-cache:https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz", "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz"},
+		{"pacote:version-manifest:https://registry.npmjs.org/@ctrl/tinycolor/-/tinycolor-4.1.1.tgz:sha512-AAAA", "https://registry.npmjs.org/@ctrl/tinycolor/-/tinycolor-4.1.1.tgz"},
+		// Packuments list every version
+		{"make-fetch-happen:request-cache:https://registry.npmjs.org/chalk", ""},
+		{"pacote:tag-manifest:chalk", ""},
+	}
+	for _, tt := range tests {
+		entry := cacacheEntry{Key: tt.key}
+		if got, _ := entry.tarballURL(); got != tt.want {
+			t.Errorf("tarballURL(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestReadCacacheBucket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bucket")
+	bucket := cacacheLine(`{"key":"a","integrity":"sha512-AAAA","time":1}`) +
+		cacacheLine(`{"key":"b","integrity":"sha512-BBBB","time":1}`) +
+		// The last line for a key wins, and a null integrity removes it
+		cacacheLine(`{"key":"a","integrity":"sha512-CCCC","time":2}`) +
+		cacacheLine(`{"key":"b","integrity":null,"time":2}`) +
+		// Interrupted writes leave lines that fail their hash
+		"0000000000000000000000000000000000000000\t" + `{"key":"c","integrity":"sha512-DDDD"}` + "\n" +
+		cacacheLine(`{"key":"d","integrity":"sha512-EEEE"`)
+	if err := os.WriteFile(path, []byte(bucket), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range readCacacheBucket(path) {
+		got = append(got, fmt.Sprintf("%s %s %d", entry.Key, *entry.Integrity, entry.Time))
+	}
+	if want := []string{"a sha512-CCCC 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+}
+
+func TestScanCacacheIndex(t *testing.T) {
+	setTestIOCs(t, CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}})
+	cacheDir := filepath.Join(t.TempDir(), "_cacache")
+	const prefix = "make-fetch-happen:request-cache:"
+	bad := writeCacacheEntry(t, cacheDir, prefix+"https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz", packTarball(t, "chalk", "5.6.1"))
+	clean := writeCacacheEntry(t, cacheDir, prefix+"https://registry.npmjs.org/chalk/-/chalk-5.3.0.tgz", packTarball(t, "chalk", "5.3.0"))
+	packument := writeCacacheEntry(t, cacheDir, prefix+"https://registry.npmjs.org/chalk", `{"name": "chalk", "versions": {"5.6.1": {}}}`)
+
+	// The finding points at the content blob and says when it was fetched
+	var findings []string
+	resolved := scanCacacheIndex(cacheDir, func(f Finding) {
+		findings = append(findings, fmt.Sprintf("%s %s@%s (%s) %v", f.Type, f.Package, f.Version, f.Location, f.File == bad))
+	}, false)
+	want := []string{"cache chalk@5.6.1 (https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz, fetched 2025-09-08 17:00:00 UTC) true"}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("findings %q, want %q", findings, want)
+	}
+	// Every blob the index resolved is skipped by the content walk, clean
+	// tarballs and packuments included
+	if want := map[string]bool{bad: true, clean: true, packument: true}; !reflect.DeepEqual(resolved, want) {
+		t.Errorf("resolved %v, want %v", resolved, want)
+	}
+}
+
+func TestScanCacheDirCacache(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+	)
+	cacheDir := filepath.Join(t.TempDir(), "_cacache")
+	writeCacacheEntry(t, cacheDir, "make-fetch-happen:request-cache:https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz", packTarball(t, "chalk", "5.6.1"))
+
+	// Content the index does not list is still identified by its manifest
+	orphan := packTarball(t, "debug", "4.4.2")
+	integrity := sha512SRI(orphan)
+	entry := cacacheEntry{Integrity: &integrity}
+	path, _ := entry.contentPath(cacheDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(orphan), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The indexed blob is reported once, from the index
+	var got []string
+	scanCacheDir(filepath.Dir(cacheDir), func(f Finding) {
+		got = append(got, f.Type+" "+f.Package+"@"+f.Version)
+	}, false)
+	sort.Strings(got)
+	want := []string{"cache chalk@5.6.1", "cache debug@4.4.2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %s", strings.Join(got, ", "), strings.Join(want, ", "))
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// denoLock covers the npm sections of every deno.lock version: v2 keeps
+// them under "npm.packages", v3 under "packages.npm" and v4+ under "npm".
+// Entries are keyed "name@version", with peer dependencies appended as
+// "_peer@1.0.0". The project's specifiers, such as "npm:chalk@^5.0.0",
+// map to the entries they resolved to.
+type denoLock struct {
+	NPM        json.RawMessage   `json:"npm"`
+	Specifiers map[string]string `json:"specifiers"`
+	Packages   struct {
+		NPM        map[string]denoNPMPackage `json:"npm"`
+		Specifiers map[string]string         `json:"specifiers"`
+	} `json:"packages"`
+}
+
+// denoNPMPackage is an npm entry. Its dependencies are a map from name to
+// entry key up to v3, and from v4 a list of names, or of entry keys where
+// several versions of a name are locked.
+type denoNPMPackage struct {
+	Integrity    string          `json:"integrity"`
+	Dependencies json.RawMessage `json:"dependencies"`
+}
+
+// npmSpecifiers returns the entry keys the project's npm specifiers
+// resolved to
+func (lock *denoLock) npmSpecifiers() []string {
+	var keys []string
+	if len(lock.Packages.Specifiers) > 0 {
+		// v3: "npm:chalk": "npm:chalk@5.6.1"
+		for _, resolved := range lock.Packages.Specifiers {
+			if key, ok := strings.CutPrefix(resolved, "npm:"); ok {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+	if len(lock.Specifiers) > 0 {
+		// v4+: "npm:chalk@^5.0.0": "5.6.1"
+		for spec, version := range lock.Specifiers {
+			if spec, ok := strings.CutPrefix(spec, "npm:"); ok {
+				keys = append(keys, yarnSpecName(spec)+"@"+version)
+			}
+		}
+		return keys
+	}
+	// v2: "chalk": "chalk@5.6.1"
+	var v2 struct {
+		Specifiers map[string]string `json:"specifiers"`
+	}
+	json.Unmarshal(lock.NPM, &v2)
+	for _, key := range v2.Specifiers {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// depGraph links the npm entries, keys sorted, through their dependencies,
+// with the project in dir as the root depending on what its specifiers
+// resolved to
+func (lock *denoLock) depGraph(packages map[string]denoNPMPackage, keys []string, dir string) *depGraph {
+	graph := newDepGraph()
+	graph.addRoot("", projectLabel(dir))
+	byName := make(map[string][]string)
+	for _, key := range keys {
+		name := yarnSpecName(key)
+		graph.addNode(key, name)
+		byName[name] = append(byName[name], key)
+	}
+	// A dependency is an entry key, or a name locked at one version only
+	resolve := func(dep string) string {
+		if _, ok := packages[dep]; ok {
+			return dep
+		}
+		if keys := byName[dep]; len(keys) == 1 {
+			return keys[0]
+		}
+		return ""
+	}
+
+	specifiers := lock.npmSpecifiers()
+	sort.Strings(specifiers)
+	for _, key := range specifiers {
+		if to := resolve(key); to != "" {
+			graph.addEdge("", to)
+		}
+	}
+	for _, key := range keys {
+		var deps []string
+		var byDepName map[string]string
+		if json.Unmarshal(packages[key].Dependencies, &byDepName) == nil {
+			for _, name := range sortedKeys(byDepName) {
+				deps = append(deps, byDepName[name])
+			}
+		} else {
+			json.Unmarshal(packages[key].Dependencies, &deps)
+		}
+		for _, dep := range deps {
+			if to := resolve(dep); to != "" {
+				graph.addEdge(key, to)
+			}
+		}
+	}
+	return graph
+}
+
+// npmPackages returns the npm packages of the lockfile, whichever version
+// wrote it
+func (lock *denoLock) npmPackages() map[string]denoNPMPackage {
+	if len(lock.Packages.NPM) > 0 {
+		return lock.Packages.NPM
+	}
+	if len(lock.NPM) == 0 {
+		return nil
+	}
+	var v2 struct {
+		Packages map[string]denoNPMPackage `json:"packages"`
+	}
+	if json.Unmarshal(lock.NPM, &v2) == nil && len(v2.Packages) > 0 {
+		return v2.Packages
+	}
+	var packages map[string]denoNPMPackage
+	json.Unmarshal(lock.NPM, &packages)
+	return packages
+}
+
+func scanDenoLock(file *os.File, filePath string, addFinding func(Finding), verbose bool) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return
+	}
+	var lock denoLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		if verbose {
+			fmt.Printf("  ⚠️  Could not parse %s (%v), scanning it as text\n", filePath, err)
+		}
+		scanFile(filePath, addFinding, verbose)
+		return
+	}
+
+	packages := lock.npmPackages()
+	keys := make([]string, 0, len(packages))
+	for key := range packages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	graph := lock.depGraph(packages, keys, filepath.Dir(filePath))
+
+	for _, key := range keys {
+		name := yarnSpecName(key)
+		version := strings.TrimPrefix(key, name+"@")
+		if idx := strings.IndexByte(version, '_'); idx >= 0 {
+			version = version[:idx]
+		}
+		pkg := activeMatcher.lookup(name)
+		reportLockedIntegrity(pkg, version, packages[key].Integrity, filePath, "npm:"+key, addFinding, verbose)
+		if pkg == nil || !pkg.matchesVersion(version) {
+			continue
+		}
+		addFinding(Finding{
+			Package:    pkg.Name,
+			Version:    version,
+			File:       filePath,
+			Location:   "npm:" + key,
+			Type:       "resolved",
+			IOC:        pkg,
+			Paths:      graph.paths(key, pkg.Name+"@"+version),
+			Dependents: graph.dependents(key),
+		})
+		if verbose {
+			fmt.Printf("  Found resolved %s@%s (npm:%s) in %s\n", pkg.Name, version, key, filePath)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestScanDenoLock(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "@ctrl/tinycolor", Versions: []string{"4.1.1 || 4.1.2"}},
+		CompromisedPackage{Name: "debug", Versions: []string{">=4.4.3"}},
+	)
+
+	// Every lockfile version keeps its npm packages somewhere else
+	tests := []struct {
+		name string
+		lock string
+	}{
+		{"v2", `{"version": "2", "npm": {"specifiers": {"chalk": "chalk@5.6.1"}, "packages": {
+  "chalk@5.6.1": {"integrity": "sha512-AAAA", "dependencies": {}},
+  "@ctrl/tinycolor@4.1.1_react@18.2.0": {"integrity": "sha512-BBBB", "dependencies": {}},
+  "debug@4.4.2": {"integrity": "sha512-CCCC", "dependencies": {}}
+}}}`},
+		{"v3", `{"version": "3", "packages": {"specifiers": {"npm:chalk": "npm:chalk@5.6.1"}, "npm": {
+  "chalk@5.6.1": {"integrity": "sha512-AAAA", "dependencies": {}},
+  "@ctrl/tinycolor@4.1.1_react@18.2.0": {"integrity": "sha512-BBBB", "dependencies": {}},
+  "debug@4.4.2": {"integrity": "sha512-CCCC", "dependencies": {}}
+}}}`},
+		{"v4", `{"version": "4", "specifiers": {"npm:chalk@^5.0.0": "5.6.1"}, "npm": {
+  "chalk@5.6.1": {"integrity": "sha512-AAAA"},
+  "@ctrl/tinycolor@4.1.1_react@18.2.0": {"integrity": "sha512-BBBB"},
+  "debug@4.4.2": {"integrity": "sha512-CCCC"}
+}}`},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "deno.lock")
+		if err := os.WriteFile(path, []byte(tt.lock), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		scanDenoLock(file, path, func(f Finding) { got[f.Location] = f.Package + "@" + f.Version }, false)
+		file.Close()
+
+		// Peer dependency suffixes are not part of the version
+		want := map[string]string{
+			"npm:@ctrl/tinycolor@4.1.1_react@18.2.0": "@ctrl/tinycolor@4.1.1",
+			"npm:chalk@5.6.1":                        "chalk@5.6.1",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestDenoLockGraph(t *testing.T) {
+	tests := []struct {
+		name string
+		lock string
+	}{
+		// v4+ lists a dependency by name when one version of it is locked
+		{"v4", `{"version": "4", "specifiers": {"npm:chalk@^5.0.0": "5.6.1", "npm:react@^18.0.0": "18.2.0"}, "npm": {
+  "chalk@5.6.1": {"integrity": "sha512-AAAA", "dependencies": ["@ctrl/tinycolor"]},
+  "@ctrl/tinycolor@4.1.1_react@18.2.0": {"integrity": "sha512-BBBB", "dependencies": ["react"]},
+  "react@18.2.0": {"integrity": "sha512-CCCC"}
+}}`},
+		{"v2", `{"version": "2", "npm": {"specifiers": {"chalk": "chalk@5.6.1", "react": "react@18.2.0"}, "packages": {
+  "chalk@5.6.1": {"integrity": "sha512-AAAA", "dependencies": {"@ctrl/tinycolor": "@ctrl/tinycolor@4.1.1_react@18.2.0"}},
+  "@ctrl/tinycolor@4.1.1_react@18.2.0": {"integrity": "sha512-BBBB", "dependencies": {"react": "react@18.2.0"}},
+  "react@18.2.0": {"integrity": "sha512-CCCC", "dependencies": {}}
+}}}`},
+	}
+	for _, tt := range tests {
+		var lock denoLock
+		if err := json.Unmarshal([]byte(tt.lock), &lock); err != nil {
+			t.Fatal(err)
+		}
+		packages := lock.npmPackages()
+		keys := make([]string, 0, len(packages))
+		for key := range packages {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		graph := lock.depGraph(packages, keys, "/work/app")
+
+		// Peer suffixes are part of the key, not the label
+		target := "@ctrl/tinycolor@4.1.1_react@18.2.0"
+		if got, want := graph.paths(target, "@ctrl/tinycolor@4.1.1"), []string{"app > chalk > @ctrl/tinycolor@4.1.1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: paths = %q, want %q", tt.name, got, want)
+		}
+		if got, want := graph.paths("react@18.2.0", "react@18.2.0"), []string{"app > react@18.2.0", "app > chalk > @ctrl/tinycolor > react@18.2.0"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: paths = %q, want %q", tt.name, got, want)
+		}
+		if got, want := graph.dependents(target), []string{"app"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: dependents = %q, want %q", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxDependencyPaths caps the dependency chains reported per finding; a
+// package deep in a large tree can be reachable along thousands of them
+const maxDependencyPaths = 10
+
+// maxPathSearchSteps bounds the search for distinct dependency chains
+const maxPathSearchSteps = 100000
+
+// depGraph is the dependency graph of a lockfile. Nodes are whatever ID the
+// lockfile uses for an installed package, such as a package-lock.json key
+// path or a pnpm package key. Roots are the project and its workspace
+// packages.
+type depGraph struct {
+	roots    []string
+	isRoot   map[string]bool
+	labels   map[string]string
+	children map[string][]string
+	parents  map[string][]string // built by buildParents
+}
+
+func newDepGraph() *depGraph {
+	return &depGraph{
+		isRoot:   make(map[string]bool),
+		labels:   make(map[string]string),
+		children: make(map[string][]string),
+	}
+}
+
+// addRoot adds a project or workspace package the chains start from
+func (g *depGraph) addRoot(id, label string) {
+	if !g.isRoot[id] {
+		g.isRoot[id] = true
+		g.roots = append(g.roots, id)
+	}
+	g.labels[id] = label
+}
+
+// addNode sets the name a package is shown with in chains
+func (g *depGraph) addNode(id, label string) {
+	if _, ok := g.labels[id]; !ok {
+		g.labels[id] = label
+	}
+}
+
+func (g *depGraph) addEdge(from, to string) {
+	if from == to {
+		return
+	}
+	g.children[from] = append(g.children[from], to)
+}
+
+// paths returns the distinct dependency chains from a root to target, such
+// as "my-app > eslint > debug@4.4.2", shortest first. targetLabel is how
+// target itself is shown. Packages no root depends on have no chains, and
+// neither does anything in a nil graph.
+func (g *depGraph) paths(target, targetLabel string) []string {
+	if g == nil {
+		return nil
+	}
+	return g.pathsFrom(g.isRoot, []string{target}, targetLabel)
+}
+
+// pathsFrom returns the distinct chains from any of roots to any of
+// targets, the install IDs of one package version, shortest first
+func (g *depGraph) pathsFrom(roots map[string]bool, targets []string, targetLabel string) []string {
+	g.buildParents()
+
+	// Distance from the nearest root bounds how short a chain through each
+	// package can be
+	dist := make(map[string]int)
+	var queue []string
+	for id := range roots {
+		dist[id] = 0
+		queue = append(queue, id)
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, child := range g.children[id] {
+			if _, ok := dist[child]; !ok {
+				dist[child] = dist[id] + 1
+				queue = append(queue, child)
+			}
+		}
+	}
+	shortest := -1
+	for _, target := range targets {
+		if d, ok := dist[target]; ok && d > 0 && (shortest < 0 || d < shortest) {
+			shortest = d
+		}
+	}
+	if shortest < 0 {
+		return nil
+	}
+
+	// Walk up from the targets, collecting the cycle-free chains to a root
+	// one length at a time so the shortest come first
+	var chains []string
+	seen := make(map[string]bool)
+	steps := 0
+	var onPath map[string]bool
+	var path []string
+	var limit int
+	var truncated bool
+	var walk func(id string)
+	walk = func(id string) {
+		steps++
+		if steps > maxPathSearchSteps || len(chains) >= maxDependencyPaths {
+			return
+		}
+		if roots[id] && len(path) > 1 {
+			if len(path) == limit {
+				if chain := g.formatPath(reversed(path), targetLabel); !seen[chain] {
+					seen[chain] = true
+					chains = append(chains, chain)
+				}
+			}
+			return
+		}
+		for _, parent := range g.parents[id] {
+			d, ok := dist[parent]
+			if !ok || onPath[parent] {
+				continue
+			}
+			if len(path)+1+d > limit {
+				truncated = true
+				continue
+			}
+			onPath[parent] = true
+			path = append(path, parent)
+			walk(parent)
+			path = path[:len(path)-1]
+			onPath[parent] = false
+		}
+	}
+	for limit = shortest + 1; ; limit++ {
+		truncated = false
+		for _, target := range targets {
+			onPath = map[string]bool{target: true}
+			path = []string{target}
+			walk(target)
+		}
+		if !truncated || steps > maxPathSearchSteps || len(chains) >= maxDependencyPaths {
+			break
+		}
+	}
+	return chains
+}
+
+// buildParents indexes the edges by dependency on first use
+func (g *depGraph) buildParents() {
+	if g.parents != nil {
+		return
+	}
+	g.parents = make(map[string][]string)
+	for from, tos := range g.children {
+		for _, to := range tos {
+			g.parents[to] = append(g.parents[to], from)
+		}
+	}
+	for id := range g.parents {
+		sort.Strings(g.parents[id])
+	}
+}
+
+// dependents returns the labels of every root whose dependency tree
+// includes target, sorted
+func (g *depGraph) dependents(target string) []string {
+	if g == nil {
+		return nil
+	}
+	g.buildParents()
+	var labels []string
+	seen := map[string]bool{target: true}
+	queue := []string{target}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		// Chains end at the first root, as in paths
+		if g.isRoot[id] && id != target {
+			labels = append(labels, g.labels[id])
+			continue
+		}
+		for _, parent := range g.parents[id] {
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// formatPath joins the labels of a root-to-target path
+func (g *depGraph) formatPath(path []string, targetLabel string) string {
+	labels := make([]string, len(path))
+	for i, id := range path {
+		labels[i] = g.labels[id]
+	}
+	labels[len(labels)-1] = targetLabel
+	return strings.Join(labels, " > ")
+}
+
+func reversed(ids []string) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[len(ids)-1-i] = id
+	}
+	return out
+}
+
+// sortedKeys returns the names of a dependency map in order, so graphs
+// and chains come out the same on every run
+func sortedKeys(deps map[string]string) []string {
+	keys := make([]string, 0, len(deps))
+	for key := range deps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// packageManifest is the part of package.json the lockfile parsers need
+type packageManifest struct {
+	Name                 string            `json:"name"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+}
+
+// readPackageManifest reads the package.json in dir, if there is one
+func readPackageManifest(dir string) (*packageManifest, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, false
+	}
+	var manifest packageManifest
+	if json.Unmarshal(data, &manifest) != nil {
+		return nil, false
+	}
+	return &manifest, true
+}
+
+// allDependencies returns every dependency a manifest declares, name to
+// range
+func (manifest *packageManifest) allDependencies() map[string]string {
+	deps := make(map[string]string)
+	for _, section := range []map[string]string{manifest.Dependencies, manifest.DevDependencies, manifest.OptionalDependencies} {
+		for name, spec := range section {
+			deps[name] = spec
+		}
+	}
+	return deps
+}
+
+// projectLabel returns how a project folder is shown at the start of a
+// chain: its package.json name, else the folder name
+func projectLabel(dir string) string {
+	if manifest, ok := readPackageManifest(dir); ok && manifest.Name != "" {
+		return manifest.Name
+	}
+	return filepath.Base(dir)
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDepGraphPaths(t *testing.T) {
+	// app > a > c > target, app > b > target, app > a > b; c and d form a
+	// cycle with target
+	g := newDepGraph()
+	g.addRoot("app", "app")
+	for _, id := range []string{"a", "b", "c", "d", "target", "orphan"} {
+		g.addNode(id, id)
+	}
+	for _, edge := range [][2]string{
+		{"app", "a"}, {"app", "b"}, {"a", "c"}, {"a", "b"}, {"c", "target"},
+		{"b", "target"}, {"target", "d"}, {"d", "c"},
+	} {
+		g.addEdge(edge[0], edge[1])
+	}
+
+	// Shortest first, each chain once, no cycles through the target
+	want := []string{
+		"app > b > target@1.0.0",
+		"app > a > b > target@1.0.0",
+		"app > a > c > target@1.0.0",
+	}
+	if got := g.paths("target", "target@1.0.0"); !reflect.DeepEqual(got, want) {
+		t.Errorf("paths = %q, want %q", got, want)
+	}
+	if got := g.paths("orphan", "orphan@1.0.0"); got != nil {
+		t.Errorf("unreachable package has paths %q", got)
+	}
+	if got := g.paths("app", "app"); got != nil {
+		t.Errorf("root has paths %q", got)
+	}
+	var nilGraph *depGraph
+	if got := nilGraph.paths("target", "target"); got != nil {
+		t.Errorf("nil graph has paths %q", got)
+	}
+}
+
+func TestDepGraphPathsCapped(t *testing.T) {
+	// Twenty intermediate packages give twenty equally short chains
+	g := newDepGraph()
+	g.addRoot("app", "app")
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("dep%02d", i)
+		g.addNode(id, id)
+		g.addEdge("app", id)
+		g.addEdge(id, "target")
+	}
+	got := g.paths("target", "target")
+	if len(got) != maxDependencyPaths || got[0] != "app > dep00 > target" {
+		t.Errorf("got %d paths starting %q, want %d starting with dep00", len(got), got[0], maxDependencyPaths)
+	}
+}
+
+func TestDepGraphDependents(t *testing.T) {
+	// Both workspace packages reach debug, only web reaches chalk, and the
+	// root only reaches debug through the api workspace
+	g := newDepGraph()
+	g.addRoot("", "monorepo")
+	g.addRoot("web", "@acme/web")
+	g.addRoot("api", "@acme/api")
+	for _, id := range []string{"chalk", "foo", "debug"} {
+		g.addNode(id, id)
+	}
+	for _, edge := range [][2]string{
+		{"", "api"}, {"web", "chalk"}, {"web", "foo"}, {"api", "debug"}, {"foo", "debug"},
+	} {
+		g.addEdge(edge[0], edge[1])
+	}
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"debug", []string{"@acme/api", "@acme/web"}},
+		{"chalk", []string{"@acme/web"}},
+		{"api", []string{"monorepo"}},
+		{"unknown", nil},
+	}
+	for _, tt := range tests {
+		if got := g.dependents(tt.target); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("dependents(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+	var nilGraph *depGraph
+	if got := nilGraph.dependents("debug"); got != nil {
+		t.Errorf("nil graph has dependents %q", got)
+	}
+}
+
+func TestResolveLockLocation(t *testing.T) {
+	installed := map[string]bool{
+		"node_modules/debug":                                 true,
+		"node_modules/foo/node_modules/debug":                true,
+		"node_modules/foo/node_modules/bar":                  true,
+		"packages/web/node_modules/debug":                    true,
+		"node_modules/@scope/pkg/node_modules/@scope/nested": true,
+	}
+	lookup := func(candidate string) (string, bool) { return candidate, installed[candidate] }
+	tests := []struct {
+		from, dep, want string
+	}{
+		{"", "debug", "node_modules/debug"},
+		{"node_modules/foo", "debug", "node_modules/foo/node_modules/debug"},
+		// Nearest node_modules first, then each parent folder
+		{"node_modules/foo/node_modules/bar", "debug", "node_modules/foo/node_modules/debug"},
+		{"node_modules/other", "debug", "node_modules/debug"},
+		{"packages/web", "debug", "packages/web/node_modules/debug"},
+		{"node_modules/@scope/pkg", "@scope/nested", "node_modules/@scope/pkg/node_modules/@scope/nested"},
+		{"node_modules/foo", "missing", ""},
+	}
+	for _, tt := range tests {
+		if got := resolveLockLocation(tt.from, tt.dep, lookup); got != tt.want {
+			t.Errorf("resolveLockLocation(%q, %q) = %q, want %q", tt.from, tt.dep, got, tt.want)
+		}
+	}
+}
+
+// TestLockfileDependencyPaths installs the same tree with every package
+// manager: the project "app" depends on chalk and foo, and foo on debug
+func TestLockfileDependencyPaths(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+	)
+
+	tests := []struct {
+		name     string
+		file     string
+		scan     func(*os.File, string, func(Finding), bool)
+		manifest string
+		lock     string
+	}{
+		{"package-lock v1", "package-lock.json", scanPackageLockJson, "", `{"name": "app", "lockfileVersion": 1, "dependencies": {
+  "chalk": {"version": "5.6.1"},
+  "foo": {"version": "1.0.0", "requires": {"debug": "^4.0.0"}, "dependencies": {
+    "debug": {"version": "4.4.2"}
+  }}
+}}`},
+		{"package-lock v3", "package-lock.json", scanPackageLockJson, "", `{"name": "app", "lockfileVersion": 3, "packages": {
+  "": {"name": "app", "dependencies": {"chalk": "^5.0.0", "foo": "^1.0.0"}},
+  "node_modules/chalk": {"version": "5.6.1"},
+  "node_modules/debug": {"version": "3.2.7"},
+  "node_modules/foo": {"version": "1.0.0", "dependencies": {"debug": "^4.0.0"}},
+  "node_modules/foo/node_modules/debug": {"version": "4.4.2"}
+}}`},
+		{"yarn classic", "yarn.lock", scanYarnLock, `{"name": "app", "dependencies": {"chalk": "^5.0.0", "foo": "^1.0.0"}}`, `# yarn lockfile v1
+
+
+chalk@^5.0.0:
+  version "5.6.1"
+
+debug@^4.0.0:
+  version "4.4.2"
+
+foo@^1.0.0:
+  version "1.0.0"
+  dependencies:
+    debug "^4.0.0"
+`},
+		{"yarn berry", "yarn.lock", scanYarnLock, "", `__metadata:
+  version: 8
+
+"app@workspace:.":
+  version: 0.0.0-use.local
+  resolution: "app@workspace:."
+  dependencies:
+    chalk: "npm:^5.0.0"
+    foo: "npm:^1.0.0"
+
+"chalk@npm:^5.0.0":
+  version: 5.6.1
+  resolution: "chalk@npm:5.6.1"
+
+"debug@npm:^4.0.0":
+  version: 4.4.2
+  resolution: "debug@npm:4.4.2"
+
+"foo@npm:^1.0.0":
+  version: 1.0.0
+  resolution: "foo@npm:1.0.0"
+  dependencies:
+    debug: "npm:^4.0.0"
+`},
+		{"pnpm v9", "pnpm-lock.yaml", scanPnpmLock, `{"name": "app"}`, `lockfileVersion: '9.0'
+
+importers:
+
+  .:
+    dependencies:
+      chalk:
+        specifier: ^5.0.0
+        version: 5.6.1
+      foo:
+        specifier: ^1.0.0
+        version: 1.0.0
+
+packages:
+
+  chalk@5.6.1:
+    resolution: {integrity: sha512-AAAA}
+
+  debug@4.4.2:
+    resolution: {integrity: sha512-BBBB}
+
+  foo@1.0.0:
+    resolution: {integrity: sha512-CCCC}
+
+snapshots:
+
+  chalk@5.6.1: {}
+
+  debug@4.4.2: {}
+
+  foo@1.0.0:
+    dependencies:
+      debug: 4.4.2
+`},
+		{"bun.lock", "bun.lock", scanBunLock, "", `{"lockfileVersion": 1,
+  "workspaces": {"": {"name": "app", "dependencies": {"chalk": "^5.0.0", "foo": "^1.0.0"}}},
+  "packages": {
+    "chalk": ["chalk@5.6.1", "", {}, "sha512-AAAA"],
+    "debug": ["debug@3.2.7", "", {}, "sha512-BBBB"],
+    "foo": ["foo@1.0.0", "", {"dependencies": {"debug": "^4.0.0"}}, "sha512-CCCC"],
+    "foo/debug": ["debug@4.4.2", "", {}, "sha512-DDDD"],
+  }
+}`},
+		{"bun.lockb", "bun.lockb", scanBunLockb, "", string(bunLockbFile(t, []bunLockbPackage{
+			{Name: "app", Resolution: bunResolutionRoot, Dependencies: []uint32{1, 3}},
+			{Name: "chalk", Resolution: bunResolutionNPM, Version: "5.6.1"},
+			{Name: "debug", Resolution: bunResolutionNPM, Version: "3.2.7"},
+			{Name: "foo", Resolution: bunResolutionNPM, Version: "1.0.0", Dependencies: []uint32{4}},
+			{Name: "debug", Resolution: bunResolutionNPM, Version: "4.4.2"},
+		}))},
+		{"deno.lock v4", "deno.lock", scanDenoLock, `{"name": "app"}`, `{"version": "4",
+  "specifiers": {"npm:chalk@^5.0.0": "5.6.1", "npm:foo@^1.0.0": "1.0.0", "jsr:@std/path@^1.0.0": "1.0.8"},
+  "npm": {
+    "chalk@5.6.1": {"integrity": "sha512-AAAA"},
+    "debug@3.2.7": {"integrity": "sha512-BBBB"},
+    "foo@1.0.0": {"integrity": "sha512-CCCC", "dependencies": ["debug@4.4.2"]},
+    "debug@4.4.2": {"integrity": "sha512-DDDD"}
+  }
+}`},
+		{"deno.lock v3", "deno.lock", scanDenoLock, `{"name": "app"}`, `{"version": "3", "packages": {
+  "specifiers": {"npm:chalk@^5.0.0": "npm:chalk@5.6.1", "npm:foo@^1.0.0": "npm:foo@1.0.0"},
+  "npm": {
+    "chalk@5.6.1": {"integrity": "sha512-AAAA", "dependencies": {}},
+    "debug@3.2.7": {"integrity": "sha512-BBBB", "dependencies": {}},
+    "foo@1.0.0": {"integrity": "sha512-CCCC", "dependencies": {"debug": "debug@4.4.2"}},
+    "debug@4.4.2": {"integrity": "sha512-DDDD", "dependencies": {}}
+  }
+}}`},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if tt.manifest != "" {
+			if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(tt.manifest), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		path := filepath.Join(dir, tt.file)
+		if err := os.WriteFile(path, []byte(tt.lock), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string][]string)
+		tt.scan(file, path, func(f Finding) { got[f.Package] = f.Paths }, false)
+		file.Close()
+
+		want := map[string][]string{
+			"chalk": {"app > chalk@5.6.1"},
+			"debug": {"app > foo > debug@4.4.2"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			var names []string
+			for name := range got {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			t.Errorf("%s: got %v for %v, want %v", tt.name, got, names, want)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// driftLockfiles are the lockfiles a project's install is compared with,
+// in the order package managers prefer them
+var driftLockfiles = []string{"npm-shrinkwrap.json", "package-lock.json", "pnpm-lock.yaml", "yarn.lock", "bun.lock"}
+
+// hiddenLockfile is where npm records the tree it last installed, and
+// trusts instead of rereading node_modules when nothing seems to change
+const hiddenLockfile = "node_modules/.package-lock.json"
+
+// installRecord is one copy of a package version, as a lockfile locks it
+// or as it is installed on disk
+type installRecord struct {
+	Name    string
+	Version string
+	Alias   string
+	// File is the lockfile, or the installed package.json
+	File string
+	// Location is the lockfile's ID for the package, or the install path
+	Location string
+	Optional bool
+}
+
+// installSet maps each package version, "chalk@5.6.1", to its copies
+type installSet map[string][]installRecord
+
+func (set installSet) add(record installRecord) {
+	id := record.Name + "@" + record.Version
+	set[id] = append(set[id], record)
+}
+
+// missingFrom returns the package versions in set that other lacks, sorted
+func (set installSet) missingFrom(other installSet) []string {
+	var ids []string
+	for id := range set {
+		if _, ok := other[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// lockedInstall is what a project's lockfile says should be installed
+type lockedInstall struct {
+	Lockfile string
+	Packages installSet
+	Graph    *depGraph // keyed by the records' Locations
+	// Command reinstalls exactly the locked tree
+	Command string
+}
+
+// scanDrift compares each project's lockfile with its node_modules, so
+// packages left behind by an earlier install, or locked but never
+// installed, are not missed by looking at only one of them. Each drifted
+// project's summary, with the command that reinstalls it, goes to
+// addSummary.
+func scanDrift(baseDir string, jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Finding), addSummary func(string), verbose bool) {
+	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if info.Name() == "node_modules" || info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if nm, err := os.Stat(filepath.Join(path, "node_modules")); err != nil || !nm.IsDir() {
+			return nil
+		}
+		wg.Add(1)
+		jobs <- func() {
+			defer wg.Done()
+			if summary := checkDrift(path, addFinding, verbose); summary != "" {
+				addSummary(summary)
+			}
+		}
+		return nil
+	})
+}
+
+// checkDrift reports the drift between the lockfile and node_modules of
+// the project in dir, calling out compromised versions on only one side.
+// It returns a summary of the drift, or "" if there is none.
+func checkDrift(dir string, addFinding func(Finding), verbose bool) string {
+	locked, ok := readLockedInstall(dir, verbose)
+	if !ok {
+		return ""
+	}
+	installed := readInstalledSet(dir)
+	if len(installed) == 0 {
+		return ""
+	}
+	lockName := filepath.Base(locked.Lockfile)
+
+	// npm's hidden lockfile going stale means npm will not notice the
+	// package on its next install
+	var hidden installSet
+	if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(hiddenLockfile))); err == nil {
+		if packages, _, err := parsePackageLock(data); err == nil {
+			hidden = make(installSet)
+			for _, p := range packages {
+				hidden.add(installRecord{Name: p.Name, Version: p.Version})
+			}
+		} else if verbose {
+			fmt.Printf("  ⚠️  Could not parse %s (%v)\n", filepath.Join(dir, hiddenLockfile), err)
+		}
+	}
+
+	unlocked := installed.missingFrom(locked.Packages)
+	missing := locked.Packages.missingFrom(installed)
+	var uninstalled []string
+	for _, id := range missing {
+		// Optional packages for other platforms are never installed
+		for _, record := range locked.Packages[id] {
+			if !record.Optional {
+				uninstalled = append(uninstalled, id)
+				break
+			}
+		}
+	}
+	var unrecorded []string
+	if hidden != nil {
+		unrecorded = installed.missingFrom(hidden)
+	}
+
+	// Installed versions missing from either lockfile
+	reasons := make(map[string][]string)
+	for _, id := range unlocked {
+		reasons[id] = append(reasons[id], lockName)
+	}
+	for _, id := range unrecorded {
+		reasons[id] = append(reasons[id], hiddenLockfile)
+	}
+	ids := make([]string, 0, len(reasons))
+	for id := range reasons {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		for _, record := range installed[id] {
+			reportDrift(record, record.Location+", not in "+strings.Join(reasons[id], " or "), nil, addFinding, verbose)
+		}
+	}
+
+	for _, id := range missing {
+		for _, record := range locked.Packages[id] {
+			if record.Optional {
+				continue
+			}
+			reportDrift(record, record.Location+", not installed", locked.Graph, addFinding, verbose)
+		}
+	}
+
+	if len(unlocked) > 0 || len(uninstalled) > 0 {
+		return fmt.Sprintf("%s: node_modules has drifted from %s (installed but not locked: %d, locked but not installed: %d); run %s",
+			dir, lockName, len(unlocked), len(uninstalled), locked.Command)
+	}
+	if len(unrecorded) > 0 {
+		return fmt.Sprintf("%s: %s is stale (installed but not recorded: %d); run %s", dir, hiddenLockfile, len(unrecorded), locked.Command)
+	}
+	return ""
+}
+
+// reportDrift adds a drift finding if record is a compromised version
+func reportDrift(record installRecord, location string, graph *depGraph, addFinding func(Finding), verbose bool) {
+	pkg := activeMatcher.lookup(record.Name)
+	if pkg == nil || !pkg.matchesVersion(record.Version) {
+		return
+	}
+	addFinding(Finding{
+		Package:    pkg.Name,
+		Version:    record.Version,
+		Alias:      record.Alias,
+		File:       record.File,
+		Location:   location,
+		Type:       "drift",
+		IOC:        pkg,
+		Paths:      graph.paths(record.Location, pkg.Name+"@"+record.Version),
+		Dependents: graph.dependents(record.Location),
+	})
+	if verbose {
+		fmt.Printf("  Found drifted %s@%s (%s) in %s\n", pkg.Name, record.Version, location, record.File)
+	}
+}
+
+// readLockedInstall reads the first lockfile of dir a package manager
+// would install from
+func readLockedInstall(dir string, verbose bool) (*lockedInstall, bool) {
+	for _, name := range driftLockfiles {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		locked, err := parseLockedInstall(path, data)
+		if err != nil {
+			if verbose {
+				fmt.Printf("  ⚠️  Could not parse %s (%v), skipping drift check\n", path, err)
+			}
+			return nil, false
+		}
+		return locked, true
+	}
+	return nil, false
+}
+
+func parseLockedInstall(path string, data []byte) (*lockedInstall, error) {
+	locked := &lockedInstall{Lockfile: path, Packages: make(installSet)}
+	add := func(name, version, alias, location string, optional bool) {
+		locked.Packages.add(installRecord{Name: name, Version: version, Alias: alias, File: path, Location: location, Optional: optional})
+	}
+	dir := filepath.Dir(path)
+
+	switch filepath.Base(path) {
+	case "package-lock.json", "npm-shrinkwrap.json":
+		packages, graph, err := parsePackageLock(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range packages {
+			add(p.Name, p.Version, p.Alias, p.Location, p.Optional)
+		}
+		locked.Graph, locked.Command = graph, "npm ci"
+
+	case "pnpm-lock.yaml":
+		lock, err := parsePnpmLock(data)
+		if err != nil {
+			return nil, err
+		}
+		// Peer variants, "chalk@5.6.1(react@18.2.0)", are copies of one
+		// version, which is optional only if every variant is
+		keys := make([]string, 0, len(lock.graph))
+		for key := range lock.graph {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		records := make(map[string]*installRecord)
+		var ids []string
+		for _, key := range keys {
+			name, version, ok := parsePnpmPackageKey(key)
+			if !ok || strings.Contains(version, ":") {
+				continue
+			}
+			id := name + "@" + version
+			if record, ok := records[id]; ok {
+				record.Optional = record.Optional && lock.optional[key]
+				continue
+			}
+			records[id] = &installRecord{Name: name, Version: version, File: path, Location: key, Optional: lock.optional[key]}
+			ids = append(ids, id)
+		}
+		for _, id := range ids {
+			locked.Packages.add(*records[id])
+		}
+		locked.Graph, locked.Command = lock.depGraph(dir), "pnpm install --frozen-lockfile"
+
+	case "yarn.lock":
+		if isYarnBerryLock(data) {
+			records := parseYarnBerryLock(data)
+			for _, record := range records {
+				name, protocol, reference, ok := parseBerryLocator(record.Resolution)
+				if !ok || protocol != "npm" {
+					continue
+				}
+				version, _, _ := strings.Cut(reference, "::")
+				if version == "" {
+					version = record.Version
+				}
+				add(name, version, berryAlias(record.Specs, name), record.Resolution, false)
+			}
+			locked.Graph, locked.Command = yarnBerryGraph(records), "yarn install --immutable"
+			break
+		}
+		records, err := parseYarnClassicLock(string(data))
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if len(record.Specs) == 0 {
+				continue
+			}
+			name, alias := record.specPackage(record.Specs[0])
+			add(name, record.Version, alias, strings.Join(record.Specs, ", "), false)
+		}
+		locked.Graph, locked.Command = yarnClassicGraph(records, dir), "yarn install --frozen-lockfile"
+
+	case "bun.lock":
+		var lock bunLock
+		if err := json.Unmarshal(stripJSONTrailingCommas(data), &lock); err != nil {
+			return nil, err
+		}
+		for key, entry := range lock.Packages {
+			if len(entry) == 0 {
+				continue
+			}
+			var locator string
+			json.Unmarshal(entry[0], &locator)
+			name := yarnSpecName(locator)
+			version := strings.TrimPrefix(locator, name+"@")
+			if strings.Contains(version, ":") {
+				continue
+			}
+			alias := ""
+			if installName := bunInstallName(key); installName != name {
+				alias = installName
+			}
+			add(name, version, alias, key, false)
+		}
+		locked.Graph, locked.Command = lock.depGraph(dir), "bun install --frozen-lockfile"
+	}
+	return locked, nil
+}
+
+// readInstalledSet reads what is installed in the node_modules of dir and
+// of its workspace packages, whose conflicting versions npm nests there
+func readInstalledSet(dir string) installSet {
+	installed := make(installSet)
+	dirs := []string{dir}
+	if repo := cachedMonorepo(dir); repo != nil {
+		for _, pkg := range repo.Packages {
+			dirs = append(dirs, pkg.Dir)
+		}
+	}
+	for _, projectDir := range dirs {
+		prefix := ""
+		if rel, err := filepath.Rel(dir, projectDir); err == nil && rel != "." {
+			prefix = filepath.ToSlash(rel) + "/"
+		}
+		for _, p := range readNodeModules(filepath.Join(projectDir, "node_modules")) {
+			alias := ""
+			if p.Folder != p.Name {
+				alias = p.Folder
+			}
+			installed.add(installRecord{
+				Name:     p.Name,
+				Version:  p.Version,
+				Alias:    alias,
+				File:     filepath.Join(p.Dir, "package.json"),
+				Location: prefix + p.Location,
+			})
+		}
+	}
+	return installed
+}
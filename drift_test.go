@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestInstallSetMissingFrom(t *testing.T) {
+	locked, installed := make(installSet), make(installSet)
+	locked.add(installRecord{Name: "chalk", Version: "5.6.1", Location: "node_modules/chalk"})
+	locked.add(installRecord{Name: "debug", Version: "4.4.2", Location: "node_modules/debug"})
+	locked.add(installRecord{Name: "debug", Version: "4.4.2", Location: "node_modules/foo/node_modules/debug"})
+	installed.add(installRecord{Name: "chalk", Version: "5.6.1", Location: "node_modules/a/node_modules/chalk"})
+	installed.add(installRecord{Name: "ms", Version: "2.1.3", Location: "node_modules/ms"})
+
+	// Versions are compared, not where their copies sit
+	if got := locked.missingFrom(installed); !reflect.DeepEqual(got, []string{"debug@4.4.2"}) {
+		t.Errorf("locked but not installed = %q", got)
+	}
+	if got := installed.missingFrom(locked); !reflect.DeepEqual(got, []string{"ms@2.1.3"}) {
+		t.Errorf("installed but not locked = %q", got)
+	}
+	if n := len(locked["debug@4.4.2"]); n != 2 {
+		t.Errorf("debug@4.4.2 has %d copies, want 2", n)
+	}
+}
+
+func TestParseLockedInstall(t *testing.T) {
+	tests := []struct {
+		file    string
+		lock    string
+		command string
+		want    []string // "<location> <name>@<version> [as <alias>] [optional]"
+	}{
+		{"package-lock.json", `{"lockfileVersion": 3, "packages": {
+  "": {"name": "app"},
+  "node_modules/chalk": {"version": "5.6.1"},
+  "node_modules/colors": {"name": "chalk", "version": "5.3.0"},
+  "node_modules/fsevents": {"version": "2.3.3", "optional": true}
+}}`, "npm ci", []string{
+			"node_modules/chalk chalk@5.6.1",
+			"node_modules/colors chalk@5.3.0 as colors",
+			"node_modules/fsevents fsevents@2.3.3 optional",
+		}},
+		// Peer variants and non-registry packages are not installs of a
+		// registry version
+		{"pnpm-lock.yaml", `lockfileVersion: '9.0'
+
+packages:
+
+  chalk@5.6.1:
+    resolution: {integrity: sha512-AAAA}
+
+  local@file:../local:
+    resolution: {directory: ../local, type: directory}
+
+snapshots:
+
+  chalk@5.6.1: {}
+
+  chalk@5.6.1(ms@2.1.3): {}
+
+  local@file:../local: {}
+`, "pnpm install --frozen-lockfile", []string{
+			"chalk@5.6.1 chalk@5.6.1",
+		}},
+		{"yarn.lock", `# yarn lockfile v1
+
+
+chalk@^5.0.0, chalk@^5.6.0:
+  version "5.6.1"
+
+"colors@npm:chalk@^5.0.0":
+  version "5.3.0"
+`, "yarn install --frozen-lockfile", []string{
+			"chalk@^5.0.0, chalk@^5.6.0 chalk@5.6.1",
+			"colors@npm:chalk@^5.0.0 chalk@5.3.0 as colors",
+		}},
+		{"yarn.lock", `__metadata:
+  version: 8
+
+"app@workspace:.":
+  version: 0.0.0-use.local
+  resolution: "app@workspace:."
+
+"chalk@npm:^5.0.0":
+  version: 5.6.1
+  resolution: "chalk@npm:5.6.1"
+`, "yarn install --immutable", []string{
+			"chalk@npm:5.6.1 chalk@5.6.1",
+		}},
+		{"bun.lock", `{"lockfileVersion": 1, "packages": {
+  "chalk": ["chalk@5.6.1", "", {}, "sha512-AAAA"],
+  "colors": ["chalk@5.3.0", "", {}, "sha512-BBBB"],
+  "local": ["local@file:../local", {}],
+}}`, "bun install --frozen-lockfile", []string{
+			"chalk chalk@5.6.1",
+			"colors chalk@5.3.0 as colors",
+		}},
+	}
+	for _, tt := range tests {
+		locked, err := parseLockedInstall(filepath.Join(t.TempDir(), tt.file), []byte(tt.lock))
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		var got []string
+		for _, records := range locked.Packages {
+			for _, record := range records {
+				s := fmt.Sprintf("%s %s@%s", record.Location, record.Name, record.Version)
+				if record.Alias != "" {
+					s += " as " + record.Alias
+				}
+				if record.Optional {
+					s += " optional"
+				}
+				got = append(got, s)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) || locked.Command != tt.command {
+			t.Errorf("%s:\n got %s (%s)\nwant %s (%s)", tt.file, strings.Join(got, "\n     "), locked.Command,
+				strings.Join(tt.want, "\n     "), tt.command)
+		}
+	}
+}
+
+func TestParseLockedInstallPnpmOptional(t *testing.T) {
+	tests := []struct {
+		name string
+		lock string
+		want []string // "<location> <name>@<version> [optional]"
+	}{
+		{"v6 packages", `lockfileVersion: '6.0'
+
+packages:
+
+  /chalk@5.6.1:
+    resolution: {integrity: sha512-AAAA}
+    dev: false
+
+  /fsevents@2.3.3:
+    resolution: {integrity: sha512-BBBB}
+    os: [darwin]
+    requiresBuild: true
+    dev: false
+    optional: true
+`, []string{
+			"/chalk@5.6.1 chalk@5.6.1",
+			"/fsevents@2.3.3 fsevents@2.3.3 optional",
+		}},
+		{"v9 snapshots", `lockfileVersion: '9.0'
+
+packages:
+
+  chalk@5.6.1:
+    resolution: {integrity: sha512-AAAA}
+
+  fsevents@2.3.3:
+    resolution: {integrity: sha512-BBBB}
+    os: [darwin]
+
+  '@esbuild/linux-arm64@0.21.5':
+    resolution: {integrity: sha512-CCCC}
+    cpu: [arm64]
+    optional: true
+
+snapshots:
+
+  chalk@5.6.1: {}
+
+  fsevents@2.3.3:
+    optional: true
+
+  '@esbuild/linux-arm64@0.21.5(chalk@5.6.1)': {}
+`, []string{
+			// One record for the version and its peer variant
+			"@esbuild/linux-arm64@0.21.5 @esbuild/linux-arm64@0.21.5 optional",
+			"chalk@5.6.1 chalk@5.6.1",
+			"fsevents@2.3.3 fsevents@2.3.3 optional",
+		}},
+	}
+	for _, tt := range tests {
+		locked, err := parseLockedInstall(filepath.Join(t.TempDir(), "pnpm-lock.yaml"), []byte(tt.lock))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, records := range locked.Packages {
+			for _, record := range records {
+				s := fmt.Sprintf("%s %s@%s", record.Location, record.Name, record.Version)
+				if record.Optional {
+					s += " optional"
+				}
+				got = append(got, s)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, strings.Join(got, "\n     "), strings.Join(tt.want, "\n     "))
+		}
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+		CompromisedPackage{Name: "ms", Versions: []string{"2.1.3"}},
+		CompromisedPackage{Name: "fsevents", Versions: []string{"2.3.3"}},
+	)
+	dir := t.TempDir()
+	files := map[string]string{
+		"package.json": `{"name": "app"}`,
+		"package-lock.json": `{"name": "app", "lockfileVersion": 3, "packages": {
+  "": {"name": "app", "dependencies": {"debug": "^4.4.0", "ms": "^2.1.0"}, "optionalDependencies": {"fsevents": "^2.3.0"}},
+  "node_modules/debug": {"version": "4.4.2"},
+  "node_modules/fsevents": {"version": "2.3.3", "optional": true},
+  "node_modules/ms": {"version": "2.1.3"}
+}}`,
+		// npm recorded ms when it installed it, but chalk appeared since
+		"node_modules/.package-lock.json": `{"lockfileVersion": 3, "packages": {
+  "node_modules/ms": {"version": "2.1.3"}
+}}`,
+		"node_modules/chalk/package.json": `{"name": "chalk", "version": "5.6.1"}`,
+		"node_modules/ms/package.json":    `{"name": "ms", "version": "2.1.3"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// chalk was left behind by an earlier install and debug was never
+	// installed; ms is on both sides, so it is not drift, and fsevents is
+	// an optional package this platform skips
+	var got []string
+	summary := checkDrift(dir, func(f Finding) {
+		s := fmt.Sprintf("%s %s@%s (%s)", f.Type, f.Package, f.Version, f.Location)
+		if len(f.Paths) > 0 {
+			s += " via " + strings.Join(f.Paths, "; ")
+		}
+		got = append(got, s)
+	}, false)
+	sort.Strings(got)
+	want := []string{
+		"drift chalk@5.6.1 (node_modules/chalk, not in package-lock.json or node_modules/.package-lock.json)",
+		"drift debug@4.4.2 (node_modules/debug, not installed) via app > debug@4.4.2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+	wantSummary := dir + ": node_modules has drifted from package-lock.json (installed but not locked: 1, locked but not installed: 1); run npm ci"
+	if summary != wantSummary {
+		t.Errorf("summary = %q, want %q", summary, wantSummary)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// integrityDigestSizes are the decoded digest lengths of the Subresource
+// Integrity algorithms npm, yarn and pnpm record
+var integrityDigestSizes = map[string]int{
+	"sha1":   20,
+	"sha256": 32,
+	"sha384": 48,
+	"sha512": 64,
+}
+
+// integrityMatch is the IOC behind a known-bad integrity digest
+type integrityMatch struct {
+	pkg     *CompromisedPackage
+	version string
+}
+
+// knownIntegrity maps normalized integrity digests of malicious tarballs to
+// their IOC entries
+var knownIntegrity = map[string]integrityMatch{}
+
+func newIntegrityIndex(packages []CompromisedPackage) map[string]integrityMatch {
+	index := make(map[string]integrityMatch)
+	for i := range packages {
+		for _, tarball := range packages[i].Tarballs {
+			for _, digest := range integrityDigests(tarball.Integrity) {
+				index[digest] = integrityMatch{pkg: &packages[i], version: tarball.Version}
+			}
+		}
+	}
+	return index
+}
+
+// normalizeIntegrity validates a single "<algo>-<base64>" digest and returns
+// it in canonical padded form
+func normalizeIntegrity(value string) (string, bool) {
+	algo, encoded, ok := strings.Cut(strings.TrimSpace(value), "-")
+	if !ok {
+		return "", false
+	}
+	size, ok := integrityDigestSizes[strings.ToLower(algo)]
+	if !ok {
+		return "", false
+	}
+	// Strip SRI options ("sha512-...?foo") and accept unpadded base64
+	if i := strings.IndexByte(encoded, '?'); i >= 0 {
+		encoded = encoded[:i]
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil || len(raw) != size {
+		return "", false
+	}
+	return strings.ToLower(algo) + "-" + base64.StdEncoding.EncodeToString(raw), true
+}
+
+// integrityDigests normalizes each digest of an SRI value. Lockfiles may
+// list several, "sha1-... sha512-...", separated by whitespace; digests with
+// unknown algorithms or bad encodings are left out.
+func integrityDigests(value string) []string {
+	var digests []string
+	for _, field := range strings.Fields(value) {
+		if digest, ok := normalizeIntegrity(field); ok {
+			digests = append(digests, digest)
+		}
+	}
+	return digests
+}
+
+// integrityTokens extracts every SRI digest from a lockfile line, whether it
+// is a JSON string, a yarn "integrity" field or a pnpm resolution map
+func integrityTokens(line string) []string {
+	var tokens []string
+	for algo := range integrityDigestSizes {
+		prefix := algo + "-"
+		for offset := 0; ; {
+			idx := strings.Index(line[offset:], prefix)
+			if idx < 0 {
+				break
+			}
+			start := offset + idx
+			end := start + len(prefix)
+			for end < len(line) && isBase64Char(line[end]) {
+				end++
+			}
+			if start == 0 || !isBase64Char(line[start-1]) {
+				if digest, ok := normalizeIntegrity(line[start:end]); ok {
+					tokens = append(tokens, digest)
+				}
+			}
+			offset = end
+		}
+	}
+	return tokens
+}
+
+func isBase64Char(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '+' || c == '/' || c == '='
+}
+
+// scanIntegrityLine reports every known-malicious tarball digest on a
+// lockfile line
+func scanIntegrityLine(line, filePath string, addFinding func(Finding), verbose bool) {
+	if len(knownIntegrity) == 0 {
+		return
+	}
+	for _, digest := range integrityTokens(line) {
+		reportIntegrity(digest, filePath, "", addFinding, verbose)
+	}
+}
+
+// reportIntegrity reports a lockfile entry's integrity value if any of its
+// digests is a known-malicious tarball digest
+func reportIntegrity(value, filePath, location string, addFinding func(Finding), verbose bool) {
+	if match, ok := lookupIntegrity(value); ok {
+		addIntegrityFinding(match, filePath, location, addFinding, verbose)
+	}
+}
+
+// reportLockedIntegrity reports a lockfile entry's integrity value unless
+// the entry already matched by version: the known tarball of a compromised
+// version is the same package, and would only be counted twice. pkg is the
+// IOC entry for the entry's name, or nil.
+func reportLockedIntegrity(pkg *CompromisedPackage, version, value, filePath, location string, addFinding func(Finding), verbose bool) {
+	if pkg != nil && pkg.matchesVersion(version) {
+		return
+	}
+	reportIntegrity(value, filePath, location, addFinding, verbose)
+}
+
+// lookupIntegrity returns the known-malicious tarball an SRI value names,
+// checking each of its digests
+func lookupIntegrity(value string) (integrityMatch, bool) {
+	if len(knownIntegrity) == 0 {
+		return integrityMatch{}, false
+	}
+	for _, digest := range integrityDigests(value) {
+		if match, ok := knownIntegrity[digest]; ok {
+			return match, true
+		}
+	}
+	return integrityMatch{}, false
+}
+
+func addIntegrityFinding(match integrityMatch, filePath, location string, addFinding func(Finding), verbose bool) {
+	addFinding(Finding{
+		Package:  match.pkg.Name,
+		Version:  match.version,
+		File:     filePath,
+		Location: location,
+		Type:     "integrity",
+		IOC:      match.pkg,
+	})
+	if verbose {
+		fmt.Printf("  Found known-bad tarball integrity of %s@%s in %s\n", match.pkg.Name, match.version, filePath)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sha512SRI(data string) string {
+	sum := sha512.Sum512([]byte(data))
+	return "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func sha1SRI(data string) string {
+	sum := sha1.Sum([]byte(data))
+	return "sha1-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestNormalizeIntegrity(t *testing.T) {
+	bad := sha512SRI("bad")
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{bad, bad, true},
+		{"  " + bad + "\n", bad, true},
+		{strings.TrimRight(bad, "="), bad, true},
+		{"SHA512-" + strings.TrimPrefix(bad, "sha512-"), bad, true},
+		{bad + "?foo", bad, true},
+		{sha1SRI("bad"), sha1SRI("bad"), true},
+		{"md5-" + strings.TrimPrefix(bad, "sha512-"), "", false},
+		{"sha512-" + strings.TrimPrefix(sha1SRI("bad"), "sha1-"), "", false},
+		{"sha512-not base64", "", false},
+		{"sha512", "", false},
+		{"", "", false},
+		// One digest only; integrityDigests splits lists
+		{sha1SRI("bad") + " " + bad, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeIntegrity(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeIntegrity(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIntegrityDigests(t *testing.T) {
+	sha1Digest, sha512Digest := sha1SRI("bad"), sha512SRI("bad")
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{sha512Digest, []string{sha512Digest}},
+		{sha1Digest + " " + sha512Digest, []string{sha1Digest, sha512Digest}},
+		{sha1Digest + "\n\t" + strings.TrimRight(sha512Digest, "="), []string{sha1Digest, sha512Digest}},
+		{"md5-abc " + sha512Digest + " sha512-bogus", []string{sha512Digest}},
+	}
+	for _, tt := range tests {
+		if got := integrityDigests(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("integrityDigests(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIntegrityTokens(t *testing.T) {
+	bad := sha512SRI("bad")
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{`      "integrity": "` + bad + `",`, []string{bad}},
+		{`  integrity ` + bad, []string{bad}},
+		{`    resolution: {integrity: ` + bad + `}`, []string{bad}},
+		{`"integrity": "` + sha1SRI("bad") + ` ` + bad + `"`, []string{sha1SRI("bad"), bad}},
+		{`"resolved": "https://registry.npmjs.org/sha512-x/-/sha512-x-1.0.0.tgz"`, nil},
+	}
+	for _, tt := range tests {
+		got := integrityTokens(tt.line)
+		// Algorithms are searched in map order
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("integrityTokens(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestScanIntegrityLine(t *testing.T) {
+	packages := []CompromisedPackage{{
+		Name:     "chalk",
+		Versions: []string{"5.6.1"},
+		Tarballs: []TarballIOC{{Version: "5.6.1", Integrity: sha512SRI("bad")}},
+	}}
+	saved := knownIntegrity
+	knownIntegrity = newIntegrityIndex(packages)
+	t.Cleanup(func() { knownIntegrity = saved })
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		// Matched whatever name the tarball was installed under
+		{`      "integrity": "` + sha512SRI("bad") + `",`, true},
+		{`  integrity ` + strings.TrimRight(sha512SRI("bad"), "="), true},
+		{`    resolution: {integrity: ` + sha512SRI("bad") + `}`, true},
+		{`      "integrity": "` + sha512SRI("good") + `",`, false},
+		{`      "integrity": "` + sha1SRI("bad") + `",`, false},
+	}
+	for _, tt := range tests {
+		var findings []Finding
+		scanIntegrityLine(tt.line, "package-lock.json", func(f Finding) {
+			findings = append(findings, f)
+		}, false)
+		if got := len(findings) == 1; got != tt.want || len(findings) > 1 {
+			t.Errorf("scanIntegrityLine(%q) found %v, want %v", tt.line, findings, tt.want)
+			continue
+		}
+		if tt.want {
+			f := findings[0]
+			if f.Package != "chalk" || f.Version != "5.6.1" || f.Type != "integrity" || f.IOC != &packages[0] {
+				t.Errorf("scanIntegrityLine(%q) = %+v", tt.line, f)
+			}
+		}
+	}
+}
+
+func TestTarballIOCValidation(t *testing.T) {
+	tests := []struct {
+		tarball string
+		want    string
+	}{
+		{`{"version": "latest", "integrity": "` + sha512SRI("bad") + `"}`, "chalk tarball"},
+		{`{"version": "5.6.1", "integrity": "sha512-short"}`, `chalk@5.6.1 has invalid integrity "sha512-short"`},
+		// Every digest of a list must be valid
+		{`{"version": "5.6.1", "integrity": "` + sha512SRI("bad") + ` md5-abc"}`, "chalk@5.6.1 has invalid integrity"},
+	}
+	for _, tt := range tests {
+		data := `{"schemaVersion": 4, "generated": "2025-09-16", "source": "test", "packages": [{"name": "chalk", "versions": ["5.6.1"], "tarballs": [` + tt.tarball + `]}]}`
+		if _, err := parseIOCDatabase([]byte(data)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.tarball, err, tt.want)
+		}
+	}
+}
+
+func TestReportIntegrity(t *testing.T) {
+	packages := []CompromisedPackage{{
+		Name:     "chalk",
+		Versions: []string{"5.6.1"},
+		Tarballs: []TarballIOC{{Version: "5.6.1", Integrity: sha512SRI("bad")}},
+	}}
+	saved := knownIntegrity
+	knownIntegrity = newIntegrityIndex(packages)
+	t.Cleanup(func() { knownIntegrity = saved })
+
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{sha512SRI("bad"), true},
+		{strings.TrimRight(sha512SRI("bad"), "="), true},
+		// Any digest of a multi-digest value may be the known one
+		{sha1SRI("bad") + " " + sha512SRI("bad"), true},
+		{sha512SRI("good") + " " + sha512SRI("bad"), true},
+		{sha1SRI("bad") + " " + sha512SRI("good"), false},
+		{sha512SRI("good"), false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		var findings []Finding
+		reportIntegrity(tt.value, "package-lock.json", "node_modules/x", func(f Finding) {
+			findings = append(findings, f)
+		}, false)
+		if got := len(findings) == 1; got != tt.want || len(findings) > 1 {
+			t.Errorf("reportIntegrity(%q) found %v, want %v", tt.value, findings, tt.want)
+			continue
+		}
+		// The finding points at the lockfile entry, whatever it is named
+		if tt.want {
+			f := findings[0]
+			if f.Package != "chalk" || f.Version != "5.6.1" || f.Type != "integrity" || f.Location != "node_modules/x" {
+				t.Errorf("reportIntegrity(%q) = %+v", tt.value, f)
+			}
+		}
+	}
+}
+
+// TestLockedIntegrityReportedOnce locks chalk@5.6.1 and a re-hosted copy
+// named colors, both with the known tarball digest, in every lockfile
+// format. chalk is reported by its version alone; colors only by its
+// integrity.
+func TestLockedIntegrityReportedOnce(t *testing.T) {
+	bad := sha512SRI("bad")
+	setTestIOCs(t, CompromisedPackage{
+		Name:     "chalk",
+		Versions: []string{"5.6.1"},
+		Tarballs: []TarballIOC{{Version: "5.6.1", Integrity: bad}},
+	})
+	saved := knownIntegrity
+	knownIntegrity = newIntegrityIndex(compromisedPackages)
+	t.Cleanup(func() { knownIntegrity = saved })
+
+	tests := []struct {
+		name string
+		file string
+		scan func(*os.File, string, func(Finding), bool)
+		lock string
+		want []string
+	}{
+		{"package-lock", "package-lock.json", scanPackageLockJson, `{"lockfileVersion": 3, "packages": {
+  "node_modules/chalk": {"version": "5.6.1", "integrity": "` + bad + `"},
+  "node_modules/colors": {"version": "1.0.0", "integrity": "` + bad + `"}
+}}`, []string{"resolved chalk@5.6.1 (node_modules/chalk)", "integrity chalk@5.6.1 (node_modules/colors)"}},
+		{"yarn classic", "yarn.lock", scanYarnLock, `# yarn lockfile v1
+
+
+chalk@^5.0.0:
+  version "5.6.1"
+  integrity ` + bad + `
+
+colors@^1.0.0:
+  version "1.0.0"
+  integrity ` + bad + `
+`, []string{"resolved chalk@5.6.1 (chalk@^5.0.0)", "integrity chalk@5.6.1 (colors@^1.0.0)"}},
+		{"pnpm v9", "pnpm-lock.yaml", scanPnpmLock, `lockfileVersion: '9.0'
+
+packages:
+
+  chalk@5.6.1:
+    resolution: {integrity: ` + bad + `}
+
+  colors@1.0.0:
+    resolution: {integrity: ` + bad + `}
+
+snapshots:
+
+  chalk@5.6.1: {}
+
+  colors@1.0.0: {}
+`, []string{"integrity chalk@5.6.1 (colors@1.0.0)", "resolved chalk@5.6.1 (chalk@5.6.1)"}},
+		{"bun.lock", "bun.lock", scanBunLock, `{"lockfileVersion": 1, "packages": {
+  "chalk": ["chalk@5.6.1", "", {}, "` + bad + `"],
+  "colors": ["colors@1.0.0", "", {}, "` + bad + `"],
+}}`, []string{"resolved chalk@5.6.1 (chalk)", "integrity chalk@5.6.1 (colors)"}},
+		{"deno.lock", "deno.lock", scanDenoLock, `{"version": "4", "npm": {
+  "chalk@5.6.1": {"integrity": "` + bad + `"},
+  "colors@1.0.0": {"integrity": "` + bad + `"}
+}}`, []string{"resolved chalk@5.6.1 (npm:chalk@5.6.1)", "integrity chalk@5.6.1 (npm:colors@1.0.0)"}},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.file)
+		if err := os.WriteFile(path, []byte(tt.lock), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		tt.scan(file, path, func(f Finding) {
+			got = append(got, fmt.Sprintf("%s %s@%s (%s)", f.Type, f.Package, f.Version, f.Location))
+		}, false)
+		file.Close()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
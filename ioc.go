@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// iocSchemaVersion is the IOC database schema this build understands
+const iocSchemaVersion = 4
+
+// defaultIOCData is the IOC database compiled into the binary, used when no
+// -ioc-file is given
+//
+//go:embed iocs.json
+var defaultIOCData []byte
+
+// iocSeverities are the accepted severity levels, most severe first
+var iocSeverities = []string{"critical", "high", "medium", "low"}
+
+// IOCDatabase is the versioned on-disk format of the compromised package list
+type IOCDatabase struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	Version       string               `json:"version,omitempty"`
+	Generated     string               `json:"generated"`
+	Source        string               `json:"source"`
+	Campaigns     []IOCCampaign        `json:"campaigns,omitempty"`
+	Packages      []CompromisedPackage `json:"packages"`
+	Payloads      []PayloadIOC         `json:"payloads,omitempty"`
+
+	origin   string // where the database was loaded from
+	signedBy string // ID of the key that signed it, empty if unverified
+}
+
+// TarballIOC is the lockfile integrity digest of one malicious tarball
+type TarballIOC struct {
+	Version   string `json:"version"`
+	Integrity string `json:"integrity"` // Subresource Integrity, e.g. "sha512-...", may list several digests
+}
+
+// PayloadIOC identifies a known malicious file by content hash, so it is
+// caught even when shipped under a name or version that isn't listed. At
+// least one of FileName and Size must be set; they decide which files are
+// worth hashing.
+type PayloadIOC struct {
+	SHA256      string `json:"sha256"`
+	FileName    string `json:"fileName,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Description string `json:"description,omitempty"`
+	Campaign    string `json:"campaign,omitempty"`
+
+	campaign *IOCCampaign // resolved from Campaign by validate()
+}
+
+// IOCCampaign describes an attack wave shared by many IOC entries, so hits
+// can be grouped and explained by campaign
+type IOCCampaign struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Advisories  []string `json:"advisories,omitempty"`
+	FirstSeen   string   `json:"firstSeen,omitempty"`
+	Severity    string   `json:"severity,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+}
+
+// loadIOCDatabase reads the IOC database from path, or the embedded default
+// when path is empty
+func loadIOCDatabase(path string) (*IOCDatabase, error) {
+	if path == "" {
+		db, err := parseIOCDatabase(defaultIOCData)
+		if err != nil {
+			return nil, fmt.Errorf("embedded IOC database: %w", err)
+		}
+		return db, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := parseIOCDatabase(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// loadTrustedIOCDatabase loads the IOC database and, for external files,
+// verifies the detached Ed25519 signature next to it. Unsigned or tampered
+// bundles are refused unless allowUnsigned is set.
+func loadTrustedIOCDatabase(path string, pubKeys []string, allowUnsigned bool) (*IOCDatabase, error) {
+	if path == "" {
+		db, err := loadIOCDatabase("")
+		if err != nil {
+			return nil, err
+		}
+		db.origin = "embedded"
+		return db, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sigData, sigErr := readIOCSignature(path)
+	return decodeTrustedIOCBundle(path, data, sigData, sigErr, pubKeys, allowUnsigned)
+}
+
+// decodeTrustedIOCBundle verifies and parses bundle data named by origin.
+// sigErr reports why the signature could not be read, if it couldn't.
+func decodeTrustedIOCBundle(origin string, data, sigData []byte, sigErr error, pubKeys []string, allowUnsigned bool) (*IOCDatabase, error) {
+	keyID, err := "", sigErr
+	if err == nil {
+		keyID, err = verifyIOCSignature(data, sigData, pubKeys)
+	}
+	if err != nil {
+		if !allowUnsigned {
+			return nil, fmt.Errorf("%s: %w (pass -allow-unsigned-ioc to use it anyway)", origin, err)
+		}
+		fmt.Fprintf(os.Stderr, "⚠️  Using unverified IOC bundle %s: %v\n", origin, err)
+	}
+
+	db, err := parseIOCDatabase(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", origin, err)
+	}
+	db.origin = origin
+	db.signedBy = keyID
+	return db, nil
+}
+
+// provenance describes where the database came from and how it was verified
+func (db *IOCDatabase) provenance() string {
+	switch {
+	case db.origin == "embedded":
+		return "embedded in this binary"
+	case db.signedBy == "":
+		return db.origin + ", UNVERIFIED"
+	}
+	return fmt.Sprintf("%s, signed by key %s", db.origin, db.signedBy)
+}
+
+// parseIOCDatabase decodes and validates an IOC database
+func parseIOCDatabase(data []byte) (*IOCDatabase, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var db IOCDatabase
+	if err := decoder.Decode(&db); err != nil {
+		return nil, fmt.Errorf("invalid IOC database JSON: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid IOC database JSON: trailing data after top-level object")
+	}
+	if err := db.validate(); err != nil {
+		return nil, err
+	}
+	return &db, nil
+}
+
+// validate checks the header and every entry so a malformed file is rejected
+// instead of silently scanning for nothing
+func (db *IOCDatabase) validate() error {
+	if db.SchemaVersion == 0 {
+		return fmt.Errorf("missing schemaVersion")
+	}
+	if db.SchemaVersion > iocSchemaVersion {
+		return fmt.Errorf("unsupported schemaVersion %d (this build supports up to %d)", db.SchemaVersion, iocSchemaVersion)
+	}
+	if strings.TrimSpace(db.Generated) == "" {
+		return fmt.Errorf("missing generated date")
+	}
+	if _, err := db.generatedTime(); err != nil {
+		return fmt.Errorf("generated %q is not a YYYY-MM-DD or RFC 3339 date", db.Generated)
+	}
+	if strings.TrimSpace(db.Source) == "" {
+		return fmt.Errorf("missing source")
+	}
+	if len(db.Packages) == 0 {
+		return fmt.Errorf("no packages defined")
+	}
+
+	campaigns := make(map[string]*IOCCampaign)
+	for i := range db.Campaigns {
+		campaign := &db.Campaigns[i]
+		if strings.TrimSpace(campaign.ID) == "" {
+			return fmt.Errorf("campaigns[%d]: missing id", i)
+		}
+		if campaigns[campaign.ID] != nil {
+			return fmt.Errorf("campaigns[%d]: duplicate campaign %s", i, campaign.ID)
+		}
+		if err := validateIOCMetadata(campaign.Advisories, campaign.FirstSeen, campaign.Severity); err != nil {
+			return fmt.Errorf("campaigns[%d]: %s: %w", i, campaign.ID, err)
+		}
+		campaigns[campaign.ID] = campaign
+	}
+
+	seen := make(map[string]bool)
+	for i, pkg := range db.Packages {
+		if !isValidPackageName(pkg.Name) {
+			return fmt.Errorf("packages[%d]: invalid package name %q", i, pkg.Name)
+		}
+		if seen[pkg.Name] {
+			return fmt.Errorf("packages[%d]: duplicate entry for %s", i, pkg.Name)
+		}
+		seen[pkg.Name] = true
+
+		if len(pkg.Versions) == 0 {
+			return fmt.Errorf("packages[%d]: %s has no versions", i, pkg.Name)
+		}
+		if err := db.Packages[i].compile(); err != nil {
+			return fmt.Errorf("packages[%d]: %s: %w", i, pkg.Name, err)
+		}
+		if err := validateIOCMetadata(pkg.Advisories, pkg.FirstSeen, pkg.Severity); err != nil {
+			return fmt.Errorf("packages[%d]: %s: %w", i, pkg.Name, err)
+		}
+		for _, tarball := range pkg.Tarballs {
+			if _, err := parseSemVer(tarball.Version); err != nil {
+				return fmt.Errorf("packages[%d]: %s tarball: %w", i, pkg.Name, err)
+			}
+			if digests := integrityDigests(tarball.Integrity); len(digests) == 0 || len(digests) != len(strings.Fields(tarball.Integrity)) {
+				return fmt.Errorf("packages[%d]: %s@%s has invalid integrity %q", i, pkg.Name, tarball.Version, tarball.Integrity)
+			}
+		}
+		if pkg.Campaign != "" {
+			campaign, ok := campaigns[pkg.Campaign]
+			if !ok {
+				return fmt.Errorf("packages[%d]: %s references unknown campaign %q", i, pkg.Name, pkg.Campaign)
+			}
+			db.Packages[i].campaign = campaign
+		}
+	}
+
+	seenHashes := make(map[string]bool)
+	for i, payload := range db.Payloads {
+		if !isSHA256Hex(payload.SHA256) {
+			return fmt.Errorf("payloads[%d]: sha256 %q is not 64 lowercase hex characters", i, payload.SHA256)
+		}
+		if seenHashes[payload.SHA256] {
+			return fmt.Errorf("payloads[%d]: duplicate hash %s", i, payload.SHA256)
+		}
+		seenHashes[payload.SHA256] = true
+		if payload.Size < 0 || (payload.Size == 0 && payload.FileName == "") {
+			return fmt.Errorf("payloads[%d]: %s needs a fileName or a positive size", i, payload.SHA256)
+		}
+		if strings.ContainsAny(payload.FileName, "/\\") {
+			return fmt.Errorf("payloads[%d]: fileName %q must be a base name", i, payload.FileName)
+		}
+		if payload.Campaign != "" {
+			campaign, ok := campaigns[payload.Campaign]
+			if !ok {
+				return fmt.Errorf("payloads[%d]: %s references unknown campaign %q", i, payload.SHA256, payload.Campaign)
+			}
+			db.Payloads[i].campaign = campaign
+		}
+	}
+	return nil
+}
+
+func isSHA256Hex(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= '0' && r <= '9') && !(r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// metadata returns the campaign details for a payload hit
+func (payload *PayloadIOC) metadata() IOCCampaign {
+	meta := IOCCampaign{ID: payload.Campaign}
+	if c := payload.campaign; c != nil {
+		meta = *c
+	}
+	return meta
+}
+
+// generatedTime parses the generation date, either YYYY-MM-DD or RFC 3339
+func (db *IOCDatabase) generatedTime() (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, db.Generated); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", db.Generated)
+}
+
+// label identifies the threat-intel snapshot for reports
+func (db *IOCDatabase) label() string {
+	if db.Version != "" {
+		return fmt.Sprintf("version %s, generated %s", db.Version, db.Generated)
+	}
+	return "generated " + db.Generated
+}
+
+// validateIOCMetadata checks the advisory and triage fields shared by
+// campaigns and package entries
+func validateIOCMetadata(advisories []string, firstSeen, severity string) error {
+	for _, advisory := range advisories {
+		if !strings.HasPrefix(advisory, "https://") && !strings.HasPrefix(advisory, "http://") {
+			return fmt.Errorf("advisory %q is not an http(s) URL", advisory)
+		}
+	}
+	if firstSeen != "" {
+		if _, err := time.Parse("2006-01-02", firstSeen); err != nil {
+			return fmt.Errorf("firstSeen %q is not a YYYY-MM-DD date", firstSeen)
+		}
+	}
+	if severity != "" && severityRank(severity) < 0 {
+		return fmt.Errorf("unknown severity %q (want one of %s)", severity, strings.Join(iocSeverities, ", "))
+	}
+	return nil
+}
+
+// severityRank orders severities from most (0) to least severe, or -1 for an
+// unknown value
+func severityRank(severity string) int {
+	for i, s := range iocSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// metadata returns the campaign and advisory details for pkg. Fields left
+// empty on the entry are inherited from its campaign; advisories are combined.
+func (pkg *CompromisedPackage) metadata() IOCCampaign {
+	meta := IOCCampaign{
+		ID:          pkg.Campaign,
+		Advisories:  pkg.Advisories,
+		FirstSeen:   pkg.FirstSeen,
+		Severity:    pkg.Severity,
+		Remediation: pkg.Remediation,
+	}
+	if c := pkg.campaign; c != nil {
+		meta.Name = c.Name
+		meta.Advisories = append(append([]string(nil), c.Advisories...), pkg.Advisories...)
+		if meta.FirstSeen == "" {
+			meta.FirstSeen = c.FirstSeen
+		}
+		if meta.Severity == "" {
+			meta.Severity = c.Severity
+		}
+		if meta.Remediation == "" {
+			meta.Remediation = c.Remediation
+		}
+	}
+	return meta
+}
+
+// compile parses every entry in Versions into a semver range and indexes
+// the plain versions so most lookups skip range evaluation
+func (pkg *CompromisedPackage) compile() error {
+	pkg.ranges = make([]SemVerRange, 0, len(pkg.Versions))
+	pkg.exact = make(map[string]bool, len(pkg.Versions))
+	pkg.onlyExact = true
+	for _, version := range pkg.Versions {
+		if strings.TrimSpace(version) == "" {
+			return fmt.Errorf("empty version")
+		}
+		r, err := parseSemVerRange(version)
+		if err != nil {
+			return err
+		}
+		pkg.ranges = append(pkg.ranges, r)
+
+		if v, err := parseSemVer(version); err == nil && v.String() == version {
+			pkg.exact[version] = true
+		} else {
+			pkg.onlyExact = false
+		}
+	}
+	return nil
+}
+
+// matchesVersion reports whether version falls in any compromised range
+func (pkg *CompromisedPackage) matchesVersion(version string) bool {
+	if pkg.exact[version] {
+		return true
+	}
+	if pkg.onlyExact && isPlainVersion(version) {
+		return false
+	}
+	v, err := parseSemVer(version)
+	if err != nil {
+		return false
+	}
+	for _, r := range pkg.ranges {
+		if r.Matches(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// admittedVersions returns the compromised versions, or ranges, that a
+// declared dependency range would accept on a fresh install
+func (pkg *CompromisedPackage) admittedVersions(declared SemVerRange) []string {
+	var admitted []string
+	for i, version := range pkg.Versions {
+		if pkg.exact[version] {
+			if v, err := parseSemVer(version); err == nil && declared.Matches(v) {
+				admitted = append(admitted, version)
+			}
+		} else if declared.Intersects(pkg.ranges[i]) {
+			admitted = append(admitted, version)
+		}
+	}
+	return admitted
+}
+
+// isPlainVersion reports whether version is a bare "major.minor.patch" with
+// nothing parseSemVer would normalize away
+func isPlainVersion(version string) bool {
+	dots := 0
+	for i := 0; i < len(version); i++ {
+		switch c := version[i]; {
+		case c == '.':
+			dots++
+		case c < '0' || c > '9':
+			return false
+		}
+	}
+	return dots == 2
+}
+
+// isValidPackageName reports whether name looks like an npm package name,
+// either "name" or "@scope/name"
+func isValidPackageName(name string) bool {
+	if name == "" || len(name) > 214 || strings.ToLower(name) != name {
+		return false
+	}
+
+	if strings.HasPrefix(name, "@") {
+		parts := strings.Split(name[1:], "/")
+		if len(parts) != 2 {
+			return false
+		}
+		return isValidNameSegment(parts[0]) && isValidNameSegment(parts[1])
+	}
+	return isValidNameSegment(name)
+}
+
+func isValidNameSegment(segment string) bool {
+	if segment == "" || strings.HasPrefix(segment, ".") || strings.HasPrefix(segment, "_") {
+		return false
+	}
+	for _, r := range segment {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runIOCCommand implements the "ioc" subcommands and returns the exit code
+func runIOCCommand(args []string) int {
+	if len(args) == 0 {
+		printIOCUsage()
+		return 2
+	}
+
+	switch args[0] {
+	case "import-osv":
+		return runImportOSV(args[1:])
+	case "keygen":
+		return runIOCKeygen(args[1:])
+	case "sign":
+		return runIOCSign(args[1:])
+	case "verify":
+		return runIOCVerify(args[1:])
+	case "update":
+		return runIOCUpdate(args[1:])
+	case "help", "-h", "-help", "--help":
+		printIOCUsage()
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown ioc command '%s'\n\n", args[0])
+		printIOCUsage()
+		return 2
+	}
+}
+
+func printIOCUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: check-npm-cache ioc <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  import-osv <path>   Merge npm advisories from an OSV zip, directory or JSON file into an IOC database")
+	fmt.Fprintln(os.Stderr, "  keygen              Generate an Ed25519 key pair for signing IOC bundles")
+	fmt.Fprintln(os.Stderr, "  sign <bundle>       Sign an IOC bundle, writing <bundle>.sig")
+	fmt.Fprintln(os.Stderr, "  verify <bundle>     Verify an IOC bundle against its <bundle>.sig")
+	fmt.Fprintln(os.Stderr, "  update              Fetch the latest signed IOC bundle from the feed into the user cache")
+}
+
+func runImportOSV(args []string) int {
+	fs := flag.NewFlagSet("ioc import-osv", flag.ContinueOnError)
+	baseFile := fs.String("ioc-file", "", "Signed IOC database to merge into (default: embedded database)")
+	var pubKeys stringList
+	fs.Var(&pubKeys, "ioc-pubkey", "Trusted Ed25519 public key (PEM file or base64) for verifying -ioc-file; repeatable")
+	allowUnsigned := fs.Bool("allow-unsigned-ioc", false, "Merge into an -ioc-file even if its signature is missing or invalid (unsafe)")
+	output := fs.String("o", "", "Write the merged IOC database to this file (default: stdout)")
+	verbose := fs.Bool("verbose", false, "Show skipped records and ranges")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: check-npm-cache ioc import-osv [flags] <osv.zip|dir|record.json>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	osvPath := fs.Arg(0)
+
+	db, err := loadTrustedIOCDatabase(*baseFile, pubKeys, *allowUnsigned)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error loading IOC database: %v\n", err)
+		return 1
+	}
+
+	records, err := readOSVRecords(osvPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading OSV data: %v\n", err)
+		return 1
+	}
+
+	var stats osvImportStats
+	imported := osvToCompromisedPackages(records, &stats)
+	stats.Packages = len(imported)
+	changed := mergeCompromisedPackages(db, imported)
+
+	db.Generated = time.Now().UTC().Format("2006-01-02")
+	db.Version = time.Now().UTC().Format("2006.01.02") + "-osv"
+	db.Source = fmt.Sprintf("%s; OSV import from %s", db.Source, filepath.Base(osvPath))
+	if err := db.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Merged IOC database is invalid: %v\n", err)
+		return 1
+	}
+
+	if err := writeIOCDatabase(db, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error writing IOC database: %v\n", err)
+		return 1
+	}
+
+	if *verbose {
+		for _, warning := range stats.Warnings {
+			fmt.Fprintf(os.Stderr, "  ⚠️  %s\n", warning)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "📥 Imported %d OSV records (%d withdrawn skipped, %d warnings)\n", stats.Records, stats.Skipped, len(stats.Warnings))
+	fmt.Fprintf(os.Stderr, "🛡️  %d npm packages in advisories, %d IOC entries added or extended, %d total\n", stats.Packages, changed, len(db.Packages))
+	return 0
+}
+
+func runIOCKeygen(args []string) int {
+	fs := flag.NewFlagSet("ioc keygen", flag.ContinueOnError)
+	output := fs.String("o", "ioc-signing", "Write <name>.key (private) and <name>.pub (public)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	privPEM, pubPEM, pub, err := generateIOCKeyPair()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error generating key pair: %v\n", err)
+		return 1
+	}
+
+	keyPath, pubPath := *output+".key", *output+".pub"
+	for _, path := range []string{keyPath, pubPath} {
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintf(os.Stderr, "❌ Refusing to overwrite existing %s\n", path)
+			return 1
+		}
+	}
+	if err := os.WriteFile(keyPath, privPEM, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error writing %s: %v\n", keyPath, err)
+		return 1
+	}
+	if err := os.WriteFile(pubPath, pubPEM, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error writing %s: %v\n", pubPath, err)
+		return 1
+	}
+
+	fmt.Printf("🔑 Private key: %s (keep it off shared drives)\n", keyPath)
+	fmt.Printf("🔑 Public key:  %s\n", pubPath)
+	fmt.Printf("   Key ID: %s\n", iocKeyID(pub))
+	fmt.Printf("   Base64: %s\n", base64.StdEncoding.EncodeToString(pub))
+	return 0
+}
+
+func runIOCSign(args []string) int {
+	fs := flag.NewFlagSet("ioc sign", flag.ContinueOnError)
+	keyPath := fs.String("key", "", "Ed25519 private key in PEM (PKCS #8) format")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: check-npm-cache ioc sign -key <private.key> <bundle.json>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || *keyPath == "" {
+		fs.Usage()
+		return 2
+	}
+	bundlePath := fs.Arg(0)
+
+	keyData, err := os.ReadFile(*keyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading key: %v\n", err)
+		return 1
+	}
+	priv, err := parseIOCPrivateKey(keyData)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error parsing key %s: %v\n", *keyPath, err)
+		return 1
+	}
+
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading bundle: %v\n", err)
+		return 1
+	}
+	// Never sign something the scanner would refuse to load
+	if _, err := parseIOCDatabase(data); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Refusing to sign invalid IOC bundle %s: %v\n", bundlePath, err)
+		return 1
+	}
+
+	sig, err := json.MarshalIndent(signIOCBundle(data, priv), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error encoding signature: %v\n", err)
+		return 1
+	}
+	sigPath := bundlePath + iocSignatureSuffix
+	if err := os.WriteFile(sigPath, append(sig, '\n'), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error writing %s: %v\n", sigPath, err)
+		return 1
+	}
+	fmt.Printf("✍️  Signed %s with key %s -> %s\n", bundlePath, iocKeyID(priv.Public().(ed25519.PublicKey)), sigPath)
+	return 0
+}
+
+func runIOCVerify(args []string) int {
+	fs := flag.NewFlagSet("ioc verify", flag.ContinueOnError)
+	var pubKeys stringList
+	fs.Var(&pubKeys, "ioc-pubkey", "Trusted Ed25519 public key (PEM file or base64); repeatable")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: check-npm-cache ioc verify [-ioc-pubkey <key>]... <bundle.json>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	bundlePath := fs.Arg(0)
+
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error reading bundle: %v\n", err)
+		return 1
+	}
+	sigData, err := readIOCSignature(bundlePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s: %v\n", bundlePath, err)
+		return 1
+	}
+	keyID, err := verifyIOCSignature(data, sigData, pubKeys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s: %v\n", bundlePath, err)
+		return 1
+	}
+	db, err := parseIOCDatabase(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s: signature is valid but the bundle is not: %v\n", bundlePath, err)
+		return 1
+	}
+
+	fmt.Printf("✅ %s: valid signature by key %s\n", bundlePath, keyID)
+	fmt.Printf("   %d packages, generated %s, source %s\n", len(db.Packages), db.Generated, db.Source)
+	return 0
+}
+
+func runIOCUpdate(args []string) int {
+	fs := flag.NewFlagSet("ioc update", flag.ContinueOnError)
+	url := fs.String("url", "", "IOC bundle URL; <url>.sig must hold its signature (default: $"+iocFeedURLEnv+" or the last URL used)")
+	var pubKeys stringList
+	fs.Var(&pubKeys, "ioc-pubkey", "Trusted Ed25519 public key (PEM file or base64); repeatable")
+	allowUnsigned := fs.Bool("allow-unsigned-ioc", false, "Cache the bundle even if its signature is missing or invalid (unsafe)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cacheDir, err := iocCacheDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Could not determine cache directory: %v\n", err)
+		return 1
+	}
+	feedURL := resolveIOCFeedURL(*url, readIOCFeedMeta(cacheDir))
+	if feedURL == "" {
+		fmt.Fprintf(os.Stderr, "❌ No IOC feed URL configured: pass -url or set %s\n", iocFeedURLEnv)
+		return 2
+	}
+
+	fmt.Printf("🌐 Fetching IOC bundle from %s\n", feedURL)
+	result, err := updateIOCBundle(feedURL, pubKeys, *allowUnsigned)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ IOC update failed: %v\n", err)
+		// Tell the user what scans will fall back to
+		if cached, cacheErr := loadCachedIOCDatabase(pubKeys, *allowUnsigned); cacheErr == nil {
+			fmt.Fprintf(os.Stderr, "   Scans keep using the last good bundle: %s (%s)\n", cached.label(), cached.provenance())
+		} else if embedded, embeddedErr := loadIOCDatabase(""); embeddedErr == nil {
+			fmt.Fprintf(os.Stderr, "   Scans keep using the embedded bundle: %s\n", embedded.label())
+		}
+		return 1
+	}
+
+	if result.NotModified {
+		fmt.Printf("✅ IOC bundle is up to date: %s\n", result.DB.label())
+	} else {
+		fmt.Printf("✅ Updated IOC bundle: %s, %d packages\n", result.DB.label(), len(result.DB.Packages))
+	}
+	fmt.Printf("   Cached in %s\n", cacheDir)
+	return 0
+}
+
+// writeIOCDatabase writes db as indented JSON to path, or stdout when path is
+// empty
+func writeIOCDatabase(db *IOCDatabase, path string) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false) // keep range operators like ">=" readable
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(db); err != nil {
+		return err
+	}
+
+	if path == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
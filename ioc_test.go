@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadIOCDatabaseEmbedded(t *testing.T) {
+	db, err := loadIOCDatabase("")
+	if err != nil {
+		t.Fatalf("embedded database: %v", err)
+	}
+	if db.SchemaVersion != iocSchemaVersion || len(db.Packages) == 0 {
+		t.Errorf("embedded database has schema %d and %d packages", db.SchemaVersion, len(db.Packages))
+	}
+}
+
+func TestLoadIOCDatabaseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iocs.json")
+	data := `{"schemaVersion": 1, "generated": "2025-09-16", "source": "test", "packages": [{"name": "chalk", "versions": ["5.6.1"]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := loadIOCDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Packages) != 1 || db.Packages[0].Name != "chalk" || db.Source != "test" {
+		t.Errorf("loaded %+v", db)
+	}
+
+	// Errors name the file that was rejected
+	if _, err := loadIOCDatabase(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file loaded")
+	}
+	if err := os.WriteFile(path, []byte(`{"schemaVersion": 1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadIOCDatabase(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("got %v, want an error naming %s", err, path)
+	}
+}
+
+func TestParseIOCDatabaseRejectsMalformed(t *testing.T) {
+	const header = `"generated": "2025-09-16", "source": "test"`
+	tests := []struct {
+		name string
+		data string
+		want string // substring of the error
+	}{
+		{"not JSON", `{`, "invalid IOC database JSON"},
+		{"trailing data", `{"schemaVersion": 1, ` + header + `, "packages": [{"name": "a", "versions": ["1.0.0"]}]} {}`, "trailing data"},
+		{"unknown field", `{"schemaVersion": 1, ` + header + `, "pakages": []}`, "unknown field"},
+		{"no schema", `{` + header + `, "packages": [{"name": "a", "versions": ["1.0.0"]}]}`, "missing schemaVersion"},
+		{"newer schema", `{"schemaVersion": 99, ` + header + `, "packages": [{"name": "a", "versions": ["1.0.0"]}]}`, "unsupported schemaVersion 99"},
+		{"no date", `{"schemaVersion": 1, "source": "test", "packages": [{"name": "a", "versions": ["1.0.0"]}]}`, "missing generated date"},
+		{"no source", `{"schemaVersion": 1, "generated": "2025-09-16", "packages": [{"name": "a", "versions": ["1.0.0"]}]}`, "missing source"},
+		{"no packages", `{"schemaVersion": 1, ` + header + `, "packages": []}`, "no packages"},
+		{"bad name", `{"schemaVersion": 1, ` + header + `, "packages": [{"name": "Chalk", "versions": ["1.0.0"]}]}`, `invalid package name "Chalk"`},
+		{"duplicate", `{"schemaVersion": 1, ` + header + `, "packages": [{"name": "a", "versions": ["1.0.0"]}, {"name": "a", "versions": ["2.0.0"]}]}`, "duplicate entry for a"},
+		{"no versions", `{"schemaVersion": 1, ` + header + `, "packages": [{"name": "a", "versions": []}]}`, "a has no versions"},
+		{"bad version", `{"schemaVersion": 1, ` + header + `, "packages": [{"name": "a", "versions": [""]}]}`, "packages[0]"},
+	}
+	for _, tt := range tests {
+		_, err := parseIOCDatabase([]byte(tt.data))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestIsValidPackageName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"chalk", true},
+		{"@ctrl/tinycolor", true},
+		{"lodash.merge", true},
+		{"is-arrayish", true},
+		{"", false},
+		{"Chalk", false},
+		{".hidden", false},
+		{"_private", false},
+		{"@scope", false},
+		{"@scope/a/b", false},
+		{"has space", false},
+		{strings.Repeat("a", 215), false},
+	}
+	for _, tt := range tests {
+		if got := isValidPackageName(tt.name); got != tt.want {
+			t.Errorf("isValidPackageName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// setTestIOCs makes packages the IOC list scanners match against until the
+// test ends
+func setTestIOCs(t *testing.T, packages ...CompromisedPackage) {
+	t.Helper()
+	for i := range packages {
+		if err := packages[i].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	savedPackages, savedMatcher := compromisedPackages, activeMatcher
+	compromisedPackages, activeMatcher = packages, newIOCMatcher(packages)
+	t.Cleanup(func() { compromisedPackages, activeMatcher = savedPackages, savedMatcher })
+}
+
+func TestIOCCampaignMetadata(t *testing.T) {
+	db, err := parseIOCDatabase([]byte(`{
+  "schemaVersion": 2, "generated": "2025-09-16", "source": "test",
+  "campaigns": [{
+    "id": "chalk-debug-2025-09", "name": "chalk/debug crypto-drainer",
+    "advisories": ["https://example.com/chalk-debug"], "firstSeen": "2025-09-08",
+    "severity": "critical", "remediation": "Purge browser bundles."
+  }],
+  "packages": [
+    {"name": "chalk", "versions": ["5.6.1"], "campaign": "chalk-debug-2025-09"},
+    {"name": "debug", "versions": ["4.4.2"], "campaign": "chalk-debug-2025-09",
+     "advisories": ["https://example.com/debug"], "severity": "high", "remediation": "Rotate secrets."},
+    {"name": "left-pad", "versions": ["1.3.1"]}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries inherit what they leave empty; advisories are combined
+	chalk, debug, leftPad := db.Packages[0].metadata(), db.Packages[1].metadata(), db.Packages[2].metadata()
+	if chalk.Name != "chalk/debug crypto-drainer" || chalk.Severity != "critical" || chalk.FirstSeen != "2025-09-08" || chalk.Remediation != "Purge browser bundles." {
+		t.Errorf("chalk metadata = %+v, want the campaign's", chalk)
+	}
+	if debug.Severity != "high" || debug.Remediation != "Rotate secrets." || debug.FirstSeen != "2025-09-08" ||
+		strings.Join(debug.Advisories, " ") != "https://example.com/chalk-debug https://example.com/debug" {
+		t.Errorf("debug metadata = %+v, want its own severity and remediation", debug)
+	}
+	if leftPad.ID != "" || leftPad.Severity != "" {
+		t.Errorf("left-pad metadata = %+v, want none", leftPad)
+	}
+}
+
+func TestIOCCampaignValidation(t *testing.T) {
+	const header = `"schemaVersion": 2, "generated": "2025-09-16", "source": "test"`
+	tests := []struct {
+		name      string
+		campaigns string
+		pkg       string
+		want      string
+	}{
+		{"unknown campaign", `[]`, `"campaign": "nope"`, `unknown campaign "nope"`},
+		{"campaign without id", `[{"name": "x"}]`, `"severity": "low"`, "campaigns[0]: missing id"},
+		{"duplicate campaign", `[{"id": "a"}, {"id": "a"}]`, `"severity": "low"`, "duplicate campaign a"},
+		{"bad severity", `[]`, `"severity": "urgent"`, `unknown severity "urgent"`},
+		{"bad date", `[{"id": "a", "firstSeen": "Sep 8"}]`, `"campaign": "a"`, `firstSeen "Sep 8"`},
+		{"bad advisory", `[]`, `"advisories": ["GHSA-1"]`, `advisory "GHSA-1"`},
+	}
+	for _, tt := range tests {
+		data := `{` + header + `, "campaigns": ` + tt.campaigns + `, "packages": [{"name": "a", "versions": ["1.0.0"], ` + tt.pkg + `}]}`
+		if _, err := parseIOCDatabase([]byte(data)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
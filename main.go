@@ -4,29 +4,71 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
-// CompromisedPackage represents a package and its compromised versions
+// CompromisedPackage represents a package and its compromised versions.
+// Each entry in Versions is an exact version or an npm-style semver range.
+// Campaign metadata left empty is inherited from the referenced IOCCampaign.
 type CompromisedPackage struct {
-	Name     string
-	Versions []string
+	Name        string   `json:"name"`
+	Versions    []string `json:"versions"`
+	Campaign    string   `json:"campaign,omitempty"`
+	Advisories  []string `json:"advisories,omitempty"`
+	FirstSeen   string   `json:"firstSeen,omitempty"`
+	Severity    string   `json:"severity,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+	// Tarballs lists the integrity digests of known-malicious tarballs,
+	// matched in lockfiles whatever name or registry they were installed from
+	Tarballs []TarballIOC `json:"tarballs,omitempty"`
+
+	ranges    []SemVerRange   // compiled from Versions by compile()
+	exact     map[string]bool // Versions entries that are plain versions
+	onlyExact bool            // every Versions entry is a plain version
+	campaign  *IOCCampaign    // resolved from Campaign by validate()
 }
 
 // Finding represents a discovered compromised package
 type Finding struct {
 	Package string
 	Version string
-	File    string
-	Type    string // "file", "cache", "resolved"
+	// Alias is the name an npm: alias installed Package under, such as
+	// "colors-safe" for "npm:chalk@5.6.1"
+	Alias string
+	File  string
+	// Location is where inside File the package is installed, such as the
+	// package-lock.json key "node_modules/a/node_modules/chalk"
+	Location string
+	// Paths are the dependency chains from the project or a workspace
+	// package to Package, such as "my-app > eslint > debug@4.4.2", shortest
+	// first
+	Paths []string
+	// Dependents are the project and workspace packages whose dependency
+	// trees include Package, by name
+	Dependents []string
+	Type       string              // "file", "cache", "resolved", "installed", "drift", "exposure", "tarball", "integrity", "payload"
+	IOC        *CompromisedPackage // the IOC entry that matched
+	Payload    *PayloadIOC         // the payload IOC for "payload" findings
+	// Confidence is confidenceHigh, confidenceMedium or confidenceLow;
+	// detectors that only report exact matches leave it empty
+	Confidence string
 }
 
+// Confidence levels of a finding
+const (
+	confidenceHigh   = "high"   // name and version are paired, e.g. name@1.2.3
+	confidenceMedium = "medium" // only separators between name and version, e.g. "name ^1.2.3"
+	confidenceLow    = "low"    // name and version merely share a line
+)
+
 // Scanner configuration
 type ScanConfig struct {
 	BaseDir    string
@@ -35,230 +77,41 @@ type ScanConfig struct {
 	RepoOnly   bool
 	MaxWorkers int
 	Verbose    bool
+	IOCFile    string
+	IOCPubKeys stringList
+	// AllowUnsignedIOC accepts an IOC bundle without a valid signature
+	AllowUnsignedIOC bool
+	UpdateIOC        bool
+	// Drift compares each project's lockfile with its node_modules
+	Drift bool
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
 }
 
-var compromisedPackages = []CompromisedPackage{
-	{"ansi-regex", []string{"6.2.1"}},
-	{"ansi-styles", []string{"6.2.2"}},
-	{"backslash", []string{"0.2.1"}},
-	{"chalk", []string{"5.6.1"}},
-	{"chalk-template", []string{"1.1.1"}},
-	{"color-convert", []string{"3.1.1"}},
-	{"color-name", []string{"2.0.1"}},
-	{"color-string", []string{"2.1.1"}},
-	{"debug", []string{"4.4.2"}},
-	{"error-ex", []string{"1.3.3"}},
-	{"has-ansi", []string{"6.0.1"}},
-	{"is-arrayish", []string{"0.3.3"}},
-	{"proto-tinker-wc", []string{"0.1.87"}},
-	{"simple-swizzle", []string{"0.2.3"}},
-	{"slice-ansi", []string{"7.1.1"}},
-	{"strip-ansi", []string{"7.1.1"}},
-	{"supports-color", []string{"10.2.1"}},
-	{"supports-hyperlinks", []string{"4.1.1"}},
-	{"wrap-ansi", []string{"9.0.1"}},
-
-	// Original packages updated
-	{"angulartics2", []string{"14.1.1", "14.1.2"}},
-	{"@ctrl/deluge", []string{"7.2.1", "7.2.2"}},
-	{"@ctrl/golang-template", []string{"1.4.2", "1.4.3"}},
-	{"@ctrl/magnet-link", []string{"4.0.3", "4.0.4"}},
-	{"@ctrl/ngx-codemirror", []string{"7.0.1", "7.0.2"}},
-	{"@ctrl/ngx-csv", []string{"6.0.1", "6.0.2"}},
-	{"@ctrl/ngx-emoji-mart", []string{"9.2.1", "9.2.2"}},
-	{"@ctrl/ngx-rightclick", []string{"4.0.1", "4.0.2"}},
-	{"@ctrl/qbittorrent", []string{"9.7.1", "9.7.2"}},
-	{"@ctrl/react-adsense", []string{"2.0.1", "2.0.2"}},
-	{"@ctrl/shared-torrent", []string{"6.3.1", "6.3.2"}},
-	{"@ctrl/tinycolor", []string{"4.1.1", "4.1.2"}},
-	{"@ctrl/torrent-file", []string{"4.1.1", "4.1.2"}},
-	{"@ctrl/transmission", []string{"7.3.1"}},
-	{"@ctrl/ts-base32", []string{"4.0.1", "4.0.2"}},
-	{"encounter-playground", []string{"0.0.2", "0.0.3", "0.0.4", "0.0.5"}},
-	{"json-rules-engine-simplified", []string{"0.2.1", "0.2.4"}},
-	{"koa2-swagger-ui", []string{"5.11.1", "5.11.2"}},
-	{"ngx-color", []string{"10.0.1", "10.0.2"}},
-	{"ngx-toastr", []string{"19.0.1", "19.0.2"}},
-	{"ngx-trend", []string{"8.0.1"}},
-	{"react-complaint-image", []string{"0.0.32", "0.0.35"}},
-	{"react-jsonschema-form-conditionals", []string{"0.3.18", "0.3.21"}},
-	{"react-jsonschema-form-extras", []string{"1.0.4"}},
-	{"rxnt-authentication", []string{"0.0.3", "0.0.4", "0.0.5", "0.0.6"}},
-	{"rxnt-healthchecks-nestjs", []string{"1.0.2", "1.0.3", "1.0.4", "1.0.5"}},
-	{"rxnt-kue", []string{"1.0.4", "1.0.5", "1.0.6", "1.0.7"}},
-	{"swc-plugin-component-annotate", []string{"1.9.1", "1.9.2"}},
-	{"ts-gaussian", []string{"3.0.5", "3.0.6"}},
-
-	// New packages from your list
-	{"@ahmedhfarag/ngx-perfect-scrollbar", []string{"20.0.20"}},
-	{"@ahmedhfarag/ngx-virtual-scroller", []string{"4.0.4"}},
-	{"@art-ws/common", []string{"2.0.28"}},
-	{"@art-ws/config-eslint", []string{"2.0.4", "2.0.5"}},
-	{"@art-ws/config-ts", []string{"2.0.7", "2.0.8"}},
-	{"@art-ws/db-context", []string{"2.0.24"}},
-	{"@art-ws/di-node", []string{"2.0.13"}},
-	{"@art-ws/di", []string{"2.0.28", "2.0.32"}},
-	{"@art-ws/eslint", []string{"1.0.5", "1.0.6"}},
-	{"@art-ws/fastify-http-server", []string{"2.0.24", "2.0.27"}},
-	{"@art-ws/http-server", []string{"2.0.21", "2.0.25"}},
-	{"@art-ws/openapi", []string{"0.1.9", "0.1.12"}},
-	{"@art-ws/package-base", []string{"1.0.5", "1.0.6"}},
-	{"@art-ws/prettier", []string{"1.0.5", "1.0.6"}},
-	{"@art-ws/slf", []string{"2.0.15", "2.0.22"}},
-	{"@art-ws/ssl-info", []string{"1.0.9", "1.0.10"}},
-	{"@art-ws/web-app", []string{"1.0.3", "1.0.4"}},
-	{"@crowdstrike/commitlint", []string{"8.1.1", "8.1.2"}},
-	{"@crowdstrike/falcon-shoelace", []string{"0.4.1", "0.4.2"}},
-	{"@crowdstrike/foundry-js", []string{"0.19.1", "0.19.2"}},
-	{"@crowdstrike/glide-core", []string{"0.34.2", "0.34.3"}},
-	{"@crowdstrike/logscale-dashboard", []string{"1.205.1", "1.205.2"}},
-	{"@crowdstrike/logscale-file-editor", []string{"1.205.1", "1.205.2"}},
-	{"@crowdstrike/logscale-parser-edit", []string{"1.205.1", "1.205.2"}},
-	{"@crowdstrike/logscale-search", []string{"1.205.1", "1.205.2"}},
-	{"@crowdstrike/tailwind-toucan-base", []string{"5.0.1", "5.0.2"}},
-	{"@hestjs/core", []string{"0.2.1"}},
-	{"@hestjs/cqrs", []string{"0.1.6"}},
-	{"@hestjs/demo", []string{"0.1.2"}},
-	{"@hestjs/eslint-config", []string{"0.1.2"}},
-	{"@hestjs/logger", []string{"0.1.6"}},
-	{"@hestjs/scalar", []string{"0.1.7"}},
-	{"@hestjs/validation", []string{"0.1.6"}},
-	{"@nativescript-community/arraybuffers", []string{"1.1.6", "1.1.7", "1.1.8"}},
-	{"@nativescript-community/gesturehandler", []string{"2.0.35"}},
-	{"@nativescript-community/perms", []string{"3.0.5", "3.0.6", "3.0.7", "3.0.8"}},
-	{"@nativescript-community/sentry", []string{"4.6.43"}},
-	{"@nativescript-community/sqlite", []string{"3.5.2", "3.5.3", "3.5.4", "3.5.5"}},
-	{"@nativescript-community/text", []string{"1.6.9", "1.6.10", "1.6.11", "1.6.12", "1.6.13"}},
-	{"@nativescript-community/typeorm", []string{"0.2.30", "0.2.31", "0.2.32", "0.2.33"}},
-	{"@nativescript-community/ui-collectionview", []string{"6.0.6"}},
-	{"@nativescript-community/ui-document-picker", []string{"1.1.27", "1.1.28"}},
-	{"@nativescript-community/ui-drawer", []string{"0.1.30"}},
-	{"@nativescript-community/ui-image", []string{"4.5.6"}},
-	{"@nativescript-community/ui-label", []string{"1.3.35", "1.3.36", "1.3.37"}},
-	{"@nativescript-community/ui-material-bottom-navigation", []string{"7.2.72", "7.2.73", "7.2.74", "7.2.75"}},
-	{"@nativescript-community/ui-material-bottomsheet", []string{"7.2.72"}},
-	{"@nativescript-community/ui-material-core-tabs", []string{"7.2.72", "7.2.73", "7.2.74", "7.2.75", "7.2.76"}},
-	{"@nativescript-community/ui-material-core", []string{"7.2.72", "7.2.73", "7.2.74", "7.2.75", "7.2.76"}},
-	{"@nativescript-community/ui-material-ripple", []string{"7.2.72", "7.2.73", "7.2.74", "7.2.75"}},
-	{"@nativescript-community/ui-material-tabs", []string{"7.2.72", "7.2.73", "7.2.74", "7.2.75"}},
-	{"@nativescript-community/ui-pager", []string{"14.1.36", "14.1.37", "14.1.38"}},
-	{"@nativescript-community/ui-pulltorefresh", []string{"2.5.4", "2.5.5", "2.5.6", "2.5.7"}},
-	{"@nexe/config-manager", []string{"0.1.1"}},
-	{"@nexe/eslint-config", []string{"0.1.1"}},
-	{"@nexe/logger", []string{"0.1.3"}},
-	{"@nstudio/angular", []string{"20.0.4", "20.0.5", "20.0.6"}},
-	{"@nstudio/focus", []string{"20.0.4", "20.0.5", "20.0.6"}},
-	{"@nstudio/nativescript-checkbox", []string{"2.0.6", "2.0.7", "2.0.8", "2.0.9"}},
-	{"@nstudio/nativescript-loading-indicator", []string{"5.0.1", "5.0.2", "5.0.3", "5.0.4"}},
-	{"@nstudio/ui-collectionview", []string{"5.1.11", "5.1.12", "5.1.13", "5.1.14"}},
-	{"@nstudio/web-angular", []string{"20.0.4"}},
-	{"@nstudio/web", []string{"20.0.4"}},
-	{"@nstudio/xplat-utils", []string{"20.0.5", "20.0.6", "20.0.7"}},
-	{"@nstudio/xplat", []string{"20.0.5", "20.0.6", "20.0.7"}},
-	{"@operato/board", []string{"9.0.36", "9.0.37", "9.0.38", "9.0.39", "9.0.40", "9.0.41", "9.0.42", "9.0.43", "9.0.44", "9.0.45", "9.0.46"}},
-	{"@operato/data-grist", []string{"9.0.29", "9.0.35", "9.0.36", "9.0.37"}},
-	{"@operato/graphql", []string{"9.0.22", "9.0.35", "9.0.36", "9.0.37", "9.0.38", "9.0.39", "9.0.40", "9.0.41", "9.0.42", "9.0.43", "9.0.44", "9.0.45", "9.0.46"}},
-	{"@operato/headroom", []string{"9.0.2", "9.0.35", "9.0.36", "9.0.37"}},
-	{"@operato/help", []string{"9.0.35", "9.0.36", "9.0.37", "9.0.38", "9.0.39", "9.0.40", "9.0.41", "9.0.42", "9.0.43", "9.0.44", "9.0.45", "9.0.46"}},
-	{"@operato/i18n", []string{"9.0.35", "9.0.36", "9.0.37"}},
-	{"@operato/input", []string{"9.0.27", "9.0.35", "9.0.36", "9.0.37", "9.0.38", "9.0.39", "9.0.40", "9.0.41", "9.0.42", "9.0.43", "9.0.44", "9.0.45", "9.0.46", "9.0.47", "9.0.48"}},
-	{"@operato/layout", []string{"9.0.35", "9.0.36", "9.0.37"}},
-	{"@operato/popup", []string{"9.0.22", "9.0.35", "9.0.36", "9.0.37", "9.0.38", "9.0.39", "9.0.40", "9.0.41", "9.0.42", "9.0.43", "9.0.44", "9.0.45", "9.0.46", "9.0.49"}},
-	{"@operato/pull-to-refresh", []string{"9.0.36", "9.0.37", "9.0.38", "9.0.39", "9.0.40", "9.0.41", "9.0.42"}},
-	{"@operato/shell", []string{"9.0.22", "9.0.35", "9.0.36", "9.0.37", "9.0.38", "9.0.39"}},
-	{"@operato/styles", []string{"9.0.2", "9.0.35", "9.0.36", "9.0.37"}},
-	{"@operato/utils", []string{"9.0.22", "9.0.35", "9.0.36", "9.0.37", "9.0.38", "9.0.39", "9.0.40", "9.0.41", "9.0.42", "9.0.43", "9.0.44", "9.0.45", "9.0.46", "9.0.49"}},
-	{"@teselagen/bio-parsers", []string{"0.4.30"}},
-	{"@teselagen/bounce-loader", []string{"0.3.16", "0.3.17"}},
-	{"@teselagen/file-utils", []string{"0.3.22"}},
-	{"@teselagen/liquibase-tools", []string{"0.4.1"}},
-	{"@teselagen/ove", []string{"0.7.40"}},
-	{"@teselagen/range-utils", []string{"0.3.14", "0.3.15"}},
-	{"@teselagen/react-list", []string{"0.8.19", "0.8.20"}},
-	{"@teselagen/react-table", []string{"6.10.19", "6.10.20", "6.10.22"}},
-	{"@teselagen/sequence-utils", []string{"0.3.34"}},
-	{"@teselagen/ui", []string{"0.9.10"}},
-	{"@thangved/callback-window", []string{"1.1.4"}},
-	{"@things-factory/attachment-base", []string{"9.0.43", "9.0.44", "9.0.45", "9.0.46", "9.0.47", "9.0.48", "9.0.49", "9.0.50"}},
-	{"@things-factory/auth-base", []string{"9.0.43", "9.0.44", "9.0.45"}},
-	{"@things-factory/email-base", []string{"9.0.42", "9.0.43", "9.0.44", "9.0.45", "9.0.46", "9.0.47", "9.0.48", "9.0.49", "9.0.50", "9.0.51", "9.0.52", "9.0.53", "9.0.54"}},
-	{"@things-factory/env", []string{"9.0.42", "9.0.43", "9.0.44", "9.0.45"}},
-	{"@things-factory/integration-base", []string{"9.0.43", "9.0.44", "9.0.45"}},
-	{"@things-factory/integration-marketplace", []string{"9.0.43", "9.0.44", "9.0.45"}},
-	{"@things-factory/shell", []string{"9.0.43", "9.0.44", "9.0.45"}},
-	{"@tnf-dev/api", []string{"1.0.8"}},
-	{"@tnf-dev/core", []string{"1.0.8"}},
-	{"@tnf-dev/js", []string{"1.0.8"}},
-	{"@tnf-dev/mui", []string{"1.0.8"}},
-	{"@tnf-dev/react", []string{"1.0.8"}},
-	{"@ui-ux-gang/devextreme-angular-rpk", []string{"24.1.7"}},
-	{"@yoobic/design-system", []string{"6.5.17"}},
-	{"@yoobic/jpeg-camera-es6", []string{"1.0.13"}},
-	{"@yoobic/yobi", []string{"8.7.53"}},
-	{"airchief", []string{"0.3.1"}},
-	{"airpilot", []string{"0.8.8"}},
-	{"browser-webdriver-downloader", []string{"3.0.8"}},
-	{"capacitor-notificationhandler", []string{"0.0.2", "0.0.3"}},
-	{"capacitor-plugin-healthapp", []string{"0.0.2", "0.0.3"}},
-	{"capacitor-plugin-ihealth", []string{"1.1.8", "1.1.9"}},
-	{"capacitor-plugin-vonage", []string{"1.0.2", "1.0.3"}},
-	{"capacitorandroidpermissions", []string{"0.0.4", "0.0.5"}},
-	{"config-cordova", []string{"0.8.5"}},
-	{"cordova-plugin-voxeet2", []string{"1.0.24"}},
-	{"cordova-voxeet", []string{"1.0.32"}},
-	{"create-hest-app", []string{"0.1.9"}},
-	{"db-evo", []string{"1.1.4", "1.1.5"}},
-	{"devextreme-angular-rpk", []string{"21.2.8"}},
-	{"ember-browser-services", []string{"5.0.2", "5.0.3"}},
-	{"ember-headless-form-yup", []string{"1.0.1"}},
-	{"ember-headless-form", []string{"1.1.2", "1.1.3"}},
-	{"ember-headless-table", []string{"2.1.5", "2.1.6"}},
-	{"ember-url-hash-polyfill", []string{"1.0.12", "1.0.13"}},
-	{"ember-velcro", []string{"2.2.1", "2.2.2"}},
-	{"eslint-config-crowdstrike-node", []string{"4.0.3", "4.0.4"}},
-	{"eslint-config-crowdstrike", []string{"11.0.2", "11.0.3"}},
-	{"eslint-config-teselagen", []string{"6.1.7", "6.1.8"}},
-	{"globalize-rpk", []string{"1.7.4"}},
-	{"graphql-sequelize-teselagen", []string{"5.3.8", "5.3.9"}},
-	{"html-to-base64-image", []string{"1.0.2"}},
-	{"jumpgate", []string{"0.0.2"}},
-	{"mcfly-semantic-release", []string{"1.3.1"}},
-	{"mcp-knowledge-base", []string{"0.0.2"}},
-	{"mcp-knowledge-graph", []string{"1.2.1"}},
-	{"mobioffice-cli", []string{"1.0.3"}},
-	{"monorepo-next", []string{"13.0.1", "13.0.2"}},
-	{"mstate-angular", []string{"0.4.4"}},
-	{"mstate-cli", []string{"0.4.7"}},
-	{"mstate-dev-react", []string{"1.1.1"}},
-	{"mstate-react", []string{"1.6.5"}},
-	{"ng2-file-upload", []string{"7.0.2", "7.0.3", "8.0.1", "8.0.2", "8.0.3", "9.0.1"}},
-	{"ngx-bootstrap", []string{"18.1.4", "19.0.3", "19.0.4", "20.0.3", "20.0.4", "20.0.5"}},
-	{"ngx-ws", []string{"1.1.5", "1.1.6"}},
-	{"oradm-to-gql", []string{"35.0.14", "35.0.15"}},
-	{"oradm-to-sqlz", []string{"1.1.2"}},
-	{"ove-auto-annotate", []string{"0.0.9", "0.0.10"}},
-	{"pm2-gelf-json", []string{"1.0.4", "1.0.5"}},
-	{"printjs-rpk", []string{"1.6.1"}},
-	{"react-jsonschema-rxnt-extras", []string{"0.4.9"}},
-	{"remark-preset-lint-crowdstrike", []string{"4.0.1", "4.0.2"}},
-	{"tbssnch", []string{"1.0.2"}},
-	{"teselagen-interval-tree", []string{"1.1.2"}},
-	{"tg-client-query-builder", []string{"2.14.4", "2.14.5"}},
-	{"tg-redbird", []string{"1.3.1", "1.3.2"}},
-	{"tg-seq-gen", []string{"1.0.9", "1.0.10"}},
-	{"thangved-react-grid", []string{"1.0.3"}},
-	{"ts-imports", []string{"1.0.1", "1.0.2"}},
-	{"tvi-cli", []string{"0.1.5"}},
-	{"ve-bamreader", []string{"0.2.6", "0.2.7"}},
-	{"ve-editor", []string{"1.0.1", "1.0.2"}},
-	{"verror-extra", []string{"6.0.1"}},
-	{"voip-callkit", []string{"1.0.2", "1.0.3"}},
-	{"wdio-web-reporter", []string{"0.1.3"}},
-	{"yargs-help-output", []string{"5.0.3"}},
-	{"yoo-styles", []string{"6.0.326"}},
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
+// compromisedPackages is the active IOC list, populated from the IOC database
+// before scanning starts
+var compromisedPackages []CompromisedPackage
+
+// activeIOCDatabase is the IOC bundle compromisedPackages came from, recorded
+// in reports so results can be tied to a threat-intel snapshot
+var activeIOCDatabase *IOCDatabase
+
 func main() {
+	// Subcommands for managing the IOC database
+	if len(os.Args) > 1 && os.Args[1] == "ioc" {
+		os.Exit(runIOCCommand(os.Args[2:]))
+	}
+
 	config := ScanConfig{}
 
 	flag.StringVar(&config.BaseDir, "dir", ".", "Base directory to scan (default: current directory)")
@@ -267,6 +120,11 @@ func main() {
 	flag.BoolVar(&config.RepoOnly, "repo-only", false, "Only scan repository files (skip all global caches)")
 	flag.IntVar(&config.MaxWorkers, "workers", runtime.NumCPU()*2, "Number of concurrent workers")
 	flag.BoolVar(&config.Verbose, "verbose", false, "Verbose output")
+	flag.StringVar(&config.IOCFile, "ioc-file", "", "Path to a signed IOC database JSON file (default: embedded database)")
+	flag.Var(&config.IOCPubKeys, "ioc-pubkey", "Trusted Ed25519 public key (PEM file or base64) for verifying -ioc-file; repeatable")
+	flag.BoolVar(&config.AllowUnsignedIOC, "allow-unsigned-ioc", false, "Use an IOC bundle even if its signature is missing or invalid (unsafe)")
+	flag.BoolVar(&config.UpdateIOC, "ioc-update", false, "Refresh the cached IOC bundle from the feed before scanning (falls back to the last good bundle when offline)")
+	flag.BoolVar(&config.Drift, "drift", false, "Compare each project's lockfile with its installed node_modules (and node_modules/.package-lock.json) and report drift")
 	flag.Parse()
 
 	// Handle repo-only flag
@@ -288,20 +146,37 @@ func main() {
 	}
 	config.BaseDir = absPath
 
+	// Load the IOC database
+	iocDB, err := selectIOCDatabase(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error loading IOC database: %v\n", err)
+		os.Exit(1)
+	}
+	compromisedPackages = iocDB.Packages
+	activeMatcher = newIOCMatcher(compromisedPackages)
+	knownIntegrity = newIntegrityIndex(compromisedPackages)
+	knownPayloads = newPayloadIndex(iocDB.Payloads)
+	activeIOCDatabase = iocDB
+
 	fmt.Println("🔍 Scanning for compromised NPM packages...")
 	fmt.Printf("🔎 Base directory: %s\n", config.BaseDir)
 	fmt.Printf("🔧 Workers: %d\n", config.MaxWorkers)
+	fmt.Printf("🛡️  IOC database: %s, %d packages (source %s)\n", iocDB.label(), len(iocDB.Packages), iocDB.Source)
+	fmt.Printf("🔏 IOC bundle: %s\n", iocDB.provenance())
 
 	start := time.Now()
-	findings := scanForCompromisedPackages(config)
+	findings, driftSummaries := scanForCompromisedPackages(config)
 	duration := time.Since(start)
 
 	fmt.Printf("\n📊 Scan completed in %v\n", duration)
-	printResults(findings, config)
+	printResults(findings, driftSummaries, config)
 }
 
-func scanForCompromisedPackages(config ScanConfig) []Finding {
+// scanForCompromisedPackages returns the findings, and with -drift the
+// summary of each project whose node_modules has drifted from its lockfile
+func scanForCompromisedPackages(config ScanConfig) ([]Finding, []string) {
 	var findings []Finding
+	var driftSummaries []string
 	var mutex sync.Mutex
 
 	// Create worker pool
@@ -318,14 +193,31 @@ func scanForCompromisedPackages(config ScanConfig) []Finding {
 	}
 
 	addFinding := func(finding Finding) {
+		if finding.Confidence == "" {
+			finding.Confidence = confidenceHigh
+		}
 		mutex.Lock()
 		findings = append(findings, finding)
 		mutex.Unlock()
 	}
+	addDriftSummary := func(summary string) {
+		mutex.Lock()
+		driftSummaries = append(driftSummaries, summary)
+		mutex.Unlock()
+	}
 
 	// Scan repository files
 	fmt.Println("🔒 Scanning project lockfiles and package.json...")
 	scanLockfiles(config.BaseDir, jobs, &wg, addFinding, config.Verbose)
+	scanPackageManifests(config.BaseDir, jobs, &wg, addFinding, config.Verbose)
+
+	fmt.Println("📂 Scanning installed node_modules packages...")
+	scanInstalledPackages(config.BaseDir, jobs, &wg, addFinding, config.Verbose)
+
+	if config.Drift {
+		fmt.Println("🔀 Comparing lockfiles with installed node_modules...")
+		scanDrift(config.BaseDir, jobs, &wg, addFinding, addDriftSummary, config.Verbose)
+	}
 
 	fmt.Println("🐳 Scanning Dockerfiles...")
 	scanDockerfiles(config.BaseDir, jobs, &wg, addFinding, config.Verbose)
@@ -336,10 +228,19 @@ func scanForCompromisedPackages(config ScanConfig) []Finding {
 	fmt.Println("📁 Scanning vendored folders...")
 	scanVendoredDirs(config.BaseDir, jobs, &wg, addFinding, config.Verbose)
 
+	fmt.Println("📦 Scanning package tarballs and Yarn caches...")
+	scanPackageTarballs(config.BaseDir, jobs, &wg, addFinding, config.Verbose)
+	scanRepoYarnCaches(config.BaseDir, jobs, &wg, addFinding, config.Verbose)
+
+	if !knownPayloads.empty() {
+		fmt.Println("🧬 Scanning node_modules for known payload files...")
+		scanNodeModulesPayloads(config.BaseDir, jobs, &wg, addFinding, config.Verbose)
+	}
+
 	// Scan global caches if not disabled
 	if !config.NoGlobal {
 		fmt.Println("📦 Scanning global npm caches...")
-		scanGlobalCaches(jobs, &wg, addFinding, config.Verbose)
+		scanGlobalCaches(config.BaseDir, jobs, &wg, addFinding, config.Verbose)
 	}
 
 	if !config.NoNVM {
@@ -351,33 +252,41 @@ func scanForCompromisedPackages(config ScanConfig) []Finding {
 	wg.Wait()
 	close(jobs)
 
-	return findings
+	return findings, driftSummaries
 }
 
 func scanLockfiles(baseDir string, jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Finding), verbose bool) {
-	lockfilePatterns := []string{"package-lock.json", "yarn.lock", "pnpm-lock.yaml"}
 	fileCount := 0
 
-	for _, pattern := range lockfilePatterns {
-		filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil || info.IsDir() {
-				return nil
-			}
+	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
 
-			if info.Name() == pattern {
-				fileCount++
-				if verbose {
-					fmt.Printf("  📄 Found lockfile: %s\n", path)
-				}
-				wg.Add(1)
-				jobs <- func() {
-					defer wg.Done()
-					scanLockfileResolved(path, addFinding, verbose)
-				}
+		if lockfileNames[info.Name()] {
+			fileCount++
+			if verbose {
+				fmt.Printf("  📄 Found lockfile: %s\n", path)
 			}
-			return nil
-		})
-	}
+			wg.Add(1)
+			jobs <- func() {
+				defer wg.Done()
+				scanLockfileResolved(path, addFinding, verbose)
+			}
+		}
+		return nil
+	})
+}
+
+// lockfileNames are the lockfiles scanLockfileResolved can parse
+var lockfileNames = map[string]bool{
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"bun.lock":            true,
+	"bun.lockb":           true,
+	"deno.lock":           true,
 }
 
 func scanLockfileResolved(filePath string, addFinding func(Finding), verbose bool) {
@@ -391,149 +300,45 @@ func scanLockfileResolved(filePath string, addFinding func(Finding), verbose boo
 	filename := filepath.Base(filePath)
 
 	switch filename {
-	case "package-lock.json":
+	case "package-lock.json", "npm-shrinkwrap.json":
 		scanPackageLockJson(file, filePath, addFinding, verbose)
 	case "yarn.lock":
 		scanYarnLock(file, filePath, addFinding, verbose)
 	case "pnpm-lock.yaml":
 		scanPnpmLock(file, filePath, addFinding, verbose)
+	case "bun.lock":
+		scanBunLock(file, filePath, addFinding, verbose)
+	case "bun.lockb":
+		scanBunLockb(file, filePath, addFinding, verbose)
+	case "deno.lock":
+		scanDenoLock(file, filePath, addFinding, verbose)
 	}
 }
 
-func scanPackageLockJson(file *os.File, filePath string, addFinding func(Finding), verbose bool) {
-	scanner := bufio.NewScanner(file)
+// scanPackageLockLines is the fallback for package-lock.json files that do
+// not parse, matching resolved tarball URLs line by line
+func scanPackageLockLines(r io.Reader, filePath string, addFinding func(Finding), verbose bool) {
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
-
-		for _, pkg := range compromisedPackages {
-			for _, version := range pkg.Versions {
-				// Match resolved tarball URLs in package-lock.json
-				// Handle scoped packages correctly
-				packageName := pkg.Name
-				tarballName := packageName
-				if strings.HasPrefix(packageName, "@") {
-					parts := strings.Split(packageName, "/")
-					if len(parts) == 2 {
-						tarballName = parts[1] // Remove scope for tarball name
-					}
-				}
-
-				pattern := fmt.Sprintf("%s/-/%s-%s.tgz", packageName, tarballName, version)
-				if strings.Contains(line, pattern) {
-					addFinding(Finding{
-						Package: pkg.Name,
-						Version: version,
-						File:    filePath,
-						Type:    "resolved",
-					})
-					if verbose {
-						fmt.Printf("  Found resolved %s@%s in %s\n", pkg.Name, version, filePath)
-					}
-				}
-			}
-		}
-	}
-}
-
-func scanYarnLock(file *os.File, filePath string, addFinding func(Finding), verbose bool) {
-	scanner := bufio.NewScanner(file)
-	currentPackage := ""
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// Track current package context
-		for _, pkg := range compromisedPackages {
-			// Check for package declaration lines like: "package@version":
-			if strings.HasPrefix(line, "\"") && strings.Contains(line, pkg.Name) && strings.Contains(line, "@") {
-				if strings.HasSuffix(line, "\":") || strings.HasSuffix(line, "\", ") {
-					currentPackage = pkg.Name
-					// Extract version from package@version declaration
-					for _, version := range pkg.Versions {
-						if strings.Contains(line, fmt.Sprintf("%s@%s", pkg.Name, version)) {
-							addFinding(Finding{
-								Package: pkg.Name,
-								Version: version,
-								File:    filePath,
-								Type:    "resolved",
-							})
-							if verbose {
-								fmt.Printf("  Found resolved %s@%s in %s\n", pkg.Name, version, filePath)
-							}
-							break
-						}
-					}
-				}
-			}
-		}
-
-		// Check for version field when we're in the right package context
-		if currentPackage != "" && strings.HasPrefix(line, "version ") {
-			for _, pkg := range compromisedPackages {
-				if pkg.Name == currentPackage {
-					for _, version := range pkg.Versions {
-						if strings.Contains(line, fmt.Sprintf("\"%s\"", version)) {
-							addFinding(Finding{
-								Package: pkg.Name,
-								Version: version,
-								File:    filePath,
-								Type:    "resolved",
-							})
-							if verbose {
-								fmt.Printf("  Found resolved %s@%s in %s\n", pkg.Name, version, filePath)
-							}
-							break
-						}
-					}
-				}
-			}
-		}
-
-		// Reset context when we hit a new package or end of block
-		if strings.HasSuffix(line, "\":") && !strings.Contains(line, currentPackage) {
-			currentPackage = ""
-		}
-	}
-}
-
-func scanPnpmLock(file *os.File, filePath string, addFinding func(Finding), verbose bool) {
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		for _, pkg := range compromisedPackages {
-			for _, version := range pkg.Versions {
-				// pnpm-lock.yaml format patterns:
-				// In dependencies section: 'package': version or "package": "version"
-				// In packages section: /package/version: or /@scope/package/version:
-				patterns := []string{
-					// Dependencies section patterns
-					fmt.Sprintf("'%s': %s", pkg.Name, version),
-					fmt.Sprintf("\"%s\": \"%s\"", pkg.Name, version),
-					fmt.Sprintf("'%s': '%s'", pkg.Name, version),
-					fmt.Sprintf("%s: %s", pkg.Name, version),
-					// Packages section patterns
-					fmt.Sprintf("/%s/%s:", pkg.Name, version),
-					// Also check for @package@version format
-					fmt.Sprintf("%s@%s", pkg.Name, version),
-				}
-
-				for _, pattern := range patterns {
-					if strings.Contains(line, pattern) {
-						addFinding(Finding{
-							Package: pkg.Name,
-							Version: version,
-							File:    filePath,
-							Type:    "resolved",
-						})
-						if verbose {
-							fmt.Printf("  Found resolved %s@%s in %s\n", pkg.Name, version, filePath)
-						}
-						break
-					}
+		scanIntegrityLine(line, filePath, addFinding, verbose)
+
+		// Match resolved tarball URLs in package-lock.json, e.g.
+		// "@scope/name/-/name-1.2.3.tgz" for scoped packages
+		activeMatcher.findPackages(line, func(pkg *CompromisedPackage, start, end int) {
+			if version := tarballVersionAt(line, pkg, end); version != "" && pkg.matchesVersion(version) {
+				addFinding(Finding{
+					Package: pkg.Name,
+					Version: version,
+					File:    filePath,
+					Type:    "resolved",
+					IOC:     pkg,
+				})
+				if verbose {
+					fmt.Printf("  Found resolved %s@%s in %s\n", pkg.Name, version, filePath)
 				}
 			}
-		}
+		})
 	}
 }
 
@@ -607,7 +412,15 @@ func scanVendoredDirs(baseDir string, jobs chan<- func(), wg *sync.WaitGroup, ad
 				}
 
 				ext := strings.ToLower(filepath.Ext(info.Name()))
-				if ext == ".js" || ext == ".json" || ext == ".tgz" {
+				if isJSFile(info.Name()) && knownPayloads.isCandidate(info.Name(), info.Size()) {
+					wg.Add(1)
+					jobs <- func() {
+						defer wg.Done()
+						scanPayloadFile(path, addFinding, verbose)
+					}
+				}
+				// Tarballs are read as archives by scanPackageTarballs
+				if ext == ".js" || ext == ".json" {
 					fileCount++
 					if verbose && fileCount <= 5 {
 						fmt.Printf("    📄 Scanning: %s\n", path)
@@ -629,7 +442,7 @@ func scanVendoredDirs(baseDir string, jobs chan<- func(), wg *sync.WaitGroup, ad
 	}
 }
 
-func scanGlobalCaches(jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Finding), verbose bool) {
+func scanGlobalCaches(baseDir string, jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Finding), verbose bool) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		if verbose {
@@ -643,6 +456,7 @@ func scanGlobalCaches(jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Fi
 	}
 
 	yarnCache := getYarnCacheDir()
+	yarnBerryCache := getYarnBerryCacheDir()
 	pnpmStore := getPnpmStoreDir()
 
 	var cacheDirs []string
@@ -657,7 +471,7 @@ func scanGlobalCaches(jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Fi
 			filepath.Join(homeDir, ".npm", "_cacache"), // Fallback for WSL/Git Bash
 			filepath.Join(homeDir, ".npm-packages"),
 			yarnCache,
-			pnpmStore,
+			yarnBerryCache,
 		}
 	} else {
 		// Unix-like systems
@@ -665,18 +479,19 @@ func scanGlobalCaches(jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Fi
 			filepath.Join(homeDir, ".npm", "_cacache"),
 			filepath.Join(homeDir, ".npm-packages"),
 			yarnCache,
-			pnpmStore,
+			yarnBerryCache,
 		}
 	}
 
 	var cacheNames []string
 	if runtime.GOOS == "windows" {
-		cacheNames = []string{"npm APPDATA cache", "npm LOCALAPPDATA cache", "npm _cacache", "npm-packages", "yarn cache", "pnpm store"}
+		cacheNames = []string{"npm APPDATA cache", "npm LOCALAPPDATA cache", "npm _cacache", "npm-packages", "yarn cache", "yarn berry cache"}
 	} else {
-		cacheNames = []string{"npm _cacache", "npm-packages", "yarn cache", "pnpm store"}
+		cacheNames = []string{"npm _cacache", "npm-packages", "yarn cache", "yarn berry cache"}
 	}
 
 	for i, cacheDir := range cacheDirs {
+		cacheDir := cacheDir // each job needs its own copy before Go 1.22
 		if cacheDir != "" {
 			if _, err := os.Stat(cacheDir); err == nil {
 				if verbose {
@@ -690,6 +505,18 @@ func scanGlobalCaches(jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Fi
 			}
 		}
 	}
+
+	// The pnpm store names packages only in its index files, so it is
+	// read on its own rather than walked like the other caches
+	if pnpmStore != "" {
+		if _, err := os.Stat(pnpmStore); err == nil {
+			wg.Add(1)
+			jobs <- func() {
+				defer wg.Done()
+				scanPnpmStore(pnpmStore, baseDir, addFinding, verbose)
+			}
+		}
+	}
 }
 
 func scanNVMVersions(jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Finding), verbose bool) {
@@ -767,48 +594,248 @@ func scanFile(filePath string, addFinding func(Finding), verbose bool) {
 	for scanner.Scan() {
 		line := scanner.Text()
 
-		for _, pkg := range compromisedPackages {
-			if strings.Contains(line, pkg.Name) {
-				for _, version := range pkg.Versions {
-					if strings.Contains(line, version) {
-						addFinding(Finding{
-							Package: pkg.Name,
-							Version: version,
-							File:    filePath,
-							Type:    "file",
-						})
-						if verbose {
-							fmt.Printf("  Found %s@%s in %s\n", pkg.Name, version, filePath)
-						}
-					}
+		// Keep the most confident reading of each name@version on the line
+		var lineFindings []Finding
+		seen := make(map[string]int)
+		report := func(pkg *CompromisedPackage, version, confidence string) {
+			if !pkg.matchesVersion(version) {
+				return
+			}
+			key := pkg.Name + "@" + version
+			if i, ok := seen[key]; ok {
+				if confidenceRank(confidence) > confidenceRank(lineFindings[i].Confidence) {
+					lineFindings[i].Confidence = confidence
+				}
+				return
+			}
+			seen[key] = len(lineFindings)
+			lineFindings = append(lineFindings, Finding{
+				Package:    pkg.Name,
+				Version:    version,
+				File:       filePath,
+				Type:       "file",
+				IOC:        pkg,
+				Confidence: confidence,
+			})
+		}
+
+		// Names written with a version next to them are judged by that
+		// version alone; the rest fall back to any version on the line
+		var unpaired []*CompromisedPackage
+		activeMatcher.findPackages(line, func(pkg *CompromisedPackage, start, end int) {
+			if version, confidence := pairedVersion(line, pkg, end); version != "" {
+				report(pkg, version, confidence)
+			} else {
+				unpaired = append(unpaired, pkg)
+			}
+		})
+		if len(unpaired) > 0 {
+			for _, token := range versionsInLine(line) {
+				// "other@1.2.3" belongs to another package
+				if token.Start > 0 && line[token.Start-1] == '@' {
+					continue
+				}
+				for _, pkg := range unpaired {
+					report(pkg, token.Version, confidenceLow)
 				}
 			}
 		}
+
+		for _, finding := range lineFindings {
+			addFinding(finding)
+			if verbose {
+				fmt.Printf("  Found %s@%s in %s (%s confidence)\n", finding.Package, finding.Version, filePath, finding.Confidence)
+			}
+		}
+	}
+}
+
+// confidenceRank orders confidence levels, higher is more certain
+func confidenceRank(confidence string) int {
+	switch confidence {
+	case confidenceHigh:
+		return 3
+	case confidenceMedium:
+		return 2
+	case confidenceLow:
+		return 1
 	}
+	return 0
+}
+
+// pairedVersion returns the version written right after a package name
+// ending at s[end], with how certainly it belongs to the name
+func pairedVersion(s string, pkg *CompromisedPackage, end int) (string, string) {
+	rest := s[end:]
+
+	// name@1.2.3 and pnpm-style name/1.2.3
+	if strings.HasPrefix(rest, "@") || strings.HasPrefix(rest, "/") {
+		if version := boundedVersionAt(s, end+1); version != "" {
+			return version, confidenceHigh
+		}
+	}
+
+	if version := tarballVersionAt(s, pkg, end); version != "" {
+		return version, confidenceHigh
+	}
+
+	// "name": "1.2.3" as written in package.json and lockfiles
+	if len(rest) > 1 && (rest[0] == '"' || rest[0] == '\'') && rest[1] == ':' {
+		i := end + 2
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+			quote := s[i]
+			version := boundedVersionAt(s, i+1)
+			if after := i + 1 + len(version); version != "" && after < len(s) && s[after] == quote {
+				return version, confidenceHigh
+			}
+		}
+	}
+
+	// Only separators in between: name 1.2.3, name@^1.2.3, "name": "~1.2.3"
+	for i := end; i < len(s) && i-end <= 5; i++ {
+		if isDigit(s[i]) {
+			if version := boundedVersionAt(s, i); version != "" && i > end {
+				return version, confidenceMedium
+			}
+			break
+		}
+		if !strings.ContainsRune(" \t\"':=,@^~v", rune(s[i])) {
+			break
+		}
+	}
+	return "", ""
+}
+
+// tarballVersionAt returns the version of a registry tarball path such as
+// "name/-/name-1.2.3.tgz" or "@scope/name/-/name-1.2.3.tgz" whose package
+// name ends at s[end]
+func tarballVersionAt(s string, pkg *CompromisedPackage, end int) string {
+	tarballName := pkg.Name
+	if slash := strings.IndexByte(tarballName, '/'); slash >= 0 {
+		tarballName = tarballName[slash+1:] // Remove scope for tarball name
+	}
+	tarball := "/-/" + tarballName + "-"
+	if !strings.HasPrefix(s[end:], tarball) {
+		return ""
+	}
+	return strings.TrimSuffix(boundedVersionAt(s, end+len(tarball)), ".tgz")
+}
+
+// boundedVersionAt is extractVersionAt that rejects the start of a longer
+// dotted number, so "5.6.1" is not read out of "5.6.10" or "5.6.1.2"
+func boundedVersionAt(s string, i int) string {
+	version := extractVersionAt(s, i)
+	if version == "" {
+		return ""
+	}
+	if end := i + len(version); end < len(s) {
+		if isDigit(s[end]) || s[end] == '.' && end+1 < len(s) && isDigit(s[end+1]) {
+			return ""
+		}
+	}
+	return version
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// versionToken is a version found in a line and where it starts
+type versionToken struct {
+	Version string
+	Start   int
+}
+
+// versionsInLine returns every standalone version token in line
+func versionsInLine(line string) []versionToken {
+	var versions []versionToken
+	for i := 0; i < len(line); i++ {
+		if !isDigit(line[i]) {
+			continue
+		}
+		if i > 0 && (isDigit(line[i-1]) || line[i-1] == '.') {
+			continue
+		}
+		if version := boundedVersionAt(line, i); version != "" {
+			versions = append(versions, versionToken{Version: version, Start: i})
+			i += len(version) - 1
+		}
+	}
+	return versions
 }
 
 func scanCacheDir(cacheDir string, addFinding func(Finding), verbose bool) {
+	// Content npm's _cacache index already resolved to a package
+	indexed := make(map[string]bool)
 	filepath.Walk(cacheDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
+		if err != nil {
 			return nil
 		}
-
-		for _, pkg := range compromisedPackages {
-			for _, version := range pkg.Versions {
-				pattern := fmt.Sprintf("%s@%s", pkg.Name, version)
-				if strings.Contains(path, pattern) {
-					addFinding(Finding{
-						Package: pkg.Name,
-						Version: version,
-						File:    path,
-						Type:    "cache",
-					})
-					if verbose {
-						fmt.Printf("  Found %s@%s in cache: %s\n", pkg.Name, version, path)
-					}
+		if info.IsDir() {
+			// Index bucket paths are hashes, so they are read, not matched;
+			// the walk is lexical, so a cache folder is seen before its
+			// content-v2
+			if info.Name() == cacacheIndexDir {
+				return filepath.SkipDir
+			}
+			if index, err := os.Stat(filepath.Join(path, cacacheIndexDir)); err == nil && index.IsDir() {
+				for file := range scanCacacheIndex(path, addFinding, verbose) {
+					indexed[file] = true
 				}
 			}
+			// Yarn classic entries are walked on for payload files
+			scanYarnClassicCacheEntry(path, addFinding, verbose)
+			return nil
 		}
+		if indexed[path] {
+			return nil
+		}
+
+		// Yarn classic keeps each entry's tarball next to its unpacked files,
+		// which were already identified with the entry
+		if info.Name() == ".yarn-tarball.tgz" {
+			return nil
+		}
+		// Cached tarballs, including content-addressed blobs without an
+		// extension, are identified by the package.json inside them
+		if isTarballName(info.Name()) || (strings.Contains(filepath.ToSlash(path), "/content-v2/") && isGzipFile(path)) {
+			if scanTarball(path, "cache", addFinding, verbose) {
+				return nil
+			}
+			if isTarballName(info.Name()) {
+				scanYarnMirrorName(path, addFinding, verbose)
+				return nil
+			}
+		}
+		if scanYarnBerryCacheArchive(path, addFinding, verbose) {
+			return nil
+		}
+
+		// Extracted cache contents may hold a known payload under any name
+		if knownPayloads.isCandidate(info.Name(), info.Size()) {
+			scanPayloadFile(path, addFinding, verbose)
+		}
+
+		activeMatcher.findPackages(path, func(pkg *CompromisedPackage, start, end int) {
+			if end >= len(path) || path[end] != '@' {
+				return
+			}
+			if version := boundedVersionAt(path, end+1); version != "" && pkg.matchesVersion(version) {
+				addFinding(Finding{
+					Package: pkg.Name,
+					Version: version,
+					File:    path,
+					Type:    "cache",
+					IOC:     pkg,
+				})
+				if verbose {
+					fmt.Printf("  Found %s@%s in cache: %s\n", pkg.Name, version, path)
+				}
+			}
+		})
 		return nil
 	})
 }
@@ -828,11 +855,41 @@ func getPnpmStoreDir() string {
 	cmd := exec.Command("pnpm", "store", "path")
 	output, err := cmd.Output()
 	if err != nil {
-		return ""
+		return defaultPnpmStoreDir()
 	}
 	return strings.TrimSpace(string(output))
 }
 
+// defaultPnpmStoreDir returns pnpm's default store folder, for when pnpm
+// itself is not on the PATH, or "" if there is none
+func defaultPnpmStoreDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	var candidates []string
+	switch runtime.GOOS {
+	case "windows":
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			candidates = []string{filepath.Join(local, "pnpm", "store")}
+		}
+	case "darwin":
+		candidates = []string{filepath.Join(homeDir, "Library", "pnpm", "store")}
+	default:
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(homeDir, ".local", "share")
+		}
+		candidates = []string{filepath.Join(dataHome, "pnpm", "store")}
+	}
+	for _, dir := range candidates {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
 // isRoot checks if a path is a root directory (cross-platform)
 func isRoot(path string) bool {
 	if runtime.GOOS == "windows" {
@@ -848,9 +905,15 @@ func isRoot(path string) bool {
 	return path == "/"
 }
 
-func printResults(findings []Finding, config ScanConfig) {
+func printResults(findings []Finding, driftSummaries []string, config ScanConfig) {
 	fmt.Println("\n📊 Summary of Findings:")
 
+	// Drift is worth fixing even when no drifted version is compromised
+	sort.Strings(driftSummaries)
+	for _, summary := range driftSummaries {
+		fmt.Printf("🔀 %s\n", summary)
+	}
+
 	if len(findings) == 0 {
 		fmt.Println("✅ No compromised packages found.")
 		return
@@ -858,16 +921,25 @@ func printResults(findings []Finding, config ScanConfig) {
 
 	// Group findings by project directory and package manager
 	type findingDetail struct {
-		Package string
-		Version string
-		File    string
-		Type    string
-		Line    int // Not used yet, but can be extended
+		Package    string
+		Version    string
+		Alias      string
+		File       string
+		Location   string
+		Paths      []string
+		Dependents []string
+		Type       string
+		Campaign   string
+		Confidence string
+		Line       int // Not used yet, but can be extended
 	}
 
 	projectGroups := make(map[string][]findingDetail)
 	projectTools := make(map[string]string)
+	projectRepos := make(map[string]*monorepo)
 	findingTypes := make(map[string]map[string]int)
+	confidenceCounts := make(map[string]int)
+	typeCounts := make(map[string]int)
 
 	for _, finding := range findings {
 		dir := filepath.Dir(finding.File)
@@ -878,29 +950,48 @@ func printResults(findings []Finding, config ScanConfig) {
 			dir = strings.TrimSuffix(dir, "/node_modules")
 		}
 		projectRoot := dir
+		tool := ""
 		for !isRoot(projectRoot) && projectRoot != "." {
-			if _, err := os.Stat(filepath.Join(projectRoot, "package-lock.json")); err == nil {
-				projectTools[projectRoot] = "npm"
-				break
-			} else if _, err := os.Stat(filepath.Join(projectRoot, "yarn.lock")); err == nil {
-				projectTools[projectRoot] = "yarn"
-				break
-			} else if _, err := os.Stat(filepath.Join(projectRoot, "pnpm-lock.yaml")); err == nil {
-				projectTools[projectRoot] = "pnpm"
-				break
-			} else if _, err := os.Stat(filepath.Join(projectRoot, "package.json")); err == nil {
-				projectTools[projectRoot] = "unknown"
+			if t, ok := projectTool(projectRoot); ok {
+				tool = t
 				break
 			}
 			projectRoot = filepath.Dir(projectRoot)
 		}
+
+		// Workspace packages roll up to their monorepo, and the finding is
+		// attributed to the packages that depend on it
+		dependents := finding.Dependents
+		if repo := enclosingMonorepo(projectRoot); repo != nil {
+			if repo.Root != projectRoot {
+				projectRoot = repo.Root
+				tool, _ = projectTool(projectRoot)
+			}
+			projectRepos[projectRoot] = repo
+			if len(dependents) == 0 {
+				if pkg, ok := repo.packageFor(filepath.Dir(finding.File)); ok {
+					dependents = []string{pkg.Name}
+				}
+			}
+		}
+		if tool != "" {
+			projectTools[projectRoot] = tool
+		}
 		projectGroups[projectRoot] = append(projectGroups[projectRoot], findingDetail{
-			Package: finding.Package,
-			Version: finding.Version,
-			File:    finding.File,
-			Type:    finding.Type,
-			Line:    0, // Not tracked yet
+			Package:    finding.Package,
+			Version:    finding.Version,
+			Alias:      finding.Alias,
+			File:       finding.File,
+			Location:   finding.Location,
+			Paths:      finding.Paths,
+			Dependents: dependents,
+			Type:       finding.Type,
+			Campaign:   findingCampaignID(finding),
+			Confidence: finding.Confidence,
+			Line:       0, // Not tracked yet
 		})
+		confidenceCounts[finding.Confidence]++
+		typeCounts[finding.Type]++
 		if _, exists := findingTypes[projectRoot]; !exists {
 			findingTypes[projectRoot] = make(map[string]int)
 		}
@@ -925,12 +1016,73 @@ func printResults(findings []Finding, config ScanConfig) {
 		if types["resolved"] > 0 {
 			reportLines = append(reportLines, fmt.Sprintf("   📋 Lockfile references: %d", types["resolved"]))
 		}
+		if types["installed"] > 0 {
+			reportLines = append(reportLines, fmt.Sprintf("   📂 Installed in node_modules: %d", types["installed"]))
+		}
+		if types["drift"] > 0 {
+			reportLines = append(reportLines, fmt.Sprintf("   🔀 Drift between lockfile and node_modules: %d", types["drift"]))
+		}
+		if types["exposure"] > 0 {
+			reportLines = append(reportLines, fmt.Sprintf("   🧭 package.json ranges admitting a compromised version: %d", types["exposure"]))
+		}
+		if types["integrity"] > 0 {
+			reportLines = append(reportLines, fmt.Sprintf("   🔐 Known-bad tarball integrity: %d", types["integrity"]))
+		}
 		if types["file"] > 0 {
 			reportLines = append(reportLines, fmt.Sprintf("   📄 File references: %d", types["file"]))
 		}
+		if types["tarball"] > 0 {
+			reportLines = append(reportLines, fmt.Sprintf("   📦 Package tarballs: %d", types["tarball"]))
+		}
 		if types["cache"] > 0 {
 			reportLines = append(reportLines, fmt.Sprintf("   💾 Cache entries: %d", types["cache"]))
 		}
+		if types["payload"] > 0 {
+			reportLines = append(reportLines, fmt.Sprintf("   🧬 Known payload files: %d", types["payload"]))
+		}
+
+		// Show which workspace packages depend on which compromised versions
+		if repo := projectRepos[projectRoot]; repo != nil {
+			noun := "workspace packages"
+			if len(repo.Packages) == 1 {
+				noun = "workspace package"
+			}
+			reportLines = append(reportLines, fmt.Sprintf("   🧩 Monorepo: %d %s (%s)", len(repo.Packages), noun, strings.Join(repo.Sources, ", ")))
+			rootName := projectLabel(repo.Root)
+			affected := make(map[string]map[string]bool) // workspace -> pkg@version
+			for _, d := range projectFindings {
+				dependents := d.Dependents
+				if len(dependents) == 0 {
+					dependents = []string{rootName}
+				}
+				for _, name := range dependents {
+					if affected[name] == nil {
+						affected[name] = make(map[string]bool)
+					}
+					affected[name][d.Package+"@"+d.Version] = true
+				}
+			}
+			reportLines = append(reportLines, "   🧩 Affected workspace packages:")
+			names := make([]string, 0, len(affected))
+			for name := range affected {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				ids := make([]string, 0, len(affected[name]))
+				for id := range affected[name] {
+					ids = append(ids, id)
+				}
+				sort.Strings(ids)
+				label := name
+				if name == rootName {
+					label += " (root)"
+				} else if dir, ok := repo.packageDir(name); ok {
+					label += " (" + dir + ")"
+				}
+				reportLines = append(reportLines, fmt.Sprintf("      • %s: %s", label, strings.Join(ids, ", ")))
+			}
+		}
 
 		// List affected packages with locations
 		reportLines = append(reportLines, "   📋 Affected packages:")
@@ -948,17 +1100,48 @@ func printResults(findings []Finding, config ScanConfig) {
 					if d.Line > 0 {
 						loc = fmt.Sprintf("%s:%d", d.File, d.Line)
 					}
-					reportLines = append(reportLines, fmt.Sprintf("      • %s@%s in %s [%s]", pkg, version, loc, d.Type))
+					if d.Location != "" {
+						loc += " at " + d.Location
+					}
+					tag := d.Type
+					if d.Confidence != confidenceHigh {
+						tag += ", " + d.Confidence + " confidence"
+					}
+					identity := pkg + "@" + version
+					if d.Alias != "" {
+						identity = fmt.Sprintf("%s (as %s)", identity, d.Alias)
+					}
+					line := fmt.Sprintf("      • %s in %s [%s]", identity, loc, tag)
+					if d.Campaign != "" {
+						line += fmt.Sprintf(" (campaign: %s)", d.Campaign)
+					}
+					reportLines = append(reportLines, line)
+					// Dependency chains that pulled it in, shortest first
+					for _, path := range d.Paths {
+						reportLines = append(reportLines, "          ↳ "+path)
+					}
 				}
 			}
 		}
 		reportLines = append(reportLines, "")
 	}
 
+	// Explain hits by campaign, since remediation differs per wave
+	reportLines = append(reportLines, campaignReportLines(findings)...)
+
+	if len(driftSummaries) > 0 {
+		reportLines = append(reportLines, "🔀 Drift between lockfiles and node_modules:")
+		for _, summary := range driftSummaries {
+			reportLines = append(reportLines, "   • "+summary)
+		}
+		reportLines = append(reportLines, "")
+	}
+
 	// Final summary
 	reportLines = append(reportLines, "📋 Final Report:")
 	reportLines = append(reportLines, fmt.Sprintf("   Total compromised references: %d", len(findings)))
 	reportLines = append(reportLines, fmt.Sprintf("   Affected projects: %d", len(projectGroups)))
+	reportLines = append(reportLines, fmt.Sprintf("   By confidence: %d high, %d medium, %d low", confidenceCounts[confidenceHigh], confidenceCounts[confidenceMedium], confidenceCounts[confidenceLow]))
 
 	// Count by package manager
 	toolCounts := make(map[string]int)
@@ -987,7 +1170,11 @@ func printResults(findings []Finding, config ScanConfig) {
 	// Add timestamp to file report
 	f.WriteString("NPM Supply Chain Scan Report\n")
 	f.WriteString(fmt.Sprintf("Generated: %s\n", time.Now().Format("2006-01-02 15:04:05 MST")))
-	f.WriteString(fmt.Sprintf("Scan Directory: %s\n\n", config.BaseDir))
+	f.WriteString(fmt.Sprintf("Scan Directory: %s\n", config.BaseDir))
+	if activeIOCDatabase != nil {
+		f.WriteString(fmt.Sprintf("IOC Bundle: %s (%s)\n", activeIOCDatabase.label(), activeIOCDatabase.provenance()))
+	}
+	f.WriteString("\n")
 	f.WriteString(strings.Repeat("=", 80) + "\n\n")
 
 	for _, line := range reportLines {
@@ -1011,5 +1198,163 @@ func printResults(findings []Finding, config ScanConfig) {
 		fmt.Println()
 	}
 
+	if exposed := typeCounts["exposure"]; exposed > 0 {
+		fmt.Printf("🧭 %d package.json ranges would admit a compromised version on a fresh install; pin them before regenerating lockfiles\n", exposed)
+	}
+
+	if drifted := typeCounts["drift"]; drifted > 0 {
+		fmt.Printf("🔀 Compromised versions in only one of a lockfile and its node_modules: %d; fix the lockfile, then reinstall from it (npm ci)\n", drifted)
+	}
+
+	if heuristic := confidenceCounts[confidenceMedium] + confidenceCounts[confidenceLow]; heuristic > 0 {
+		fmt.Printf("⚠️  %d references are heuristic (%d medium, %d low confidence), verify them in the report\n", heuristic, confidenceCounts[confidenceMedium], confidenceCounts[confidenceLow])
+	}
+
+	// Campaign summary for console
+	for _, summary := range summarizeCampaigns(findings) {
+		severity := summary.Meta.Severity
+		if severity == "" {
+			severity = "unrated"
+		}
+		fmt.Printf("🎯 %s [%s]: %d references\n", summary.title(), severity, summary.Count)
+	}
+
 	fmt.Printf("\n📝 Full detailed report written to %s\n", reportPath)
 }
+
+// projectTool returns the package manager whose lockfile is in dir, or
+// "unknown" for a package.json without one
+func projectTool(dir string) (string, bool) {
+	for _, lockfile := range []struct{ name, tool string }{
+		{"package-lock.json", "npm"},
+		{"npm-shrinkwrap.json", "npm"},
+		{"yarn.lock", "yarn"},
+		{"pnpm-lock.yaml", "pnpm"},
+		{"bun.lock", "bun"},
+		{"bun.lockb", "bun"},
+		{"deno.lock", "deno"},
+		{"package.json", "unknown"},
+	} {
+		if _, err := os.Stat(filepath.Join(dir, lockfile.name)); err == nil {
+			return lockfile.tool, true
+		}
+	}
+	return "", false
+}
+
+// findingCampaignID returns the campaign ID of the IOC behind a finding
+func findingCampaignID(finding Finding) string {
+	meta, _, _ := findingMetadata(finding)
+	return meta.ID
+}
+
+// findingMetadata returns the campaign details of the IOC behind a finding
+// and a label for IOCs without a campaign
+func findingMetadata(finding Finding) (IOCCampaign, string, bool) {
+	switch {
+	case finding.IOC != nil:
+		return finding.IOC.metadata(), finding.IOC.Name, true
+	case finding.Payload != nil:
+		label := "payload " + finding.Payload.SHA256[:12]
+		if finding.Payload.Description != "" {
+			label = fmt.Sprintf("%s (%s)", finding.Payload.Description, finding.Payload.SHA256[:12])
+		}
+		return finding.Payload.metadata(), label, true
+	}
+	return IOCCampaign{}, "", false
+}
+
+// campaignSummary aggregates the findings attributed to one campaign
+type campaignSummary struct {
+	Meta     IOCCampaign
+	Label    string // IOC name for IOCs without a campaign
+	Count    int
+	Packages map[string]bool
+}
+
+func (c *campaignSummary) title() string {
+	switch {
+	case c.Meta.ID == "":
+		return fmt.Sprintf("%s (no campaign)", c.Label)
+	case c.Meta.Name == "":
+		return c.Meta.ID
+	}
+	return fmt.Sprintf("%s (%s)", c.Meta.Name, c.Meta.ID)
+}
+
+// summarizeCampaigns groups findings by campaign, most severe first
+func summarizeCampaigns(findings []Finding) []*campaignSummary {
+	byID := make(map[string]*campaignSummary)
+	var summaries []*campaignSummary
+
+	for _, finding := range findings {
+		meta, label, ok := findingMetadata(finding)
+		if !ok {
+			continue
+		}
+		key := meta.ID
+		if key == "" {
+			// IOCs without a campaign are explained by their own details
+			key = "ioc:" + label
+		}
+		summary, ok := byID[key]
+		if !ok {
+			summary = &campaignSummary{Meta: meta, Label: label, Packages: make(map[string]bool)}
+			byID[key] = summary
+			summaries = append(summaries, summary)
+		}
+		summary.Count++
+		summary.Packages[fmt.Sprintf("%s@%s", finding.Package, finding.Version)] = true
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		ri, rj := severityRank(summaries[i].Meta.Severity), severityRank(summaries[j].Meta.Severity)
+		if ri < 0 {
+			ri = len(iocSeverities)
+		}
+		if rj < 0 {
+			rj = len(iocSeverities)
+		}
+		if ri != rj {
+			return ri < rj
+		}
+		return summaries[i].Count > summaries[j].Count
+	})
+	return summaries
+}
+
+// campaignReportLines renders the per-campaign section of the report
+func campaignReportLines(findings []Finding) []string {
+	summaries := summarizeCampaigns(findings)
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	lines := []string{"🎯 Findings by Campaign:"}
+	for _, summary := range summaries {
+		lines = append(lines, fmt.Sprintf("   🦠 %s", summary.title()))
+		if summary.Meta.Severity != "" {
+			lines = append(lines, fmt.Sprintf("      Severity: %s", summary.Meta.Severity))
+		}
+		if summary.Meta.FirstSeen != "" {
+			lines = append(lines, fmt.Sprintf("      First seen: %s", summary.Meta.FirstSeen))
+		}
+		lines = append(lines, fmt.Sprintf("      References: %d", summary.Count))
+
+		var packages []string
+		for pkg := range summary.Packages {
+			packages = append(packages, pkg)
+		}
+		sort.Strings(packages)
+		lines = append(lines, fmt.Sprintf("      Packages: %s", strings.Join(packages, ", ")))
+
+		for _, advisory := range summary.Meta.Advisories {
+			lines = append(lines, fmt.Sprintf("      Advisory: %s", advisory))
+		}
+		if summary.Meta.Remediation != "" {
+			lines = append(lines, fmt.Sprintf("      Remediation: %s", summary.Meta.Remediation))
+		}
+	}
+	lines = append(lines, "")
+	return lines
+}
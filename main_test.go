@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVersionsInLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []versionToken
+	}{
+		{`"chalk": "5.6.1",`, []versionToken{{"5.6.1", 10}}},
+		{`chalk@5.6.1 debug@4.4.2-beta.1`, []versionToken{{"5.6.1", 6}, {"4.4.2-beta.1", 18}}},
+		{`"chalk": "15.6.10"`, []versionToken{{"15.6.10", 10}}},
+		// Dotted numbers longer than a version are not versions
+		{`node 20.11 or 1.2.3.4`, nil},
+		{`no versions here`, nil},
+	}
+	for _, tt := range tests {
+		if got := versionsInLine(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("versionsInLine(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestPairedVersion(t *testing.T) {
+	chalk := &CompromisedPackage{Name: "chalk"}
+	tinycolor := &CompromisedPackage{Name: "@ctrl/tinycolor"}
+	tests := []struct {
+		s              string
+		pkg            *CompromisedPackage
+		wantVersion    string
+		wantConfidence string
+	}{
+		{"chalk@5.6.1", chalk, "5.6.1", confidenceHigh},
+		{"/chalk/5.6.1:", chalk, "5.6.1", confidenceHigh},
+		{"chalk/-/chalk-5.6.1.tgz", chalk, "5.6.1", confidenceHigh},
+		{"@ctrl/tinycolor/-/tinycolor-4.1.1.tgz", tinycolor, "4.1.1", confidenceHigh},
+		{`"chalk": "5.6.1",`, chalk, "5.6.1", confidenceHigh},
+		{`'chalk': '5.6.1'`, chalk, "5.6.1", confidenceHigh},
+		{`"chalk": "^5.6.1",`, chalk, "5.6.1", confidenceMedium},
+		{"chalk@~5.6.1", chalk, "5.6.1", confidenceMedium},
+		{"chalk 5.6.1", chalk, "5.6.1", confidenceMedium},
+		{"chalk=v5.6.1", chalk, "5.6.1", confidenceMedium},
+		// The whole version is read, never a prefix or suffix of it
+		{"chalk@15.6.10", chalk, "15.6.10", confidenceHigh},
+		{"chalk@5.6.10", chalk, "5.6.10", confidenceHigh},
+		{`"chalk": "5.6.1.2"`, chalk, "", ""},
+		// Too far away, or with something other than separators in between
+		{"chalk        5.6.1", chalk, "", ""},
+		{"chalk and 5.6.1", chalk, "", ""},
+		{"chalk", chalk, "", ""},
+	}
+	for _, tt := range tests {
+		start := strings.Index(tt.s, tt.pkg.Name)
+		version, confidence := pairedVersion(tt.s, tt.pkg, start+len(tt.pkg.Name))
+		if version != tt.wantVersion || confidence != tt.wantConfidence {
+			t.Errorf("pairedVersion(%q) = %q, %q, want %q, %q", tt.s, version, confidence, tt.wantVersion, tt.wantConfidence)
+		}
+	}
+}
+
+func TestScanFileConfidence(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+	)
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"paired", "RUN npm i chalk@5.6.1", []string{"chalk@5.6.1 high"}},
+		{"separated", `    "chalk": "^5.6.1",`, []string{"chalk@5.6.1 medium"}},
+		{"longer version", "RUN npm i chalk@15.6.10", nil},
+		{"longer version, separated", "chalk 15.6.10", nil},
+		// An unrelated package's version sharing the line is a weak hint only
+		{"unrelated version", "RUN npm i chalk && apt-get install -y curl=5.6.1", []string{"chalk@5.6.1 low"}},
+		// ...unless it is visibly paired with that other package
+		{"other package's version", "RUN npm i chalk express@5.6.1", nil},
+		// A paired version is the only one considered for its name
+		{"paired elsewhere", "RUN npm i chalk@5.6.0 debug@4.4.2 # was 5.6.1", []string{"debug@4.4.2 high"}},
+		{"strongest reading kept", "chalk@5.6.1 chalk 5.6.1", []string{"chalk@5.6.1 high"}},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("Dockerfile.%d", i))
+		if err := os.WriteFile(path, []byte(tt.line+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		scanFile(path, func(f Finding) { got = append(got, f.Package+"@"+f.Version+" "+f.Confidence) }, false)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %q gave %q, want %q", tt.name, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestScannersMatchParsedVersions(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "@operato/input", Versions: []string{">=9.0.35 <=9.0.48"}},
+	)
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// A version containing the compromised one is not a match; a version
+	// inside a range is
+	tests := []struct {
+		name  string
+		scan  func(path string, addFinding func(Finding))
+		file  string
+		body  string
+		found []string
+	}{
+		{"scanFile", func(path string, add func(Finding)) { scanFile(path, add, false) },
+			"Dockerfile", "RUN npm i chalk@15.6.10 @operato/input@9.0.40\n", []string{"@operato/input@9.0.40"}},
+		{"scanPackageLockJson", func(path string, add func(Finding)) {
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			scanPackageLockJson(file, path, add, false)
+		}, "package-lock.json", `"resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.10.tgz",
+"resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz",
+"resolved": "https://registry.npmjs.org/@operato/input/-/input-9.0.49.tgz",
+`, []string{"chalk@5.6.1"}},
+	}
+	for _, tt := range tests {
+		path := write(tt.file, tt.body)
+		var found []string
+		tt.scan(path, func(f Finding) { found = append(found, f.Package+"@"+f.Version) })
+		if !reflect.DeepEqual(found, tt.found) {
+			t.Errorf("%s: found %q, want %q", tt.name, found, tt.found)
+		}
+	}
+}
+
+func TestScanCacheDirMatchesPathVersions(t *testing.T) {
+	setTestIOCs(t, CompromisedPackage{Name: "chalk", Versions: []string{"^5.6.1"}})
+	dir := t.TempDir()
+	for _, name := range []string{"chalk@5.6.1", "chalk@5.6.2", "chalk@5.3.0", "chalk@15.6.1"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "index.js"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var found []string
+	scanCacheDir(dir, func(f Finding) { found = append(found, f.Version) }, false)
+	if want := []string{"5.6.1", "5.6.2"}; !reflect.DeepEqual(found, want) {
+		t.Errorf("found %q, want %q", found, want)
+	}
+}
+
+func TestSummarizeCampaigns(t *testing.T) {
+	worm := &IOCCampaign{ID: "shai-hulud", Severity: "critical"}
+	drainer := &IOCCampaign{ID: "chalk-debug", Name: "chalk/debug crypto-drainer", Severity: "high"}
+	iocs := []*CompromisedPackage{
+		{Name: "@ctrl/tinycolor", Campaign: "shai-hulud", campaign: worm},
+		{Name: "chalk", Campaign: "chalk-debug", campaign: drainer},
+		{Name: "debug", Campaign: "chalk-debug", campaign: drainer},
+		{Name: "left-pad"},
+	}
+	findings := []Finding{
+		{Package: "chalk", Version: "5.6.1", IOC: iocs[1]},
+		{Package: "debug", Version: "4.4.2", IOC: iocs[2]},
+		{Package: "debug", Version: "4.4.2", IOC: iocs[2]},
+		{Package: "left-pad", Version: "1.3.1", IOC: iocs[3]},
+		{Package: "@ctrl/tinycolor", Version: "4.1.1", IOC: iocs[0]},
+		{Package: "unattributed", Version: "1.0.0"},
+	}
+
+	// Most severe first; entries without a campaign stand on their own
+	var got []string
+	for _, summary := range summarizeCampaigns(findings) {
+		got = append(got, fmt.Sprintf("%s: %d refs, %d packages", summary.title(), summary.Count, len(summary.Packages)))
+	}
+	want := []string{
+		"shai-hulud: 1 refs, 1 packages",
+		"chalk/debug crypto-drainer (chalk-debug): 3 refs, 2 packages",
+		"left-pad (no campaign): 1 refs, 1 packages",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// manifestDependencySections are the package.json fields whose ranges a
+// fresh install resolves
+var manifestDependencySections = []string{"dependencies", "devDependencies", "optionalDependencies", "peerDependencies"}
+
+// declaredDependency is a dependency range written in a package.json
+type declaredDependency struct {
+	Section string // "dependencies", "overrides", "resolutions", ...
+	Key     string // the key as written, e.g. "**/debug" in resolutions
+	Name    string
+	Spec    string
+}
+
+func scanPackageManifests(baseDir string, jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Finding), verbose bool) {
+	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		// Installed packages are not the project's own declarations
+		if info.IsDir() {
+			if info.Name() == "node_modules" || info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() == "package.json" {
+			wg.Add(1)
+			jobs <- func() {
+				defer wg.Done()
+				scanPackageManifest(path, addFinding, verbose)
+			}
+		}
+		return nil
+	})
+}
+
+// scanPackageManifest reports declared ranges that admit a compromised
+// version, so the next install without a lockfile could pull it in
+func scanPackageManifest(filePath string, addFinding func(Finding), verbose bool) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+	deps, err := parseManifestDependencies(data)
+	if err != nil {
+		if verbose {
+			fmt.Printf("  ⚠️  Could not parse %s (%v)\n", filePath, err)
+		}
+		return
+	}
+
+	for _, dep := range deps {
+		name, spec, alias := dep.Name, dep.Spec, ""
+		if realName, rng, ok := parseNPMAlias(spec); ok {
+			name, spec, alias = realName, rng, dep.Name
+		}
+		pkg := activeMatcher.lookup(name)
+		if pkg == nil || !isRegistryRange(spec) {
+			continue
+		}
+		declared, err := parseSemVerRange(spec)
+		if err != nil {
+			// Dist-tags such as "latest" are not ranges
+			continue
+		}
+		admitted := pkg.admittedVersions(declared)
+		if len(admitted) == 0 {
+			continue
+		}
+
+		location := dep.Section
+		if dep.Key != dep.Name {
+			location += " " + dep.Key
+		}
+		location += ", admits " + strings.Join(admitted, ", ")
+		addFinding(Finding{
+			Package:  pkg.Name,
+			Version:  spec,
+			Alias:    alias,
+			File:     filePath,
+			Location: location,
+			Type:     "exposure",
+			IOC:      pkg,
+		})
+		if verbose {
+			fmt.Printf("  Found exposed range %s@%s (%s) in %s\n", pkg.Name, spec, location, filePath)
+		}
+	}
+}
+
+// isRegistryRange reports whether a dependency spec is a semver range
+// resolved from the registry, rather than a workspace, file, git, URL or
+// GitHub shorthand reference, or an override pointing at "$dependency"
+func isRegistryRange(spec string) bool {
+	return !strings.ContainsAny(spec, ":/$")
+}
+
+// parseManifestDependencies returns every dependency range a package.json
+// declares, including npm overrides, Yarn resolutions and pnpm overrides
+func parseManifestDependencies(data []byte) ([]declaredDependency, error) {
+	var manifest map[string]json.RawMessage
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	var deps []declaredDependency
+	for _, section := range manifestDependencySections {
+		specs := decodeStringMap(manifest[section])
+		for _, name := range sortedKeys(specs) {
+			deps = append(deps, declaredDependency{Section: section, Key: name, Name: name, Spec: specs[name]})
+		}
+	}
+
+	// npm overrides nest by parent package; "." sets the parent's own
+	// version: {"foo": {".": "1.0.0", "debug": "4.4.1"}}
+	var walkOverrides func(raw json.RawMessage, path []string)
+	walkOverrides = func(raw json.RawMessage, path []string) {
+		var nested map[string]json.RawMessage
+		if json.Unmarshal(raw, &nested) != nil {
+			return
+		}
+		keys := make([]string, 0, len(nested))
+		for key := range nested {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := path
+			if key != "." {
+				keyPath = append(append([]string{}, path...), key)
+			}
+			var spec string
+			if json.Unmarshal(nested[key], &spec) != nil {
+				walkOverrides(nested[key], keyPath)
+				continue
+			}
+			if len(keyPath) == 0 {
+				continue
+			}
+			target := keyPath[len(keyPath)-1]
+			deps = append(deps, declaredDependency{
+				Section: "overrides",
+				Key:     strings.Join(keyPath, " > "),
+				Name:    yarnSpecName(target),
+				Spec:    spec,
+			})
+		}
+	}
+	walkOverrides(manifest["overrides"], nil)
+
+	// Yarn resolutions target a package by path: "**/debug", "foo/@scope/bar"
+	resolutions := decodeStringMap(manifest["resolutions"])
+	for _, key := range sortedKeys(resolutions) {
+		segments := strings.Split(key, "/")
+		target := segments[len(segments)-1]
+		if len(segments) > 1 && strings.HasPrefix(segments[len(segments)-2], "@") {
+			target = segments[len(segments)-2] + "/" + target
+		}
+		deps = append(deps, declaredDependency{Section: "resolutions", Key: key, Name: yarnSpecName(target), Spec: resolutions[key]})
+	}
+
+	// pnpm overrides select by parent with ">": "foo@1>debug"
+	var pnpm struct {
+		Overrides json.RawMessage `json:"overrides"`
+	}
+	json.Unmarshal(manifest["pnpm"], &pnpm)
+	overrides := decodeStringMap(pnpm.Overrides)
+	for _, key := range sortedKeys(overrides) {
+		target := key
+		if idx := strings.LastIndex(key, ">"); idx >= 0 {
+			target = key[idx+1:]
+		}
+		deps = append(deps, declaredDependency{Section: "pnpm.overrides", Key: key, Name: yarnSpecName(target), Spec: overrides[key]})
+	}
+	return deps, nil
+}
+
+// decodeStringMap reads a JSON object of strings, skipping values of any
+// other type so one odd entry does not hide the rest
+func decodeStringMap(raw json.RawMessage) map[string]string {
+	var values map[string]json.RawMessage
+	if len(raw) == 0 || json.Unmarshal(raw, &values) != nil {
+		return nil
+	}
+	out := make(map[string]string, len(values))
+	for key, value := range values {
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			out[key] = s
+		}
+	}
+	return out
+}
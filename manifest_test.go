@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseManifestDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string // "<section> <key> <name>@<spec>"
+	}{
+		{"sections", `{
+			"dependencies": {"chalk": "^5.0.0", "my-debug": "npm:debug@^4.0.0"},
+			"devDependencies": {"@ctrl/tinycolor": "4.1.1"},
+			"optionalDependencies": {"fsevents": "^2.3.0"},
+			"peerDependencies": {"react": ">=18"},
+			"bundleDependencies": ["chalk"]
+		}`, []string{
+			"dependencies chalk chalk@^5.0.0",
+			"dependencies my-debug my-debug@npm:debug@^4.0.0",
+			"devDependencies @ctrl/tinycolor @ctrl/tinycolor@4.1.1",
+			"optionalDependencies fsevents fsevents@^2.3.0",
+			"peerDependencies react react@>=18",
+		}},
+		{"npm overrides", `{"overrides": {
+			"chalk": "5.3.0",
+			"foo": {".": "1.0.0", "debug": "4.4.1", "bar": {"@ctrl/tinycolor": "4.1.0"}},
+			"ms@^2.0.0": "2.1.3"
+		}}`, []string{
+			"overrides chalk chalk@5.3.0",
+			"overrides foo foo@1.0.0",
+			"overrides foo > bar > @ctrl/tinycolor @ctrl/tinycolor@4.1.0",
+			"overrides foo > debug debug@4.4.1",
+			"overrides ms@^2.0.0 ms@2.1.3",
+		}},
+		{"yarn resolutions", `{"resolutions": {
+			"**/debug": "4.4.1",
+			"foo/@ctrl/tinycolor": "4.1.0",
+			"chalk": "5.3.0"
+		}}`, []string{
+			"resolutions **/debug debug@4.4.1",
+			"resolutions chalk chalk@5.3.0",
+			"resolutions foo/@ctrl/tinycolor @ctrl/tinycolor@4.1.0",
+		}},
+		{"pnpm overrides", `{"pnpm": {"overrides": {
+			"foo@1>debug": "4.4.1",
+			"chalk@<5.6.0": "5.3.0"
+		}}}`, []string{
+			"pnpm.overrides chalk@<5.6.0 chalk@5.3.0",
+			"pnpm.overrides foo@1>debug debug@4.4.1",
+		}},
+		{"odd values skipped", `{"dependencies": {"chalk": 5, "debug": "^4.0.0"}, "overrides": "nope"}`, []string{
+			"dependencies debug debug@^4.0.0",
+		}},
+	}
+	for _, tt := range tests {
+		deps, err := parseManifestDependencies([]byte(tt.manifest))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, dep := range deps {
+			got = append(got, fmt.Sprintf("%s %s %s@%s", dep.Section, dep.Key, dep.Name, dep.Spec))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, strings.Join(got, "\n     "), strings.Join(tt.want, "\n     "))
+		}
+	}
+
+	if _, err := parseManifestDependencies([]byte(`{"dependencies": `)); err == nil {
+		t.Error("truncated package.json parsed")
+	}
+}
+
+func TestIsRegistryRange(t *testing.T) {
+	tests := []struct {
+		spec string
+		want bool
+	}{
+		{"^5.0.0", true},
+		{"5.6.1", true},
+		{">=4.0.0 <5", true},
+		{"latest", true},
+		{"workspace:*", false},
+		{"file:../lib", false},
+		{"git+https://github.com/chalk/chalk.git", false},
+		{"chalk/chalk#v5.6.1", false},
+		{"https://example.com/chalk.tgz", false},
+		{"$chalk", false},
+	}
+	for _, tt := range tests {
+		if got := isRegistryRange(tt.spec); got != tt.want {
+			t.Errorf("isRegistryRange(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestScanPackageManifest(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2", ">=4.4.5 <4.5.0"}},
+	)
+	path := filepath.Join(t.TempDir(), "package.json")
+	manifest := `{
+		"name": "app",
+		"dependencies": {
+			"chalk": "^5.0.0",
+			"my-debug": "npm:debug@^4.4.0",
+			"ok-chalk": "npm:chalk@~5.3.0",
+			"local-chalk": "file:../chalk",
+			"tagged-chalk": "latest"
+		},
+		"devDependencies": {"debug": "~4.4.3"},
+		"resolutions": {"**/debug": "4.4.1"},
+		"pnpm": {"overrides": {"foo>chalk": "5.x"}}
+	}`
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	scanPackageManifest(path, func(f Finding) {
+		s := fmt.Sprintf("%s %s@%s", f.Type, f.Package, f.Version)
+		if f.Alias != "" {
+			s += " as " + f.Alias
+		}
+		got = append(got, s+" ("+f.Location+")")
+	}, false)
+	sort.Strings(got)
+
+	// Exact IOC versions are matched against the declared range, IOC ranges
+	// by overlap; pinned resolutions, file specs and dist-tags are skipped
+	want := []string{
+		"exposure chalk@5.x (pnpm.overrides foo>chalk, admits 5.6.1)",
+		"exposure chalk@^5.0.0 (dependencies, admits 5.6.1)",
+		"exposure debug@^4.4.0 as my-debug (dependencies, admits 4.4.2, >=4.4.5 <4.5.0)",
+		"exposure debug@~4.4.3 (devDependencies, admits >=4.4.5 <4.5.0)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import "strings"
+
+// byteClass maps every byte that can appear in an npm package name to a
+// dense symbol; everything else shares class 0. This keeps the automaton's
+// transition table small even for large imported IOC sets.
+var byteClass = func() [256]uint8 {
+	var classes [256]uint8
+	next := uint8(1)
+	for _, c := range []byte("abcdefghijklmnopqrstuvwxyz0123456789-._~@/") {
+		classes[c] = next
+		next++
+	}
+	return classes
+}()
+
+const byteClassCount = 43
+
+// iocMatcher is built once from the IOC list and shared by every detector:
+// a name index for exact lookups and an Aho-Corasick automaton that finds
+// every IOC package name in a line in a single pass
+type iocMatcher struct {
+	byName   map[string]*CompromisedPackage
+	patterns []*CompromisedPackage
+
+	// delta[state*byteClassCount+class] is the next state (a full DFA, with
+	// failure transitions folded in)
+	delta []int32
+	// outputs[state] lists the patterns that end at state
+	outputs [][]int32
+}
+
+// activeMatcher indexes compromisedPackages for the scanners
+var activeMatcher = newIOCMatcher(nil)
+
+func newIOCMatcher(packages []CompromisedPackage) *iocMatcher {
+	m := &iocMatcher{byName: make(map[string]*CompromisedPackage)}
+
+	// Build the trie
+	m.delta = make([]int32, byteClassCount)
+	m.outputs = [][]int32{nil}
+	for i := range packages {
+		pkg := &packages[i]
+		m.byName[pkg.Name] = pkg
+		pattern := int32(len(m.patterns))
+		m.patterns = append(m.patterns, pkg)
+
+		state := int32(0)
+		for j := 0; j < len(pkg.Name); j++ {
+			class := int32(byteClass[pkg.Name[j]])
+			next := m.delta[state*byteClassCount+class]
+			if next == 0 {
+				next = int32(len(m.outputs))
+				m.delta[state*byteClassCount+class] = next
+				m.delta = append(m.delta, make([]int32, byteClassCount)...)
+				m.outputs = append(m.outputs, nil)
+			}
+			state = next
+		}
+		m.outputs[state] = append(m.outputs[state], pattern)
+	}
+
+	// Breadth-first pass computing failure links and folding them into the
+	// transition table
+	fail := make([]int32, len(m.outputs))
+	queue := make([]int32, 0, len(m.outputs))
+	for class := int32(0); class < byteClassCount; class++ {
+		if next := m.delta[class]; next != 0 {
+			queue = append(queue, next)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		m.outputs[state] = append(m.outputs[state], m.outputs[fail[state]]...)
+
+		for class := int32(0); class < byteClassCount; class++ {
+			next := m.delta[state*byteClassCount+class]
+			if next != 0 {
+				fail[next] = m.delta[fail[state]*byteClassCount+class]
+				queue = append(queue, next)
+			} else {
+				m.delta[state*byteClassCount+class] = m.delta[fail[state]*byteClassCount+class]
+			}
+		}
+	}
+	return m
+}
+
+// lookup returns the IOC entry for an exact package name
+func (m *iocMatcher) lookup(name string) *CompromisedPackage {
+	return m.byName[name]
+}
+
+// findNames calls fn for every occurrence of an IOC package name in s, with
+// the byte offsets of the occurrence
+func (m *iocMatcher) findNames(s string, fn func(pkg *CompromisedPackage, start, end int)) {
+	if len(m.patterns) == 0 {
+		return
+	}
+	state := int32(0)
+	for i := 0; i < len(s); i++ {
+		state = m.delta[state*byteClassCount+int32(byteClass[s[i]])]
+		for _, pattern := range m.outputs[state] {
+			pkg := m.patterns[pattern]
+			fn(pkg, i+1-len(pkg.Name), i+1)
+		}
+	}
+}
+
+// isNameByte reports whether c can continue a package name, so a match that
+// touches one is part of a longer identifier
+func isNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// findPackages is findNames restricted to whole package identifiers:
+// "chalk" does not match inside "chalk-template", "my-chalk" or
+// "@scope/chalk"
+func (m *iocMatcher) findPackages(s string, fn func(pkg *CompromisedPackage, start, end int)) {
+	m.findNames(s, func(pkg *CompromisedPackage, start, end int) {
+		if end < len(s) && isNameByte(s[end]) {
+			return
+		}
+		if start > 0 {
+			switch before := s[start-1]; {
+			case isNameByte(before), before == '@':
+				return
+			case before == '/' && !strings.HasPrefix(pkg.Name, "@"):
+				// Skip an unscoped IOC matching the name part of "@scope/name"
+				segment := start - 1
+				for segment > 0 && isNameByte(s[segment-1]) {
+					segment--
+				}
+				if segment > 0 && s[segment-1] == '@' {
+					return
+				}
+			}
+		}
+		fn(pkg, start, end)
+	})
+}
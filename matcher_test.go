@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func testMatcher(t testing.TB, names ...string) *iocMatcher {
+	t.Helper()
+	packages := make([]CompromisedPackage, len(names))
+	for i, name := range names {
+		packages[i] = CompromisedPackage{Name: name, Versions: []string{"1.0.0"}}
+		if err := packages[i].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return newIOCMatcher(packages)
+}
+
+// collect returns "name@start:end" for every match, sorted
+func collect(find func(string, func(*CompromisedPackage, int, int)), s string) []string {
+	var matches []string
+	find(s, func(pkg *CompromisedPackage, start, end int) {
+		matches = append(matches, fmt.Sprintf("%s@%d:%d", pkg.Name, start, end))
+	})
+	sort.Strings(matches)
+	return matches
+}
+
+func TestFindNamesOverlapping(t *testing.T) {
+	m := testMatcher(t, "color", "color-convert", "convert", "tinycolor", "@ctrl/tinycolor")
+
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"nothing here", nil},
+		{"color", []string{"color@0:5"}},
+		// Every pattern ending at a position is reported, including ones
+		// that are suffixes or prefixes of longer patterns
+		{"color-convert", []string{"color-convert@0:13", "color@0:5", "convert@6:13"}},
+		{"@ctrl/tinycolor", []string{"@ctrl/tinycolor@0:15", "color@10:15", "tinycolor@6:15"}},
+		{"colorcolor", []string{"color@0:5", "color@5:10"}},
+		// Bytes outside package names reset the automaton
+		{"col or", nil},
+		{"COLOR", nil},
+	}
+	for _, tt := range tests {
+		if got := collect(m.findNames, tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findNames(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestFindPackagesBoundaries(t *testing.T) {
+	m := testMatcher(t, "chalk", "debug", "@ctrl/tinycolor", "tinycolor")
+
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"chalk", []string{"chalk@0:5"}},
+		{`"chalk": "5.6.1"`, []string{"chalk@1:6"}},
+		{"chalk@5.6.1", []string{"chalk@0:5"}},
+		{"node_modules/chalk/package.json", []string{"chalk@13:18"}},
+		{"https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz", []string{"chalk@27:32"}},
+		{"require('chalk')", []string{"chalk@9:14"}},
+		// Longer identifiers that contain the name
+		{"chalk-template", nil},
+		{"my-chalk", nil},
+		{"chalk.js", nil},
+		{"chalk_", nil},
+		{"debugger", nil},
+		// Scoped packages whose name part is an IOC
+		{"@x/chalk", nil},
+		{`"@x/chalk": "5.6.1"`, nil},
+		{"node_modules/@x/chalk/index.js", nil},
+		{"@my-scope/chalk", nil},
+		{"@chalk", nil},
+		// The scoped IOC matches; its unscoped name part does not
+		{"@ctrl/tinycolor@4.1.1", []string{"@ctrl/tinycolor@0:15"}},
+		{"node_modules/@ctrl/tinycolor", []string{"@ctrl/tinycolor@13:28"}},
+		{"@evil-ctrl/tinycolor", nil},
+		{"tinycolor", []string{"tinycolor@0:9"}},
+		{"chalk debug", []string{"chalk@0:5", "debug@6:11"}},
+	}
+	for _, tt := range tests {
+		if got := collect(m.findPackages, tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findPackages(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestMatcherLookup(t *testing.T) {
+	m := testMatcher(t, "chalk", "@ctrl/tinycolor")
+	if pkg := m.lookup("@ctrl/tinycolor"); pkg == nil || pkg.Name != "@ctrl/tinycolor" {
+		t.Errorf("lookup(@ctrl/tinycolor) = %v", pkg)
+	}
+	for _, name := range []string{"", "chalk-template", "tinycolor", "Chalk"} {
+		if pkg := m.lookup(name); pkg != nil {
+			t.Errorf("lookup(%q) = %s, want nil", name, pkg.Name)
+		}
+	}
+	if got := collect(newIOCMatcher(nil).findNames, "chalk"); got != nil {
+		t.Errorf("empty matcher found %v", got)
+	}
+}
+
+// The benchmarks compare the automaton with the per-package loop it
+// replaced, on the embedded IOC list and inputs of a few megabytes
+
+func benchmarkIOCs(b *testing.B) ([]CompromisedPackage, *iocMatcher) {
+	b.Helper()
+	db, err := parseIOCDatabase(defaultIOCData)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return db.Packages, newIOCMatcher(db.Packages)
+}
+
+// benchmarkLockfile returns the lines of a package-lock.json with n
+// entries, one in every 500 a compromised package
+func benchmarkLockfile(packages []CompromisedPackage, n int) []string {
+	var lines []string
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("package-%d", i)
+		if i%500 == 0 {
+			name = packages[i/500%len(packages)].Name
+		}
+		base := name[strings.LastIndex(name, "/")+1:]
+		lines = append(lines,
+			fmt.Sprintf(`    "node_modules/%s": {`, name),
+			`      "version": "1.2.3",`,
+			fmt.Sprintf(`      "resolved": "https://registry.npmjs.org/%s/-/%s-1.2.3.tgz",`, name, base),
+			`      "integrity": "sha512-6Rl8uzWcPs3ejn23hDeqDb13aJRc2u7XJYtXdePSO0j3QG62q7OIhm+iuRkUOpDzszvUl3ZA2r9bh7W98BoX0Q==",`,
+			`      "dev": true`,
+			`    },`)
+	}
+	return lines
+}
+
+// benchmarkBundle returns a minified bundle split into lines of about 32 KB,
+// as vendored JavaScript often is
+func benchmarkBundle(packages []CompromisedPackage, size int) []string {
+	var lines []string
+	var line strings.Builder
+	for i := 0; line.Len()+len(lines)*32<<10 < size; i++ {
+		fmt.Fprintf(&line, `var a%d=function(e,t,n){"use strict";var r=n(%d),o=r.default||r;e.exports=o.createElement("div",{className:"item-%d"})};`, i, i*7, i)
+		if i%2000 == 0 {
+			fmt.Fprintf(&line, `n("%s/lib/index.js");`, packages[i/2000%len(packages)].Name)
+		}
+		if line.Len() >= 32<<10 {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+	}
+	return append(lines, line.String())
+}
+
+// oldFindPackages is the loop findPackages replaced: every IOC is searched
+// for separately, with its tarball URL prefix formatted on every line
+func oldFindPackages(packages []CompromisedPackage, line string, fn func(*CompromisedPackage)) {
+	for i := range packages {
+		pkg := &packages[i]
+		base := pkg.Name[strings.LastIndex(pkg.Name, "/")+1:]
+		prefix := fmt.Sprintf("%s/-/%s-", pkg.Name, base)
+		if strings.Contains(line, prefix) || strings.Contains(line, pkg.Name) {
+			fn(pkg)
+		}
+	}
+}
+
+func runBenchmark(b *testing.B, lines []string, find func(string) int) {
+	size := 0
+	for _, line := range lines {
+		size += len(line) + 1
+	}
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hits := 0
+		for _, line := range lines {
+			hits += find(line)
+		}
+		if hits == 0 {
+			b.Fatal("no IOC names found")
+		}
+	}
+}
+
+func BenchmarkLockfileMatcher(b *testing.B) {
+	packages, m := benchmarkIOCs(b)
+	runBenchmark(b, benchmarkLockfile(packages, 20000), func(line string) int {
+		hits := 0
+		m.findPackages(line, func(*CompromisedPackage, int, int) { hits++ })
+		return hits
+	})
+}
+
+func BenchmarkLockfileLoop(b *testing.B) {
+	packages, _ := benchmarkIOCs(b)
+	runBenchmark(b, benchmarkLockfile(packages, 20000), func(line string) int {
+		hits := 0
+		oldFindPackages(packages, line, func(*CompromisedPackage) { hits++ })
+		return hits
+	})
+}
+
+func BenchmarkBundleMatcher(b *testing.B) {
+	packages, m := benchmarkIOCs(b)
+	runBenchmark(b, benchmarkBundle(packages, 5<<20), func(line string) int {
+		hits := 0
+		m.findPackages(line, func(*CompromisedPackage, int, int) { hits++ })
+		return hits
+	})
+}
+
+func BenchmarkBundleLoop(b *testing.B) {
+	packages, _ := benchmarkIOCs(b)
+	runBenchmark(b, benchmarkBundle(packages, 5<<20), func(line string) int {
+		hits := 0
+		oldFindPackages(packages, line, func(*CompromisedPackage) { hits++ })
+		return hits
+	})
+}
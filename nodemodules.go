@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// installedPackage is a package found on disk in a node_modules tree
+type installedPackage struct {
+	Name    string // from its package.json
+	Version string
+	// Folder is the name it is installed under, which differs from Name
+	// for npm: aliases
+	Folder string
+	// Location is the install path relative to the project, in the form
+	// package-lock.json keys use: "node_modules/a/node_modules/chalk"
+	Location string
+	Dir      string
+}
+
+// scanInstalledPackages reads every project's node_modules tree, so
+// packages are found even when the lockfile is missing, stale or ignored
+func scanInstalledPackages(baseDir string, jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Finding), verbose bool) {
+	trees := 0
+	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if info.Name() != "node_modules" {
+			return nil
+		}
+		trees++
+		if verbose {
+			fmt.Printf("  📂 Found node_modules: %s\n", path)
+		}
+		wg.Add(1)
+		jobs <- func() {
+			defer wg.Done()
+			scanNodeModulesTree(path, addFinding, verbose)
+		}
+		// Nested trees are read along with their parent
+		return filepath.SkipDir
+	})
+	if verbose && trees == 0 {
+		fmt.Printf("  ℹ️  No node_modules folders found in %s\n", baseDir)
+	}
+}
+
+func scanNodeModulesTree(nodeModules string, addFinding func(Finding), verbose bool) {
+	for _, installed := range readNodeModules(nodeModules) {
+		pkg := activeMatcher.lookup(installed.Name)
+		if pkg == nil || !pkg.matchesVersion(installed.Version) {
+			continue
+		}
+		alias := ""
+		if installed.Folder != installed.Name {
+			alias = installed.Folder
+		}
+		addFinding(Finding{
+			Package:  pkg.Name,
+			Version:  installed.Version,
+			Alias:    alias,
+			File:     filepath.Join(installed.Dir, "package.json"),
+			Location: installed.Location,
+			Type:     "installed",
+			IOC:      pkg,
+		})
+		if verbose {
+			fmt.Printf("  Found installed %s@%s at %s\n", pkg.Name, installed.Version, installed.Dir)
+		}
+	}
+}
+
+// readNodeModules lists every package installed in a node_modules folder:
+// hoisted and nested npm/yarn/bun installs, and the packages of pnpm's
+// .pnpm (and deno's .deno) virtual store. Symlinks are skipped, since they
+// point into the virtual store or at workspace packages scanned on their
+// own.
+func readNodeModules(nodeModules string) []installedPackage {
+	var packages []installedPackage
+	var walk func(dir, location string)
+	// readPackage records the package in dir, installed as folder, and
+	// walks its own node_modules
+	readPackage := func(dir, folder, parent string) {
+		location := parent + "/" + folder
+		if installed, ok := readInstalledManifest(dir); ok {
+			installed.Folder = folder
+			installed.Location = location
+			packages = append(packages, installed)
+		}
+		walk(filepath.Join(dir, "node_modules"), location+"/node_modules")
+	}
+	walk = func(dir, location string) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			switch {
+			case name == ".pnpm" || name == ".deno":
+				packages = append(packages, readVirtualStore(filepath.Join(dir, name), location+"/"+name)...)
+				continue
+			case strings.HasPrefix(name, "."), !entry.IsDir():
+				continue
+			}
+			if !strings.HasPrefix(name, "@") {
+				readPackage(filepath.Join(dir, name), name, location)
+				continue
+			}
+			scoped, err := os.ReadDir(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			for _, child := range scoped {
+				if child.IsDir() {
+					readPackage(filepath.Join(dir, name, child.Name()), name+"/"+child.Name(), location)
+				}
+			}
+		}
+	}
+	walk(nodeModules, "node_modules")
+	return packages
+}
+
+// readVirtualStore reads pnpm's content-linked store. Each entry is named
+// after the package it holds, with "/" in scopes written as "+" and peer
+// dependencies appended: "@ctrl+tinycolor@4.1.1",
+// "debug@4.4.2_supports-color@8.1.1". The package itself is in the entry's
+// node_modules, next to symlinks to its dependencies.
+func readVirtualStore(store, location string) []installedPackage {
+	entries, err := os.ReadDir(store)
+	if err != nil {
+		return nil
+	}
+	var packages []installedPackage
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == "node_modules" {
+			continue
+		}
+		name := yarnSpecName(entry.Name())
+		if strings.HasPrefix(name, "@") {
+			name = strings.Replace(name, "+", "/", 1)
+		}
+		if !isValidPackageName(name) {
+			continue
+		}
+		folder := filepath.Join(store, entry.Name(), "node_modules", filepath.FromSlash(name))
+		installed, ok := readInstalledManifest(folder)
+		if !ok {
+			continue
+		}
+		installed.Folder = installed.Name
+		installed.Location = location + "/" + entry.Name() + "/node_modules/" + name
+		packages = append(packages, installed)
+	}
+	return packages
+}
+
+// readInstalledManifest reads the name and version of the package in dir
+func readInstalledManifest(dir string) (installedPackage, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return installedPackage{}, false
+	}
+	var manifest struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if json.Unmarshal(data, &manifest) != nil || manifest.Name == "" {
+		return installedPackage{}, false
+	}
+	return installedPackage{Name: manifest.Name, Version: manifest.Version, Dir: dir}, true
+}
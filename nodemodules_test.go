@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeInstalledFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadNodeModules(t *testing.T) {
+	manifest := func(name, version string) string {
+		return fmt.Sprintf(`{"name": %q, "version": %q}`, name, version)
+	}
+	tests := []struct {
+		name  string
+		files map[string]string // path under node_modules => package.json
+		want  []string          // "<location> <name>@<version> [as <folder>]"
+	}{
+		{"hoisted and nested", map[string]string{
+			"chalk/package.json":                          manifest("chalk", "5.6.1"),
+			"foo/package.json":                            manifest("foo", "1.0.0"),
+			"foo/node_modules/debug/package.json":         manifest("debug", "4.4.2"),
+			"@ctrl/tinycolor/package.json":                manifest("@ctrl/tinycolor", "4.1.1"),
+			"@ctrl/tinycolor/node_modules/a/package.json": manifest("a", "1.0.0"),
+		}, []string{
+			"node_modules/@ctrl/tinycolor @ctrl/tinycolor@4.1.1",
+			"node_modules/@ctrl/tinycolor/node_modules/a a@1.0.0",
+			"node_modules/chalk chalk@5.6.1",
+			"node_modules/foo foo@1.0.0",
+			"node_modules/foo/node_modules/debug debug@4.4.2",
+		}},
+		{"aliases", map[string]string{
+			"my-debug/package.json": manifest("debug", "4.4.2"),
+		}, []string{
+			"node_modules/my-debug debug@4.4.2 as my-debug",
+		}},
+		{"skipped", map[string]string{
+			".bin/package.json":                       manifest("bin", "1.0.0"),
+			".cache/chalk/package.json":               manifest("chalk", "5.6.1"),
+			"no-manifest/index.js":                    "",
+			"no-manifest/node_modules/b/package.json": manifest("b", "2.0.0"),
+			"unnamed/package.json":                    `{"version": "1.0.0"}`,
+			"broken/package.json":                     `{"name": `,
+		}, []string{
+			"node_modules/no-manifest/node_modules/b b@2.0.0",
+		}},
+		// The hoisting folder .pnpm/node_modules only links into the store
+		{"pnpm and deno virtual stores", map[string]string{
+			".pnpm/chalk@5.6.1/node_modules/chalk/package.json":                      manifest("chalk", "5.6.1"),
+			".pnpm/@ctrl+tinycolor@4.1.1/node_modules/@ctrl/tinycolor/package.json":  manifest("@ctrl/tinycolor", "4.1.1"),
+			".pnpm/debug@4.4.2_supports-color@8.1.1/node_modules/debug/package.json": manifest("debug", "4.4.2"),
+			".pnpm/node_modules/ms/package.json":                                     manifest("ms", "2.1.3"),
+			".deno/chalk@5.6.1/node_modules/chalk/package.json":                      manifest("chalk", "5.6.1"),
+			".pnpm/lock.yaml": "",
+		}, []string{
+			"node_modules/.deno/chalk@5.6.1/node_modules/chalk chalk@5.6.1",
+			"node_modules/.pnpm/@ctrl+tinycolor@4.1.1/node_modules/@ctrl/tinycolor @ctrl/tinycolor@4.1.1",
+			"node_modules/.pnpm/chalk@5.6.1/node_modules/chalk chalk@5.6.1",
+			"node_modules/.pnpm/debug@4.4.2_supports-color@8.1.1/node_modules/debug debug@4.4.2",
+		}},
+	}
+	for _, tt := range tests {
+		nodeModules := filepath.Join(t.TempDir(), "node_modules")
+		for path, content := range tt.files {
+			writeInstalledFile(t, filepath.Join(nodeModules, path), content)
+		}
+		var got []string
+		for _, installed := range readNodeModules(nodeModules) {
+			s := fmt.Sprintf("%s %s@%s", installed.Location, installed.Name, installed.Version)
+			if installed.Folder != installed.Name {
+				s += " as " + installed.Folder
+			}
+			got = append(got, s)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, strings.Join(got, "\n     "), strings.Join(tt.want, "\n     "))
+		}
+	}
+}
+
+func TestReadNodeModulesSkipsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	writeInstalledFile(t, filepath.Join(dir, "packages", "web", "package.json"), `{"name": "@acme/web", "version": "0.1.0"}`)
+	writeInstalledFile(t, filepath.Join(dir, "node_modules", "chalk", "package.json"), `{"name": "chalk", "version": "5.6.1"}`)
+	if err := os.MkdirAll(filepath.Join(dir, "node_modules", "@acme"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "packages", "web"), filepath.Join(dir, "node_modules", "@acme", "web")); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+	packages := readNodeModules(filepath.Join(dir, "node_modules"))
+	if len(packages) != 1 || packages[0].Name != "chalk" {
+		t.Errorf("got %v, want only chalk", packages)
+	}
+}
+
+func TestScanNodeModulesTree(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+	)
+	nodeModules := filepath.Join(t.TempDir(), "node_modules")
+	for path, content := range map[string]string{
+		"chalk/package.json":                  `{"name": "chalk", "version": "5.3.0"}`,
+		"foo/node_modules/chalk/package.json": `{"name": "chalk", "version": "5.6.1"}`,
+		"my-debug/package.json":               `{"name": "debug", "version": "4.4.2"}`,
+	} {
+		writeInstalledFile(t, filepath.Join(nodeModules, path), content)
+	}
+
+	var got []string
+	scanNodeModulesTree(nodeModules, func(f Finding) {
+		s := fmt.Sprintf("%s %s@%s", f.Type, f.Package, f.Version)
+		if f.Alias != "" {
+			s += " as " + f.Alias
+		}
+		got = append(got, s+" ("+f.Location+")")
+	}, false)
+	sort.Strings(got)
+	want := []string{
+		"installed chalk@5.6.1 (node_modules/foo/node_modules/chalk)",
+		"installed debug@4.4.2 as my-debug (node_modules/my-debug)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
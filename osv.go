@@ -0,0 +1,282 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// osvRecord is the subset of the OSV schema (https://ossf.github.io/osv-schema/)
+// needed to build IOC entries
+type osvRecord struct {
+	ID        string        `json:"id"`
+	Summary   string        `json:"summary"`
+	Published string        `json:"published"`
+	Withdrawn string        `json:"withdrawn"`
+	Affected  []osvAffected `json:"affected"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges   []osvRange `json:"ranges"`
+	Versions []string   `json:"versions"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced"`
+	Fixed        string `json:"fixed"`
+	LastAffected string `json:"last_affected"`
+	Limit        string `json:"limit"`
+}
+
+// osvImportStats summarizes an OSV import for the command output
+type osvImportStats struct {
+	Records  int
+	Skipped  int
+	Packages int
+	Warnings []string
+}
+
+// readOSVRecords loads OSV records from a zip archive, a directory of JSON
+// files or a single JSON file
+func readOSVRecords(path string) ([]osvRecord, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []osvRecord
+	add := func(name string, r io.Reader) error {
+		var record osvRecord
+		if err := json.NewDecoder(r).Decode(&record); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		records = append(records, record)
+		return nil
+	}
+
+	switch {
+	case info.IsDir():
+		err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() || !strings.EqualFold(filepath.Ext(p), ".json") {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return add(p, f)
+		})
+	case strings.EqualFold(filepath.Ext(path), ".zip"):
+		var zr *zip.ReadCloser
+		zr, err = zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(zf.Name), ".json") {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", zf.Name, err)
+			}
+			err = add(zf.Name, rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		var f *os.File
+		f, err = os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		err = add(path, f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// osvToCompromisedPackages converts the npm entries of OSV records into IOC
+// entries, one per package name
+func osvToCompromisedPackages(records []osvRecord, stats *osvImportStats) []CompromisedPackage {
+	byName := make(map[string]*CompromisedPackage)
+	var order []string
+
+	for _, record := range records {
+		if record.Withdrawn != "" {
+			stats.Skipped++
+			continue
+		}
+		stats.Records++
+
+		for _, affected := range record.Affected {
+			if !strings.EqualFold(affected.Package.Ecosystem, "npm") {
+				continue
+			}
+			name := affected.Package.Name
+			if !isValidPackageName(name) {
+				stats.Warnings = append(stats.Warnings, fmt.Sprintf("%s: skipping invalid package name %q", record.ID, name))
+				continue
+			}
+
+			var specs []string
+			for _, r := range affected.Ranges {
+				if r.Type != "SEMVER" && r.Type != "ECOSYSTEM" {
+					continue
+				}
+				specs = append(specs, osvEventsToRanges(r.Events)...)
+			}
+			specs = append(specs, affected.Versions...)
+
+			pkg, ok := byName[name]
+			if !ok {
+				pkg = &CompromisedPackage{Name: name}
+				byName[name] = pkg
+				order = append(order, name)
+			}
+			applyOSVMetadata(pkg, record)
+			for _, spec := range specs {
+				if _, err := parseSemVerRange(spec); err != nil {
+					stats.Warnings = append(stats.Warnings, fmt.Sprintf("%s: skipping %s %q: %v", record.ID, name, spec, err))
+					continue
+				}
+				pkg.Versions = appendUnique(pkg.Versions, spec)
+			}
+		}
+	}
+
+	var packages []CompromisedPackage
+	for _, name := range order {
+		if len(byName[name].Versions) > 0 {
+			packages = append(packages, *byName[name])
+		}
+	}
+	return packages
+}
+
+// applyOSVMetadata records the advisory, publication date and severity of an
+// OSV record on the IOC entry it contributes to
+func applyOSVMetadata(pkg *CompromisedPackage, record osvRecord) {
+	if record.ID != "" {
+		pkg.Advisories = appendUnique(pkg.Advisories, "https://osv.dev/vulnerability/"+record.ID)
+	}
+	if len(record.Published) >= 10 {
+		published := record.Published[:10]
+		if _, err := time.Parse("2006-01-02", published); err == nil && (pkg.FirstSeen == "" || published < pkg.FirstSeen) {
+			pkg.FirstSeen = published
+		}
+	}
+	if strings.HasPrefix(record.ID, "MAL-") {
+		// OSV malicious-package records carry no CVSS score but always
+		// warrant the highest severity
+		pkg.Severity = "critical"
+		if pkg.Remediation == "" {
+			pkg.Remediation = "Published as a malicious package: remove it, reinstall from a clean lockfile and treat hosts that installed it as compromised."
+		}
+	}
+}
+
+// osvEventsToRanges turns an OSV event timeline into semver ranges. Each
+// "introduced" event opens an interval that the next "fixed", "last_affected"
+// or "limit" event closes; an interval left open affects every later version.
+func osvEventsToRanges(events []osvEvent) []string {
+	var ranges []string
+	lower := ""
+	open := false
+
+	bound := func(upper string) string {
+		if lower == "" || lower == "0" {
+			return upper
+		}
+		return ">=" + lower + " " + upper
+	}
+
+	for _, event := range events {
+		switch {
+		case event.Introduced != "":
+			lower = event.Introduced
+			open = true
+		case event.Fixed != "" && open:
+			ranges = append(ranges, bound("<"+event.Fixed))
+			open = false
+		case event.LastAffected != "" && open:
+			ranges = append(ranges, bound("<="+event.LastAffected))
+			open = false
+		case event.Limit != "" && open:
+			ranges = append(ranges, bound("<"+event.Limit))
+			open = false
+		}
+	}
+	if open {
+		if lower == "" || lower == "0" {
+			ranges = append(ranges, "*")
+		} else {
+			ranges = append(ranges, ">="+lower)
+		}
+	}
+	return ranges
+}
+
+// mergeCompromisedPackages adds the versions of every imported package to the
+// IOC database, creating entries for packages it doesn't list yet, and
+// returns the number of entries that changed
+func mergeCompromisedPackages(db *IOCDatabase, imported []CompromisedPackage) int {
+	index := make(map[string]int)
+	for i, pkg := range db.Packages {
+		index[pkg.Name] = i
+	}
+
+	changed := 0
+	for _, pkg := range imported {
+		i, ok := index[pkg.Name]
+		if !ok {
+			db.Packages = append(db.Packages, pkg)
+			index[pkg.Name] = len(db.Packages) - 1
+			changed++
+			continue
+		}
+		existing := &db.Packages[i]
+		before := len(existing.Versions)
+		for _, version := range pkg.Versions {
+			existing.Versions = appendUnique(existing.Versions, version)
+		}
+		// Keep curated campaign metadata; only add the OSV advisory links
+		for _, advisory := range pkg.Advisories {
+			existing.Advisories = appendUnique(existing.Advisories, advisory)
+		}
+		if len(existing.Versions) != before {
+			changed++
+		}
+	}
+	return changed
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOSVEventsToRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		events string
+		want   []string
+	}{
+		{"from zero, fixed", `[{"introduced": "0"}, {"fixed": "4.4.3"}]`, []string{"<4.4.3"}},
+		{"introduced and fixed", `[{"introduced": "4.4.2"}, {"fixed": "4.4.3"}]`, []string{">=4.4.2 <4.4.3"}},
+		{"last affected", `[{"introduced": "4.4.2"}, {"last_affected": "4.4.2"}]`, []string{">=4.4.2 <=4.4.2"}},
+		{"limit", `[{"introduced": "1.0.0"}, {"limit": "2.0.0"}]`, []string{">=1.0.0 <2.0.0"}},
+		{"open", `[{"introduced": "5.6.1"}]`, []string{">=5.6.1"}},
+		{"open from zero", `[{"introduced": "0"}]`, []string{"*"}},
+		{"several intervals", `[{"introduced": "1.0.0"}, {"fixed": "1.0.2"}, {"introduced": "2.0.0"}, {"fixed": "2.0.1"}]`,
+			[]string{">=1.0.0 <1.0.2", ">=2.0.0 <2.0.1"}},
+		{"fixed without introduced", `[{"fixed": "1.0.0"}]`, nil},
+	}
+	for _, tt := range tests {
+		var events []osvEvent
+		if err := json.Unmarshal([]byte(tt.events), &events); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := osvEventsToRanges(events); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// osvMalicious is a MAL- record listing one package by version and another
+// by range
+const osvMalicious = `{
+  "id": "MAL-2025-46969",
+  "summary": "Malicious code in chalk (npm)",
+  "published": "2025-09-08T16:40:00Z",
+  "affected": [
+    {"package": {"ecosystem": "npm", "name": "chalk"}, "versions": ["5.6.1"]},
+    {"package": {"ecosystem": "npm", "name": "debug"},
+     "ranges": [{"type": "SEMVER", "events": [{"introduced": "4.4.2"}, {"fixed": "4.4.3"}]}]}
+  ]
+}`
+
+func TestOSVToCompromisedPackages(t *testing.T) {
+	records := []osvRecord{
+		decodeOSVRecord(t, osvMalicious),
+		decodeOSVRecord(t, `{"id": "GHSA-1", "affected": [
+			{"package": {"ecosystem": "npm", "name": "chalk"}, "versions": ["5.6.1", "5.6.2"]},
+			{"package": {"ecosystem": "npm", "name": "Not Valid"}, "versions": ["1.0.0"]},
+			{"package": {"ecosystem": "PyPI", "name": "requests"}, "versions": ["2.0.0"]},
+			{"package": {"ecosystem": "npm", "name": "left-pad"}, "ranges": [{"type": "GIT", "events": [{"introduced": "abc"}]}]},
+			{"package": {"ecosystem": "npm", "name": "ms"}, "versions": ["not-a-version"]}
+		]}`),
+		decodeOSVRecord(t, `{"id": "MAL-2", "withdrawn": "2025-09-10T00:00:00Z",
+			"affected": [{"package": {"ecosystem": "npm", "name": "ansi-styles"}, "versions": ["6.2.2"]}]}`),
+	}
+
+	// Records naming a package twice merge into one entry; other
+	// ecosystems, git ranges and withdrawn records add nothing
+	var stats osvImportStats
+	packages := osvToCompromisedPackages(records, &stats)
+	got := make(map[string][]string)
+	for _, pkg := range packages {
+		got[pkg.Name] = pkg.Versions
+	}
+	want := map[string][]string{
+		"chalk": {"5.6.1", "5.6.2"},
+		"debug": {">=4.4.2 <4.4.3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if stats.Records != 2 || stats.Skipped != 1 {
+		t.Errorf("stats = %+v, want 2 records and 1 withdrawn", stats)
+	}
+
+	// Malicious-package records mark the entries they contribute to
+	for _, pkg := range packages {
+		if pkg.Name == "chalk" && (pkg.Severity != "critical" || pkg.FirstSeen != "2025-09-08" || len(pkg.Advisories) != 2 || pkg.Remediation == "") {
+			t.Errorf("chalk metadata = %+v, want critical from MAL-2025-46969", pkg)
+		}
+	}
+	if len(stats.Warnings) != 2 || !strings.Contains(stats.Warnings[0], `"Not Valid"`) || !strings.Contains(stats.Warnings[1], "not-a-version") {
+		t.Errorf("warnings = %q, want the invalid name and version", stats.Warnings)
+	}
+}
+
+func decodeOSVRecord(t *testing.T, data string) osvRecord {
+	t.Helper()
+	var record osvRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestReadOSVRecords(t *testing.T) {
+	dir := t.TempDir()
+	mkfile := func(name, content string) string {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	single := mkfile("osv/npm/MAL-2025-46969.json", osvMalicious)
+	mkfile("osv/npm/nested/GHSA-1.JSON", `{"id": "GHSA-1"}`)
+	mkfile("osv/README.md", "not a record")
+
+	// The dump is published as one zip of records
+	zipPath := filepath.Join(dir, "npm.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, entry := range []struct{ name, data string }{
+		{"MAL-2025-46969.json", osvMalicious},
+		{"notes.txt", "skipped"},
+	} {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(entry.data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"directory", filepath.Join(dir, "osv"), []string{"MAL-2025-46969", "GHSA-1"}},
+		{"zip", zipPath, []string{"MAL-2025-46969"}},
+		{"file", single, []string{"MAL-2025-46969"}},
+	}
+	for _, tt := range tests {
+		records, err := readOSVRecords(tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, record := range records {
+			got = append(got, record.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	bad := mkfile("bad.json", "{")
+	if _, err := readOSVRecords(bad); err == nil || !strings.Contains(err.Error(), "bad.json") {
+		t.Errorf("invalid record: got %v, want an error naming the file", err)
+	}
+}
+
+func TestMergeCompromisedPackages(t *testing.T) {
+	db := &IOCDatabase{Packages: []CompromisedPackage{
+		{Name: "chalk", Versions: []string{"5.6.1"}, Campaign: "chalk-debug", Severity: "high"},
+		{Name: "debug", Versions: []string{"4.4.2"}},
+	}}
+	changed := mergeCompromisedPackages(db, []CompromisedPackage{
+		{Name: "chalk", Versions: []string{"5.6.1"}, Severity: "critical", Advisories: []string{"https://osv.dev/vulnerability/MAL-1"}},
+		{Name: "debug", Versions: []string{"4.4.2", ">=4.4.5 <4.5.0"}},
+		{Name: "ms", Versions: []string{"2.1.3"}},
+	})
+	if changed != 2 {
+		t.Errorf("changed = %d, want 2: debug extended and ms added", changed)
+	}
+	got := make(map[string][]string)
+	for _, pkg := range db.Packages {
+		got[pkg.Name] = pkg.Versions
+	}
+	want := map[string][]string{
+		"chalk": {"5.6.1"},
+		"debug": {"4.4.2", ">=4.4.5 <4.5.0"},
+		"ms":    {"2.1.3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Curated metadata is kept; only the advisory link is added
+	if chalk := db.Packages[0]; chalk.Campaign != "chalk-debug" || chalk.Severity != "high" || len(chalk.Advisories) != 1 {
+		t.Errorf("chalk = %+v, want its curated campaign and severity", chalk)
+	}
+}
+
+func TestRunImportOSV(t *testing.T) {
+	dir := t.TempDir()
+	record := filepath.Join(dir, "MAL-2025-46969.json")
+	if err := os.WriteFile(record, []byte(osvMalicious), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "iocs.json")
+	if code := runImportOSV([]string{"-o", output, record}); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+
+	// The merged database is a valid IOC file the scanner can load
+	db, err := loadIOCDatabase(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(db.Source, "OSV import from MAL-2025-46969.json") {
+		t.Errorf("source = %q", db.Source)
+	}
+	for _, pkg := range db.Packages {
+		if pkg.Name == "debug" && pkg.matchesVersion("4.4.2") {
+			return
+		}
+	}
+	t.Error("imported debug range does not match 4.4.2")
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// packageLock is the part of package-lock.json the scanner reads. Version 1
+// lockfiles only have the nested Dependencies tree, version 3 only the flat
+// Packages map, and version 2 has both.
+type packageLock struct {
+	Name            string                           `json:"name"`
+	LockfileVersion int                              `json:"lockfileVersion"`
+	Packages        map[string]packageLockEntry      `json:"packages"`
+	Dependencies    map[string]packageLockDependency `json:"dependencies"`
+}
+
+// packageLockEntry is a "packages" entry, keyed by its install location
+// such as "node_modules/a/node_modules/chalk"
+type packageLockEntry struct {
+	Name      string `json:"name"` // the real name of an npm: alias
+	Version   string `json:"version"`
+	Resolved  string `json:"resolved"`
+	Integrity string `json:"integrity"`
+	Link      bool   `json:"link"`
+	Optional  bool   `json:"optional"`
+
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+}
+
+// dependencyNames returns every package the entry depends on
+func (entry *packageLockEntry) dependencyNames() []string {
+	var names []string
+	for _, section := range []map[string]string{entry.Dependencies, entry.DevDependencies, entry.OptionalDependencies, entry.PeerDependencies} {
+		for name := range section {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// packageLockDependency is a lockfileVersion 1 "dependencies" entry; nested
+// Dependencies are installed in its own node_modules
+type packageLockDependency struct {
+	Version      string                           `json:"version"`
+	Resolved     string                           `json:"resolved"`
+	Integrity    string                           `json:"integrity"`
+	Optional     bool                             `json:"optional"`
+	Requires     map[string]string                `json:"requires"`
+	Dependencies map[string]packageLockDependency `json:"dependencies"`
+}
+
+// lockedPackage is one installed package read from a lockfile
+type lockedPackage struct {
+	Name      string
+	Alias     string // install name when it differs from Name
+	Version   string
+	Integrity string
+	// Location is the lockfile key path, e.g.
+	// "node_modules/a/node_modules/chalk"
+	Location string
+	// Optional packages are skipped by installs on platforms they do not
+	// support
+	Optional bool
+}
+
+// parsePackageLock reads every installed package from a package-lock.json,
+// and the dependency graph between them keyed by location. The root project
+// is "" and is labelled with the lockfile's name, if it has one.
+func parsePackageLock(data []byte) ([]lockedPackage, *depGraph, error) {
+	var lock packageLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, nil, err
+	}
+
+	graph := newDepGraph()
+	var packages []lockedPackage
+	if len(lock.Packages) > 0 {
+		graph.addRoot("", lock.Packages[""].Name)
+		for location, entry := range lock.Packages {
+			// Workspace folders are roots of their own
+			name := packageNameFromLocation(location)
+			if location != "" && name == "" {
+				label := entry.Name
+				if label == "" {
+					label = location
+				}
+				graph.addRoot(location, label)
+			}
+			for _, dep := range entry.dependencyNames() {
+				if to := resolveLockLocation(location, dep, func(candidate string) (string, bool) {
+					target, ok := lock.Packages[candidate]
+					if ok && target.Link {
+						return target.Resolved, true
+					}
+					return candidate, ok
+				}); to != "" {
+					graph.addEdge(location, to)
+				}
+			}
+			// Skip the root project, workspace folders and links to them
+			if name == "" || entry.Link {
+				continue
+			}
+			graph.addNode(location, name)
+			locked := lockedPackage{
+				Name:      name,
+				Version:   entry.Version,
+				Integrity: entry.Integrity,
+				Location:  location,
+				Optional:  entry.Optional,
+			}
+			// npm records the real name of aliased packages; older lockfiles
+			// only have it in the tarball URL
+			realName := entry.Name
+			if realName == "" {
+				realName, _ = registryTarballPackage(entry.Resolved)
+			}
+			if realName != "" && realName != name {
+				locked.Name, locked.Alias = realName, name
+			}
+			packages = append(packages, locked)
+		}
+	} else {
+		// v1 nesting mirrors node_modules, so record it with the same key
+		// paths the packages map uses
+		graph.addRoot("", lock.Name)
+		installed := make(map[string]bool)
+		requires := make(map[string]map[string]string)
+		var walk func(deps map[string]packageLockDependency, parent string)
+		walk = func(deps map[string]packageLockDependency, parent string) {
+			for name, dep := range deps {
+				location := parent + "node_modules/" + name
+				installed[location] = true
+				requires[location] = dep.Requires
+				graph.addNode(location, name)
+				locked := lockedPackage{
+					Name:      name,
+					Version:   dep.Version,
+					Integrity: dep.Integrity,
+					Location:  location,
+					Optional:  dep.Optional,
+				}
+				// Aliases are locked as "version": "npm:chalk@5.6.1"
+				if realName, version, ok := parseNPMAlias(dep.Version); ok {
+					locked.Name, locked.Alias, locked.Version = realName, name, version
+				} else if realName, ok := registryTarballPackage(dep.Resolved); ok && realName != name {
+					locked.Name, locked.Alias = realName, name
+				}
+				packages = append(packages, locked)
+				walk(dep.Dependencies, location+"/")
+			}
+		}
+		walk(lock.Dependencies, "")
+
+		// v1 does not record the root's own dependencies, so top-level
+		// packages nothing else requires are taken as direct ones
+		required := make(map[string]bool)
+		for location, deps := range requires {
+			for dep := range deps {
+				if to := resolveLockLocation(location, dep, func(candidate string) (string, bool) {
+					return candidate, installed[candidate]
+				}); to != "" {
+					graph.addEdge(location, to)
+					required[to] = true
+				}
+			}
+		}
+		for name := range lock.Dependencies {
+			if location := "node_modules/" + name; !required[location] {
+				graph.addEdge("", location)
+			}
+		}
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Location < packages[j].Location
+	})
+	return packages, graph, nil
+}
+
+// resolveLockLocation finds the install location a package at from gets
+// dep from, the way Node resolves require(): the nearest node_modules/dep
+// in from or one of its parent folders. lookup reports whether a location
+// is installed and where it links to.
+func resolveLockLocation(from, dep string, lookup func(string) (string, bool)) string {
+	dir := from
+	for {
+		candidate := "node_modules/" + dep
+		if dir != "" {
+			candidate = dir + "/" + candidate
+		}
+		if target, ok := lookup(candidate); ok {
+			return target
+		}
+		if dir == "" {
+			return ""
+		}
+		if dir = path.Dir(dir); dir == "." {
+			dir = ""
+		}
+	}
+}
+
+// packageNameFromLocation returns the package installed at a "packages" key,
+// the part after its last node_modules segment, or "" for keys outside
+// node_modules
+func packageNameFromLocation(location string) string {
+	idx := strings.LastIndex(location, "node_modules/")
+	if idx < 0 || (idx > 0 && location[idx-1] != '/') {
+		return ""
+	}
+	return location[idx+len("node_modules/"):]
+}
+
+func scanPackageLockJson(file *os.File, filePath string, addFinding func(Finding), verbose bool) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return
+	}
+	packages, graph, err := parsePackageLock(data)
+	if err != nil {
+		// Lockfiles with merge conflict markers are not JSON but still worth
+		// scanning
+		if verbose {
+			fmt.Printf("  ⚠️  Could not parse %s (%v), scanning it line by line\n", filePath, err)
+		}
+		scanPackageLockLines(strings.NewReader(string(data)), filePath, addFinding, verbose)
+		return
+	}
+	if graph.labels[""] == "" {
+		graph.addRoot("", projectLabel(filepath.Dir(filePath)))
+	}
+
+	for _, locked := range packages {
+		pkg := activeMatcher.lookup(locked.Name)
+		reportLockedIntegrity(pkg, locked.Version, locked.Integrity, filePath, locked.Location, addFinding, verbose)
+		if pkg == nil || !pkg.matchesVersion(locked.Version) {
+			continue
+		}
+		addFinding(Finding{
+			Package:    pkg.Name,
+			Version:    locked.Version,
+			Alias:      locked.Alias,
+			File:       filePath,
+			Location:   locked.Location,
+			Type:       "resolved",
+			IOC:        pkg,
+			Paths:      graph.paths(locked.Location, pkg.Name+"@"+locked.Version),
+			Dependents: graph.dependents(locked.Location),
+		})
+		if verbose {
+			fmt.Printf("  Found resolved %s@%s at %s in %s\n", pkg.Name, locked.Version, locked.Location, filePath)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const packageLockV1 = `{
+  "name": "app",
+  "version": "1.0.0",
+  "lockfileVersion": 1,
+  "requires": true,
+  "dependencies": {
+    "chalk": {
+      "version": "5.6.1",
+      "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz",
+      "integrity": "sha512-AAAA"
+    },
+    "debug": {
+      "version": "3.2.7",
+      "resolved": "https://registry.npmjs.org/debug/-/debug-3.2.7.tgz"
+    },
+    "foo": {
+      "version": "1.0.0",
+      "resolved": "https://registry.npmjs.org/foo/-/foo-1.0.0.tgz",
+      "requires": {"debug": "^4.0.0"},
+      "dependencies": {
+        "debug": {
+          "version": "4.4.2",
+          "resolved": "https://registry.npmjs.org/debug/-/debug-4.4.2.tgz"
+        }
+      }
+    }
+  }
+}`
+
+// packageLockV2 has both formats, disagreeing; the packages map wins
+const packageLockV2 = `{
+  "name": "app",
+  "version": "1.0.0",
+  "lockfileVersion": 2,
+  "requires": true,
+  "packages": {
+    "": {
+      "name": "app",
+      "version": "1.0.0",
+      "dependencies": {"chalk": "^5.0.0", "foo": "^1.0.0"}
+    },
+    "node_modules/chalk": {
+      "version": "5.6.1",
+      "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz",
+      "integrity": "sha512-AAAA"
+    },
+    "node_modules/debug": {
+      "version": "3.2.7",
+      "resolved": "https://registry.npmjs.org/debug/-/debug-3.2.7.tgz"
+    },
+    "node_modules/foo": {
+      "version": "1.0.0",
+      "resolved": "https://registry.npmjs.org/foo/-/foo-1.0.0.tgz",
+      "dependencies": {"debug": "^4.0.0"}
+    },
+    "node_modules/foo/node_modules/debug": {
+      "version": "4.4.2",
+      "resolved": "https://registry.npmjs.org/debug/-/debug-4.4.2.tgz"
+    }
+  },
+  "dependencies": {
+    "chalk": {"version": "5.3.0"},
+    "debug": {"version": "4.4.2"}
+  }
+}`
+
+// packageLockV3 is a workspace: packages/web is linked into node_modules
+// and nests its own chalk
+const packageLockV3 = `{
+  "name": "monorepo",
+  "lockfileVersion": 3,
+  "requires": true,
+  "packages": {
+    "": {
+      "name": "monorepo",
+      "workspaces": ["packages/*"],
+      "devDependencies": {"chalk": "^5.0.0"}
+    },
+    "node_modules/@acme/web": {
+      "resolved": "packages/web",
+      "link": true
+    },
+    "node_modules/chalk": {
+      "version": "5.3.0",
+      "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.3.0.tgz",
+      "dev": true
+    },
+    "node_modules/@ctrl/tinycolor": {
+      "version": "4.1.1",
+      "resolved": "https://registry.npmjs.org/@ctrl/tinycolor/-/tinycolor-4.1.1.tgz"
+    },
+    "packages/web": {
+      "name": "@acme/web",
+      "version": "0.1.0",
+      "dependencies": {"chalk": "5.6.1", "@ctrl/tinycolor": "^4.1.0"}
+    },
+    "packages/web/node_modules/chalk": {
+      "version": "5.6.1",
+      "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz"
+    }
+  }
+}`
+
+func TestParsePackageLock(t *testing.T) {
+	tests := []struct {
+		name string
+		lock string
+		want []string // "<location> <name>@<version>"
+	}{
+		// v1 nesting is reported with the key paths of the packages map
+		{"v1", packageLockV1, []string{
+			"node_modules/chalk chalk@5.6.1",
+			"node_modules/debug debug@3.2.7",
+			"node_modules/foo foo@1.0.0",
+			"node_modules/foo/node_modules/debug debug@4.4.2",
+		}},
+		// The legacy dependencies section is ignored when packages exists
+		{"v2", packageLockV2, []string{
+			"node_modules/chalk chalk@5.6.1",
+			"node_modules/debug debug@3.2.7",
+			"node_modules/foo foo@1.0.0",
+			"node_modules/foo/node_modules/debug debug@4.4.2",
+		}},
+		// The root, workspace folders and links to them are not packages
+		{"v3", packageLockV3, []string{
+			"node_modules/@ctrl/tinycolor @ctrl/tinycolor@4.1.1",
+			"node_modules/chalk chalk@5.3.0",
+			"packages/web/node_modules/chalk chalk@5.6.1",
+		}},
+	}
+	for _, tt := range tests {
+		packages, _, err := parsePackageLock([]byte(tt.lock))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, p := range packages {
+			got = append(got, fmt.Sprintf("%s %s@%s", p.Location, p.Name, p.Version))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, strings.Join(got, "\n     "), strings.Join(tt.want, "\n     "))
+		}
+	}
+}
+
+func TestScanPackageLockJson(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+	)
+	dir := t.TempDir()
+	scan := func(lock string) []Finding {
+		path := filepath.Join(dir, "package-lock.json")
+		if err := os.WriteFile(path, []byte(lock), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		var findings []Finding
+		scanPackageLockJson(file, path, func(f Finding) { findings = append(findings, f) }, false)
+		return findings
+	}
+
+	// Each installed copy is reported where it sits, not just by name, and
+	// a package name elsewhere in an entry (debug in foo's requirements) is
+	// not mistaken for an install
+	tests := []struct {
+		name string
+		lock string
+		want []string // "<location> <name>@<version>"
+	}{
+		{"v1", packageLockV1, []string{
+			"node_modules/chalk chalk@5.6.1",
+			"node_modules/foo/node_modules/debug debug@4.4.2",
+		}},
+		{"v2", packageLockV2, []string{
+			"node_modules/chalk chalk@5.6.1",
+			"node_modules/foo/node_modules/debug debug@4.4.2",
+		}},
+		{"v3", packageLockV3, []string{
+			"packages/web/node_modules/chalk chalk@5.6.1",
+		}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, f := range scan(tt.lock) {
+			if f.Type != "resolved" {
+				t.Errorf("%s: %s@%s has type %q", tt.name, f.Package, f.Version, f.Type)
+			}
+			got = append(got, fmt.Sprintf("%s %s@%s", f.Location, f.Package, f.Version))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, strings.Join(got, "\n     "), strings.Join(tt.want, "\n     "))
+		}
+	}
+
+	// Merge conflict markers make the file invalid JSON; both sides are
+	// still matched line by line
+	findings := scan(`{
+  "lockfileVersion": 3,
+  "packages": {
+    "node_modules/chalk": {
+<<<<<<< HEAD
+      "version": "5.3.0",
+      "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.3.0.tgz"
+=======
+      "version": "5.6.1",
+      "resolved": "https://registry.npmjs.org/chalk/-/chalk-5.6.1.tgz"
+>>>>>>> update-deps
+    }
+  }
+}`)
+	if len(findings) != 1 || findings[0].Package != "chalk" || findings[0].Version != "5.6.1" {
+		t.Errorf("conflicted lockfile: got %+v, want chalk@5.6.1 from the resolved URL", findings)
+	}
+}
+
+func TestPackageNameFromLocation(t *testing.T) {
+	tests := []struct {
+		location, want string
+	}{
+		{"", ""},
+		{"node_modules/chalk", "chalk"},
+		{"node_modules/@ctrl/tinycolor", "@ctrl/tinycolor"},
+		{"node_modules/a/node_modules/chalk", "chalk"},
+		{"packages/web/node_modules/@ctrl/tinycolor", "@ctrl/tinycolor"},
+		{"packages/web", ""},
+		{"my_node_modules/chalk", ""},
+	}
+	for _, tt := range tests {
+		if got := packageNameFromLocation(tt.location); got != tt.want {
+			t.Errorf("packageNameFromLocation(%q) = %q, want %q", tt.location, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxPayloadFileSize caps the files hashed because of a fileName hint alone
+const maxPayloadFileSize = 32 << 20
+
+// payloadIndex answers which files are worth hashing and which hashes are
+// known payloads
+type payloadIndex struct {
+	byHash map[string]*PayloadIOC
+	sizes  map[int64]bool
+	names  map[string]bool
+}
+
+// knownPayloads indexes the payload IOCs of the active IOC database
+var knownPayloads = newPayloadIndex(nil)
+
+func newPayloadIndex(payloads []PayloadIOC) *payloadIndex {
+	index := &payloadIndex{
+		byHash: make(map[string]*PayloadIOC),
+		sizes:  make(map[int64]bool),
+		names:  make(map[string]bool),
+	}
+	for i := range payloads {
+		payload := &payloads[i]
+		index.byHash[payload.SHA256] = payload
+		if payload.Size > 0 {
+			index.sizes[payload.Size] = true
+		}
+		if payload.FileName != "" {
+			index.names[strings.ToLower(payload.FileName)] = true
+		}
+	}
+	return index
+}
+
+func (index *payloadIndex) empty() bool {
+	return len(index.byHash) == 0
+}
+
+// isCandidate pre-filters files by size and name so only files that could
+// be a known payload get hashed
+func (index *payloadIndex) isCandidate(name string, size int64) bool {
+	if index.sizes[size] {
+		return true
+	}
+	return size <= maxPayloadFileSize && index.names[strings.ToLower(name)]
+}
+
+// isJSFile reports whether a file name has a JavaScript extension
+func isJSFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".js", ".cjs", ".mjs":
+		return true
+	}
+	return false
+}
+
+// match hashes r and returns the digest and the payload it is, if any
+func (index *payloadIndex) match(r io.Reader) (string, *PayloadIOC) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", nil
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	return sum, index.byHash[sum]
+}
+
+// scanPayloadFile hashes a candidate file and reports it if it is a known
+// malicious payload
+func scanPayloadFile(filePath string, addFinding func(Finding), verbose bool) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	sum, payload := knownPayloads.match(file)
+	if payload == nil {
+		return
+	}
+	addFinding(Finding{
+		Package: owningPackage(filePath),
+		Version: "sha256:" + sum[:12],
+		File:    filePath,
+		Type:    "payload",
+		Payload: payload,
+	})
+	if verbose {
+		fmt.Printf("  Found known payload %s (sha256 %s) at %s\n", payload.Description, sum, filePath)
+	}
+}
+
+// owningPackage derives the package a file belongs to from the last
+// node_modules segment of its path
+func owningPackage(filePath string) string {
+	parts := strings.Split(filepath.ToSlash(filePath), "/")
+	for i := len(parts) - 2; i >= 0; i-- {
+		if parts[i] != "node_modules" {
+			continue
+		}
+		if strings.HasPrefix(parts[i+1], "@") && i+2 < len(parts)-1 {
+			return parts[i+1] + "/" + parts[i+2]
+		}
+		if i+1 < len(parts)-1 {
+			return parts[i+1]
+		}
+	}
+	return filepath.Base(filePath)
+}
+
+// scanNodeModulesPayloads hashes candidate JS files inside every node_modules
+// directory under baseDir
+func scanNodeModulesPayloads(baseDir string, jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Finding), verbose bool) {
+	candidates := 0
+	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if !isJSFile(info.Name()) || !strings.Contains(filepath.ToSlash(path), "/node_modules/") {
+			return nil
+		}
+
+		if knownPayloads.isCandidate(info.Name(), info.Size()) {
+			candidates++
+			wg.Add(1)
+			jobs <- func() {
+				defer wg.Done()
+				scanPayloadFile(path, addFinding, verbose)
+			}
+		}
+		return nil
+	})
+	if verbose {
+		fmt.Printf("  🧬 Hashed %d candidate files under node_modules in %s\n", candidates, baseDir)
+	}
+}
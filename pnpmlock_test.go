@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParsePnpmPackageKey(t *testing.T) {
+	tests := []struct {
+		key           string
+		name, version string
+		ok            bool
+	}{
+		// v5
+		{"/chalk/5.6.1", "chalk", "5.6.1", true},
+		{"/@ctrl/tinycolor/4.1.1", "@ctrl/tinycolor", "4.1.1", true},
+		{"/debug/4.4.2_supports-color@8.1.1", "debug", "4.4.2", true},
+		{"/@babel/core/7.24.0_@types+node@20.0.0", "@babel/core", "7.24.0", true},
+		{"registry.npmjs.org/chalk/5.6.1", "chalk", "5.6.1", true},
+		{"registry.example.com/@ctrl/tinycolor/4.1.1", "@ctrl/tinycolor", "4.1.1", true},
+		// v6
+		{"/chalk@5.6.1", "chalk", "5.6.1", true},
+		{"/@ctrl/tinycolor@4.1.1", "@ctrl/tinycolor", "4.1.1", true},
+		{"/debug@4.4.2(supports-color@8.1.1)", "debug", "4.4.2", true},
+		{"/react-dom@18.2.0(react@18.2.0)(@types/react@18.0.0)", "react-dom", "18.2.0", true},
+		// v9
+		{"chalk@5.6.1", "chalk", "5.6.1", true},
+		{"@ctrl/tinycolor@4.1.1", "@ctrl/tinycolor", "4.1.1", true},
+		{"debug@4.4.2(supports-color@8.1.1)", "debug", "4.4.2", true},
+		{"chalk@5.6.1-beta.1", "chalk", "5.6.1-beta.1", true},
+		{"my-lib@file:packages/lib", "my-lib", "file:packages/lib", true},
+		// Not package keys
+		{"chalk", "", "", false},
+		{"/chalk", "", "", false},
+		{"/a/b/c/d", "", "", false},
+	}
+	for _, tt := range tests {
+		name, version, ok := parsePnpmPackageKey(tt.key)
+		if name != tt.name || version != tt.version || ok != tt.ok {
+			t.Errorf("parsePnpmPackageKey(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.key, name, version, ok, tt.name, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestPnpmDependencyKey(t *testing.T) {
+	tests := []struct {
+		major     int
+		name, ref string
+		want      string
+	}{
+		{5, "chalk", "5.6.1", "/chalk/5.6.1"},
+		{5, "debug", "4.4.2_supports-color@8.1.1", "/debug/4.4.2_supports-color@8.1.1"},
+		{5, "my-debug", "/debug/4.4.2", "/debug/4.4.2"},
+		{6, "chalk", "5.6.1", "/chalk@5.6.1"},
+		{6, "debug", "4.4.2(supports-color@8.1.1)", "/debug@4.4.2(supports-color@8.1.1)"},
+		{6, "my-debug", "/debug@4.4.2", "/debug@4.4.2"},
+		{9, "chalk", "5.6.1", "chalk@5.6.1"},
+		{9, "my-debug", "debug@4.4.2", "debug@4.4.2"},
+		{9, "lib", "link:../lib", ""},
+		{9, "lib", "file:../lib", ""},
+	}
+	for _, tt := range tests {
+		lock := &pnpmLock{major: tt.major}
+		if got := lock.dependencyKey(tt.name, tt.ref); got != tt.want {
+			t.Errorf("v%d dependencyKey(%q, %q) = %q, want %q", tt.major, tt.name, tt.ref, got, tt.want)
+		}
+	}
+}
+
+const pnpmLockV5 = `lockfileVersion: 5.4
+
+specifiers:
+  chalk: ^5.0.0
+  foo: ^1.0.0
+  my-debug: npm:debug@4.4.2
+
+dependencies:
+  chalk: 5.6.1
+  foo: 1.0.0
+  my-debug: /debug/4.4.2
+
+packages:
+
+  /chalk/5.6.1:
+    resolution: {integrity: sha512-AAAA}
+    engines: {node: ^12.17.0 || ^14.13 || >=16.0.0}
+    dev: false
+
+  /foo/1.0.0:
+    resolution: {integrity: sha512-BBBB}
+    dependencies:
+      debug: 4.4.2_supports-color@8.1.1
+    dev: false
+
+  /debug/4.4.2:
+    resolution: {integrity: sha512-CCCC}
+    dev: false
+
+  /debug/4.4.2_supports-color@8.1.1:
+    resolution: {integrity: sha512-CCCC}
+    dev: false
+
+  /@ctrl/tinycolor/4.1.1:
+    resolution: {integrity: sha512-DDDD}
+    dev: true
+`
+
+const pnpmLockV6 = `lockfileVersion: '6.0'
+
+settings:
+  autoInstallPeers: true
+
+dependencies:
+  chalk:
+    specifier: ^5.0.0
+    version: 5.6.1
+  foo:
+    specifier: ^1.0.0
+    version: 1.0.0
+  my-debug:
+    specifier: npm:debug@4.4.2
+    version: /debug@4.4.2
+
+packages:
+
+  /chalk@5.6.1:
+    resolution: {integrity: sha512-AAAA}
+    dev: false
+
+  /foo@1.0.0:
+    resolution: {integrity: sha512-BBBB}
+    dependencies:
+      debug: 4.4.2(supports-color@8.1.1)
+    dev: false
+
+  /debug@4.4.2:
+    resolution: {integrity: sha512-CCCC}
+    dev: false
+
+  /debug@4.4.2(supports-color@8.1.1):
+    resolution: {integrity: sha512-CCCC}
+    dev: false
+
+  /@ctrl/tinycolor@4.1.1:
+    resolution: {integrity: sha512-DDDD}
+    dev: true
+`
+
+const pnpmLockV9 = `lockfileVersion: '9.0'
+
+settings:
+  autoInstallPeers: true
+  excludeLinksFromLockfile: false
+
+importers:
+
+  .:
+    dependencies:
+      chalk:
+        specifier: ^5.0.0
+        version: 5.6.1
+      foo:
+        specifier: ^1.0.0
+        version: 1.0.0
+      my-debug:
+        specifier: npm:debug@4.4.2
+        version: debug@4.4.2
+
+packages:
+
+  '@ctrl/tinycolor@4.1.1':
+    resolution: {integrity: sha512-DDDD}
+
+  chalk@5.6.1:
+    resolution: {integrity: sha512-AAAA}
+    engines: {node: ^12.17.0 || ^14.13 || >=16.0.0}
+
+  debug@4.4.2:
+    resolution: {integrity: sha512-CCCC}
+
+  foo@1.0.0:
+    resolution: {integrity: sha512-BBBB}
+
+snapshots:
+
+  '@ctrl/tinycolor@4.1.1': {}
+
+  chalk@5.6.1: {}
+
+  debug@4.4.2: {}
+
+  debug@4.4.2(supports-color@8.1.1): {}
+
+  foo@1.0.0:
+    dependencies:
+      debug: 4.4.2(supports-color@8.1.1)
+`
+
+// scanPnpmFixture writes lock as pnpm-lock.yaml and returns the findings
+// as "<package>@<version> (<location>)", sorted
+func scanPnpmFixture(t *testing.T, lock string) []string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pnpm-lock.yaml")
+	if err := os.WriteFile(path, []byte(lock), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var got []string
+	scanPnpmLock(file, path, func(f Finding) {
+		got = append(got, fmt.Sprintf("%s@%s (%s)", f.Package, f.Version, f.Location))
+	}, false)
+	sort.Strings(got)
+	return got
+}
+
+func TestScanPnpmLock(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+		CompromisedPackage{Name: "@ctrl/tinycolor", Versions: []string{"4.1.1"}},
+	)
+
+	// Reached packages are reported by importer; debug, reached directly
+	// through an alias and again under a peer suffix through foo, is one
+	// install. The dev-only tinycolor no importer reaches is still listed.
+	tests := []struct {
+		name string
+		lock string
+		want []string
+	}{
+		{"v5", pnpmLockV5, []string{
+			"@ctrl/tinycolor@4.1.1 (/@ctrl/tinycolor/4.1.1)",
+			"chalk@5.6.1 (. > /chalk/5.6.1)",
+			"debug@4.4.2 (. > /debug/4.4.2)",
+		}},
+		{"v6", pnpmLockV6, []string{
+			"@ctrl/tinycolor@4.1.1 (/@ctrl/tinycolor@4.1.1)",
+			"chalk@5.6.1 (. > /chalk@5.6.1)",
+			"debug@4.4.2 (. > /debug@4.4.2)",
+		}},
+		{"v9", pnpmLockV9, []string{
+			"@ctrl/tinycolor@4.1.1 (@ctrl/tinycolor@4.1.1)",
+			"chalk@5.6.1 (. > chalk@5.6.1)",
+			"debug@4.4.2 (. > debug@4.4.2)",
+		}},
+	}
+	for _, tt := range tests {
+		if got := scanPnpmFixture(t, tt.lock); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, strings.Join(got, "\n     "), strings.Join(tt.want, "\n     "))
+		}
+	}
+}
+
+func TestScanPnpmLockWorkspaces(t *testing.T) {
+	setTestIOCs(t, CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}})
+
+	// The same install is reported once for every workspace using it
+	got := scanPnpmFixture(t, `lockfileVersion: '9.0'
+
+importers:
+
+  .: {}
+
+  packages/api:
+    dependencies:
+      chalk:
+        specifier: 5.6.1
+        version: 5.6.1
+
+  packages/web:
+    devDependencies:
+      chalk:
+        specifier: ^5.6.0
+        version: 5.6.1
+
+packages:
+
+  chalk@5.6.1:
+    resolution: {integrity: sha512-AAAA}
+
+snapshots:
+
+  chalk@5.6.1: {}
+`)
+	want := []string{
+		"chalk@5.6.1 (packages/api > chalk@5.6.1)",
+		"chalk@5.6.1 (packages/web > chalk@5.6.1)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParsePnpmLockErrors(t *testing.T) {
+	for _, lock := range []string{
+		"packages: {}\n",
+		"lockfileVersion: next\n",
+		"lockfileVersion: '9.0'\npackages:\n  chalk@5.6.1:\n   resolution: {integrity: x\n",
+	} {
+		if _, err := parsePnpmLock([]byte(lock)); err == nil {
+			t.Errorf("parsePnpmLock(%q) succeeded", lock)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pnpmStoreIndex is the metadata pnpm keeps for each package in its
+// content-addressable store: the digest of every file, by path. pnpm 8 and
+// later also record the package's name and version.
+type pnpmStoreIndex struct {
+	Name    string                   `json:"name"`
+	Version string                   `json:"version"`
+	Files   map[string]pnpmStoreFile `json:"files"`
+}
+
+type pnpmStoreFile struct {
+	Integrity string `json:"integrity"`
+	Mode      int    `json:"mode"`
+}
+
+// pnpmProject is a local project installed from a pnpm store, with the
+// packages of its node_modules/.pnpm virtual store
+type pnpmProject struct {
+	Dir      string
+	Packages []installedPackage
+}
+
+// pnpmStoreRoots returns the versioned stores under dir. `pnpm store path`
+// names one ("store/v3", "store/v10"), but older and newer layouts can sit
+// side by side under the same store folder.
+func pnpmStoreRoots(dir string) []string {
+	if isPnpmStoreRoot(dir) {
+		return []string{dir}
+	}
+	var roots []string
+	for _, child := range subdirectories(dir) {
+		if isPnpmStoreRoot(child) {
+			roots = append(roots, child)
+		}
+	}
+	return roots
+}
+
+func isPnpmStoreRoot(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "files"))
+	return err == nil && info.IsDir()
+}
+
+// scanPnpmStore reports the compromised packages held in a pnpm store, and
+// which local projects under baseDir hard-link them into their
+// node_modules/.pnpm. Stored files are named by digest, so packages are
+// identified from the store's index files: "files/xx/<hash>-index.json" in
+// v3 stores, "index/xx/<hash>-<name>@<version>.json" in v10 ones. Stored
+// files are hashed along the way when they could be a known payload.
+func scanPnpmStore(storeDir, baseDir string, addFinding func(Finding), verbose bool) {
+	var projects []pnpmProject
+	projectsRead := false
+	indexes := 0
+	for _, root := range pnpmStoreRoots(storeDir) {
+		for _, indexDir := range []string{"files", "index"} {
+			filepath.Walk(filepath.Join(root, indexDir), func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return nil
+				}
+				name := info.Name()
+				if indexDir == "files" && !strings.HasSuffix(name, "-index.json") {
+					// Stored files may be a known payload under any name
+					if knownPayloads.isCandidate(name, info.Size()) {
+						scanPayloadFile(path, addFinding, verbose)
+					}
+					return nil
+				}
+				if indexDir == "index" && !strings.HasSuffix(name, ".json") {
+					return nil
+				}
+				indexes++
+
+				index, ok := readPnpmStoreIndex(root, path)
+				if !ok {
+					return nil
+				}
+				pkg := activeMatcher.lookup(index.Name)
+				if pkg == nil || !pkg.matchesVersion(index.Version) {
+					return nil
+				}
+				if !projectsRead {
+					projects = findPnpmProjects(baseDir)
+					projectsRead = true
+				}
+				linked := linkedPnpmProjects(root, index, projects)
+				location := filepath.Base(root) + " store, not linked into any project under " + baseDir
+				if len(linked) > 0 {
+					location = filepath.Base(root) + " store, hard-linked into " + strings.Join(linked, ", ")
+				}
+				addFinding(Finding{
+					Package:  pkg.Name,
+					Version:  index.Version,
+					File:     path,
+					Location: location,
+					Type:     "cache",
+					IOC:      pkg,
+				})
+				if verbose {
+					fmt.Printf("  Found %s@%s in pnpm store: %s (%s)\n", pkg.Name, index.Version, path, location)
+				}
+				return nil
+			})
+		}
+	}
+	if verbose {
+		fmt.Printf("  📦 Read %d pnpm store index files in %s\n", indexes, storeDir)
+	}
+}
+
+// readPnpmStoreIndex reads an index file and works out the package it
+// describes: from its name and version fields, else from a v10 index file
+// name, else from the package.json it lists
+func readPnpmStoreIndex(root, path string) (*pnpmStoreIndex, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var index pnpmStoreIndex
+	if json.Unmarshal(data, &index) != nil {
+		return nil, false
+	}
+	if index.Name == "" || index.Version == "" {
+		// "<hash>-@ctrl+tinycolor@4.1.1.json", with "/" written as "+"
+		hash, id, ok := strings.Cut(strings.TrimSuffix(filepath.Base(path), ".json"), "-")
+		if ok && isHexString(hash) && id != "index" {
+			name := yarnSpecName(id)
+			version := strings.TrimPrefix(id[len(name):], "@")
+			if strings.HasPrefix(name, "@") {
+				name = strings.Replace(name, "+", "/", 1)
+			}
+			index.Name, index.Version = name, version
+		}
+	}
+	if index.Name == "" || index.Version == "" {
+		if content, ok := pnpmStoreContentPath(root, index.Files["package.json"]); ok {
+			if data, err := os.ReadFile(content); err == nil {
+				var manifest struct {
+					Name    string `json:"name"`
+					Version string `json:"version"`
+				}
+				json.Unmarshal(data, &manifest)
+				index.Name, index.Version = manifest.Name, manifest.Version
+			}
+		}
+	}
+	return &index, index.Name != "" && index.Version != ""
+}
+
+// pnpmStoreContentPath returns where a store keeps a file's content:
+// files/xx/<rest of the hex digest>, with "-exec" for executables
+func pnpmStoreContentPath(root string, file pnpmStoreFile) (string, bool) {
+	_, digest, ok := strings.Cut(file.Integrity, "-")
+	if !ok {
+		return "", false
+	}
+	raw, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil || len(raw) < 2 {
+		return "", false
+	}
+	sum := hex.EncodeToString(raw)
+	path := filepath.Join(root, "files", sum[:2], sum[2:])
+	if file.Mode&0o111 != 0 {
+		path += "-exec"
+	}
+	return path, true
+}
+
+// findPnpmProjects lists the projects under baseDir installed by pnpm,
+// with the packages of their virtual stores
+func findPnpmProjects(baseDir string) []pnpmProject {
+	var projects []pnpmProject
+	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if info.Name() != "node_modules" {
+			return nil
+		}
+		store := filepath.Join(path, ".pnpm")
+		if stat, err := os.Stat(store); err == nil && stat.IsDir() {
+			projects = append(projects, pnpmProject{
+				Dir:      filepath.Dir(path),
+				Packages: readVirtualStore(store, "node_modules/.pnpm"),
+			})
+		}
+		return filepath.SkipDir
+	})
+	return projects
+}
+
+// linkedPnpmProjects returns the projects whose virtual store holds a hard
+// link to the package's stored package.json, sorted
+func linkedPnpmProjects(root string, index *pnpmStoreIndex, projects []pnpmProject) []string {
+	content, ok := pnpmStoreContentPath(root, index.Files["package.json"])
+	if !ok {
+		return nil
+	}
+	stored, err := os.Stat(content)
+	if err != nil {
+		return nil
+	}
+	var linked []string
+	for _, project := range projects {
+		for _, installed := range project.Packages {
+			if installed.Name != index.Name || installed.Version != index.Version {
+				continue
+			}
+			if info, err := os.Stat(filepath.Join(installed.Dir, "package.json")); err == nil && os.SameFile(stored, info) {
+				linked = append(linked, project.Dir)
+				break
+			}
+		}
+	}
+	sort.Strings(linked)
+	return linked
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeStoreFile writes content to path under root, creating its folders
+func writeStoreFile(t *testing.T, root, path, content string) string {
+	t.Helper()
+	full := filepath.Join(root, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return full
+}
+
+// storeContent stores content under its sha512 digest, as pnpm does, and
+// returns the integrity an index file lists it with
+func storeContent(t *testing.T, root, content string) string {
+	t.Helper()
+	sum := sha512.Sum512([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+	writeStoreFile(t, root, "files/"+digest[:2]+"/"+digest[2:], content)
+	return "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestPnpmStoreRoots(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"store/v3/files", "store/v10/files", "store/v10/index", "store/tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []string
+	for _, root := range pnpmStoreRoots(filepath.Join(dir, "store")) {
+		rel, _ := filepath.Rel(dir, root)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	if want := []string{"store/v10", "store/v3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("roots of the store folder = %q, want %q", got, want)
+	}
+	// `pnpm store path` names a versioned store directly
+	if got := pnpmStoreRoots(filepath.Join(dir, "store", "v3")); len(got) != 1 || filepath.Base(got[0]) != "v3" {
+		t.Errorf("roots of store/v3 = %q", got)
+	}
+}
+
+func TestPnpmStoreContentPath(t *testing.T) {
+	tests := []struct {
+		file pnpmStoreFile
+		want string
+	}{
+		{pnpmStoreFile{Integrity: "sha512-q83vEjRWeJA=", Mode: 0o644}, "files/ab/cdef1234567890"},
+		{pnpmStoreFile{Integrity: "sha512-q83vEjRWeJA=", Mode: 0o755}, "files/ab/cdef1234567890-exec"},
+		{pnpmStoreFile{Integrity: "sha512-!!"}, ""},
+		{pnpmStoreFile{}, ""},
+	}
+	for _, tt := range tests {
+		got, ok := pnpmStoreContentPath("/store", tt.file)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("pnpmStoreContentPath(%+v) = %q, want none", tt.file, got)
+			}
+		} else if want := filepath.Join("/store", filepath.FromSlash(tt.want)); got != want {
+			t.Errorf("pnpmStoreContentPath(%+v) = %q, want %q", tt.file, got, want)
+		}
+	}
+}
+
+func TestReadPnpmStoreIndex(t *testing.T) {
+	root := t.TempDir()
+	manifest := storeContent(t, root, `{"name": "@ctrl/tinycolor", "version": "4.1.1"}`)
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"name fields", "files/aa/bbbb-index.json", `{"name": "chalk", "version": "5.6.1", "files": {}}`, "chalk@5.6.1"},
+		{"v10 file name", "index/aa/bbbb-@ctrl+tinycolor@4.1.1.json", `{"files": {}}`, "@ctrl/tinycolor@4.1.1"},
+		{"stored package.json", "files/aa/cccc-index.json", fmt.Sprintf(`{"files": {"package.json": {"integrity": %q, "mode": 420}}}`, manifest), "@ctrl/tinycolor@4.1.1"},
+		{"unknown", "files/aa/dddd-index.json", `{"files": {}}`, ""},
+		{"invalid", "files/aa/eeee-index.json", `{"files": `, ""},
+	}
+	for _, tt := range tests {
+		path := writeStoreFile(t, root, tt.file, tt.content)
+		got := ""
+		if index, ok := readPnpmStoreIndex(root, path); ok {
+			got = index.Name + "@" + index.Version
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScanPnpmStore(t *testing.T) {
+	setTestIOCs(t, CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}})
+	dir := t.TempDir()
+	root := filepath.Join(dir, "store", "v10")
+	manifestContent := `{"name": "chalk", "version": "5.6.1"}`
+	manifest := storeContent(t, root, manifestContent)
+	writeStoreFile(t, root, "index/ab/cdef-chalk@5.6.1.json", fmt.Sprintf(`{"files": {"package.json": {"integrity": %q, "mode": 420}}}`, manifest))
+	writeStoreFile(t, root, "index/ab/0123-debug@4.4.1.json", `{"files": {}}`)
+
+	// app hard-links the stored package.json; other has its own copy
+	projects := filepath.Join(dir, "projects")
+	sum := sha512.Sum512([]byte(manifestContent))
+	digest := hex.EncodeToString(sum[:])
+	linked := filepath.Join(projects, "app", "node_modules", ".pnpm", "chalk@5.6.1", "node_modules", "chalk", "package.json")
+	if err := os.MkdirAll(filepath.Dir(linked), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(root, "files", digest[:2], digest[2:]), linked); err != nil {
+		t.Skip("hard links unsupported:", err)
+	}
+	writeStoreFile(t, projects, "other/node_modules/.pnpm/chalk@5.6.1/node_modules/chalk/package.json", manifestContent)
+
+	var got []string
+	scanPnpmStore(filepath.Join(dir, "store"), projects, func(f Finding) {
+		got = append(got, f.Type+" "+f.Package+"@"+f.Version+" ("+f.Location+")")
+	}, false)
+	want := []string{"cache chalk@5.6.1 (v10 store, hard-linked into " + filepath.Join(projects, "app") + ")"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+
+	// Without the project, the stored package is still reported
+	got = nil
+	scanPnpmStore(filepath.Join(dir, "store"), filepath.Join(dir, "elsewhere"), func(f Finding) {
+		got = append(got, f.Location)
+	}, false)
+	if want := "v10 store, not linked into any project under " + filepath.Join(dir, "elsewhere"); len(got) != 1 || got[0] != want {
+		t.Errorf("unlinked store: got %q, want %q", got, want)
+	}
+}
+
+func TestScanPnpmStorePayload(t *testing.T) {
+	setTestIOCs(t, CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}})
+	payload := "/* known payload */"
+	payloadSum := sha256.Sum256([]byte(payload))
+	saved := knownPayloads
+	knownPayloads = newPayloadIndex([]PayloadIOC{{SHA256: hex.EncodeToString(payloadSum[:]), Size: int64(len(payload))}})
+	t.Cleanup(func() { knownPayloads = saved })
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "store", "v10")
+	manifest := storeContent(t, root, `{"name": "chalk", "version": "5.6.1"}`)
+	script := storeContent(t, root, payload)
+	writeStoreFile(t, root, "index/ab/cdef-chalk@5.6.1.json", fmt.Sprintf(
+		`{"files": {"package.json": {"integrity": %q, "mode": 420}, "index.js": {"integrity": %q, "mode": 420}}}`, manifest, script))
+
+	// Stored files are hashed in the same walk that reads the index files
+	var got []string
+	scanPnpmStore(filepath.Join(dir, "store"), filepath.Join(dir, "projects"), func(f Finding) {
+		got = append(got, f.Type+" "+f.Version)
+	}, false)
+	sort.Strings(got)
+	want := []string{"cache 5.6.1", "payload sha256:" + hex.EncodeToString(payloadSum[:])[:12]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SemVer is a parsed semantic version (https://semver.org)
+type SemVer struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      string
+}
+
+// parseSemVer parses a full MAJOR.MINOR.PATCH[-prerelease][+build] version.
+// A leading "v" or "=" is accepted, as npm does.
+func parseSemVer(s string) (SemVer, error) {
+	var v SemVer
+	raw := s
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "=")
+	s = strings.TrimPrefix(s, "v")
+
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		v.Build = s[i+1:]
+		s = s[:i]
+		if !isValidIdentifiers(v.Build, false) {
+			return SemVer{}, fmt.Errorf("invalid build metadata in version %q", raw)
+		}
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		pre := s[i+1:]
+		s = s[:i]
+		if !isValidIdentifiers(pre, true) {
+			return SemVer{}, fmt.Errorf("invalid prerelease in version %q", raw)
+		}
+		v.Prerelease = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return SemVer{}, fmt.Errorf("invalid version %q", raw)
+	}
+	nums := make([]uint64, 3)
+	for i, part := range parts {
+		n, ok := parseNumericIdentifier(part)
+		if !ok {
+			return SemVer{}, fmt.Errorf("invalid version %q", raw)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+// parseNumericIdentifier parses a version number without leading zeros
+func parseNumericIdentifier(s string) (uint64, bool) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// isValidIdentifiers checks dot-separated prerelease or build identifiers
+func isValidIdentifiers(s string, prerelease bool) bool {
+	if s == "" {
+		return false
+	}
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		numeric := true
+		for _, r := range id {
+			switch {
+			case r >= '0' && r <= '9':
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-':
+				numeric = false
+			default:
+				return false
+			}
+		}
+		if prerelease && numeric && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+func (v SemVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 following semver precedence. Build metadata is
+// ignored.
+func (v SemVer) Compare(o SemVer) int {
+	if c := compareUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	// A version without prerelease has higher precedence
+	switch {
+	case len(v.Prerelease) == 0 && len(o.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(o.Prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(o.Prerelease); i++ {
+		if c := comparePrereleaseIdentifier(v.Prerelease[i], o.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareUint(uint64(len(v.Prerelease)), uint64(len(o.Prerelease)))
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func comparePrereleaseIdentifier(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareUint(an, bn)
+	case aErr == nil:
+		return -1 // numeric identifiers sort before alphanumeric ones
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// comparator is a single "<op> version" constraint
+type comparator struct {
+	op      string // "<", "<=", ">", ">=", "="
+	version SemVer
+}
+
+func (c comparator) matches(v SemVer) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// SemVerRange is an npm-style range: a union ("||") of comparator sets, where
+// every comparator in a set must match
+type SemVerRange [][]comparator
+
+// parseSemVerRange parses npm range syntax: exact versions, primitives
+// (">=1.2.3"), x-ranges ("1.2.x", "1.x", "*"), tilde, caret, hyphen ranges
+// ("1.2.3 - 1.2.9") and unions joined with "||"
+func parseSemVerRange(s string) (SemVerRange, error) {
+	var r SemVerRange
+	parts := strings.Split(s, "||")
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		// An empty range means any version, but an empty alternative is a
+		// truncated range rather than a wildcard
+		if part == "" && len(parts) > 1 {
+			return nil, fmt.Errorf("invalid range %q: empty alternative", s)
+		}
+		set, err := parseComparatorSet(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %w", s, err)
+		}
+		r = append(r, set)
+	}
+	return r, nil
+}
+
+// Matches reports whether v satisfies the range. As in npm, a prerelease
+// version only matches a comparator set that names a prerelease of the same
+// MAJOR.MINOR.PATCH.
+func (r SemVerRange) Matches(v SemVer) bool {
+	for _, set := range r {
+		if comparatorSetMatches(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func comparatorSetMatches(set []comparator, v SemVer) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+	if len(v.Prerelease) == 0 {
+		return true
+	}
+	for _, c := range set {
+		if len(c.version.Prerelease) > 0 &&
+			c.version.Major == v.Major && c.version.Minor == v.Minor && c.version.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// Intersects reports whether some version satisfies both ranges. Ranges
+// are compared as intervals, so prerelease rules are not applied.
+func (r SemVerRange) Intersects(o SemVerRange) bool {
+	for _, a := range r {
+		for _, b := range o {
+			if comparatorSetSatisfiable(append(append([]comparator{}, a...), b...)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// comparatorSetSatisfiable reports whether the interval left by all of a
+// set's comparators is non-empty
+func comparatorSetSatisfiable(set []comparator) bool {
+	var lower, upper *comparator
+	for i := range set {
+		c := &set[i]
+		if c.op == ">" || c.op == ">=" || c.op == "=" {
+			if lower == nil || c.version.Compare(lower.version) > 0 || (c.version.Compare(lower.version) == 0 && c.op == ">") {
+				lower = c
+			}
+		}
+		if c.op == "<" || c.op == "<=" || c.op == "=" {
+			if upper == nil || c.version.Compare(upper.version) < 0 || (c.version.Compare(upper.version) == 0 && c.op == "<") {
+				upper = c
+			}
+		}
+	}
+	if lower == nil || upper == nil {
+		return true
+	}
+	cmp := lower.version.Compare(upper.version)
+	return cmp < 0 || (cmp == 0 && lower.op != ">" && upper.op != "<")
+}
+
+func parseComparatorSet(s string) ([]comparator, error) {
+	if s == "" {
+		return anyVersion(), nil
+	}
+
+	// Hyphen range: "1.2.3 - 2.3.4"
+	if fields := strings.Fields(s); len(fields) == 3 && fields[1] == "-" {
+		return parseHyphenRange(fields[0], fields[2])
+	}
+
+	var set []comparator
+	tokens := strings.Fields(s)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		// Allow a space between an operator and its version (">= 1.2.3")
+		if isRangeOperator(token) && i+1 < len(tokens) {
+			token += tokens[i+1]
+			i++
+		}
+		comparators, err := parseSimpleRange(token)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, comparators...)
+	}
+	return set, nil
+}
+
+func isRangeOperator(s string) bool {
+	switch s {
+	case "<", "<=", ">", ">=", "=", "~", "^":
+		return true
+	}
+	return false
+}
+
+func anyVersion() []comparator {
+	return []comparator{{op: ">=", version: SemVer{}}}
+}
+
+// partialVersion is a possibly incomplete version such as "1", "1.2" or
+// "1.x". Its last component may also end in wildcard digits: "9.0.4x" covers
+// 9.0.40 to 9.0.49.
+type partialVersion struct {
+	major, minor, patch uint64
+	parts               int // number of non-wildcard components (0-3)
+	prerelease          []string
+	// step is how many values the last component covers, 0 when it is a
+	// plain number
+	step uint64
+}
+
+// complete reports whether the partial names a single version
+func (p partialVersion) complete() bool {
+	return p.parts == 3 && p.step == 0
+}
+
+func parsePartialVersion(s string) (partialVersion, error) {
+	var p partialVersion
+	raw := s
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		pre := s[i+1:]
+		s = s[:i]
+		if !isValidIdentifiers(pre, true) {
+			return p, fmt.Errorf("invalid prerelease in %q", raw)
+		}
+		p.prerelease = strings.Split(pre, ".")
+	}
+
+	components := strings.Split(s, ".")
+	if len(components) > 3 {
+		return p, fmt.Errorf("invalid version %q", raw)
+	}
+	nums := []*uint64{&p.major, &p.minor, &p.patch}
+	wildcard := false
+	for i, c := range components {
+		if c == "x" || c == "X" || c == "*" {
+			wildcard = true
+			continue
+		}
+		if wildcard {
+			return p, fmt.Errorf("invalid version %q", raw)
+		}
+		// Wildcard digits, "4x" or "4xx", end the version like "x" does
+		digits := strings.TrimRight(c, "xX")
+		n, ok := parseNumericIdentifier(digits)
+		if !ok || n == 0 && digits != c {
+			return p, fmt.Errorf("invalid version %q", raw)
+		}
+		if digits != c {
+			p.step = 1
+			for range c[len(digits):] {
+				p.step *= 10
+			}
+			n *= p.step
+			wildcard = true
+		}
+		*nums[i] = n
+		p.parts = i + 1
+	}
+	if !p.complete() && len(p.prerelease) > 0 {
+		return p, fmt.Errorf("prerelease on partial version %q", raw)
+	}
+	return p, nil
+}
+
+func (p partialVersion) lower() SemVer {
+	return SemVer{Major: p.major, Minor: p.minor, Patch: p.patch, Prerelease: p.prerelease}
+}
+
+// upperExclusive is the smallest version above every version the partial
+// covers, e.g. "1.2" -> "1.3.0-0", "9.0.4x" -> "9.0.50-0"
+func (p partialVersion) upperExclusive() SemVer {
+	if p.step > 0 {
+		upper := SemVer{Major: p.major, Minor: p.minor, Patch: p.patch, Prerelease: []string{"0"}}
+		switch p.parts {
+		case 1:
+			upper.Major += p.step
+		case 2:
+			upper.Minor += p.step
+		default:
+			upper.Patch += p.step
+		}
+		return upper
+	}
+	switch p.parts {
+	case 1:
+		return SemVer{Major: p.major + 1, Prerelease: []string{"0"}}
+	case 2:
+		return SemVer{Major: p.major, Minor: p.minor + 1, Prerelease: []string{"0"}}
+	}
+	return SemVer{}
+}
+
+func parseSimpleRange(s string) ([]comparator, error) {
+	switch {
+	case strings.HasPrefix(s, "^"):
+		return parseCaretRange(s[1:])
+	case strings.HasPrefix(s, "~>"):
+		return parseTildeRange(s[2:])
+	case strings.HasPrefix(s, "~"):
+		return parseTildeRange(s[1:])
+	}
+
+	op := ""
+	for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(s, candidate) {
+			op = candidate
+			s = s[len(candidate):]
+			break
+		}
+	}
+
+	p, err := parsePartialVersion(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.complete() {
+		if op == "" {
+			op = "="
+		}
+		return []comparator{{op: op, version: p.lower()}}, nil
+	}
+
+	// Partial versions desugar the same way npm does
+	switch op {
+	case "", "=":
+		if p.parts == 0 {
+			return anyVersion(), nil
+		}
+		return []comparator{{op: ">=", version: p.lower()}, {op: "<", version: p.upperExclusive()}}, nil
+	case ">":
+		if p.parts == 0 {
+			return []comparator{{op: "<", version: SemVer{Prerelease: []string{"0"}}}}, nil
+		}
+		return []comparator{{op: ">=", version: p.upperExclusive()}}, nil
+	case ">=":
+		return []comparator{{op: ">=", version: p.lower()}}, nil
+	case "<":
+		if p.parts == 0 {
+			return []comparator{{op: "<", version: SemVer{Prerelease: []string{"0"}}}}, nil
+		}
+		below := p.lower()
+		below.Prerelease = []string{"0"}
+		return []comparator{{op: "<", version: below}}, nil
+	default: // "<="
+		if p.parts == 0 {
+			return anyVersion(), nil
+		}
+		return []comparator{{op: "<", version: p.upperExclusive()}}, nil
+	}
+}
+
+// parseTildeRange allows patch-level changes: ~1.2.3 := >=1.2.3 <1.3.0-0
+func parseTildeRange(s string) ([]comparator, error) {
+	p, err := parsePartialVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	switch p.parts {
+	case 0:
+		return anyVersion(), nil
+	case 1:
+		return []comparator{{op: ">=", version: p.lower()}, {op: "<", version: SemVer{Major: p.major + 1, Prerelease: []string{"0"}}}}, nil
+	}
+	return []comparator{{op: ">=", version: p.lower()}, {op: "<", version: SemVer{Major: p.major, Minor: p.minor + 1, Prerelease: []string{"0"}}}}, nil
+}
+
+// parseCaretRange allows changes that do not modify the left-most non-zero
+// component: ^1.2.3 := >=1.2.3 <2.0.0-0, ^0.2.3 := >=0.2.3 <0.3.0-0
+func parseCaretRange(s string) ([]comparator, error) {
+	p, err := parsePartialVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	lower := comparator{op: ">=", version: p.lower()}
+
+	var upper SemVer
+	switch {
+	case p.parts == 0:
+		return anyVersion(), nil
+	case p.major > 0 || p.parts == 1:
+		upper = SemVer{Major: p.major + 1}
+	case p.minor > 0 || p.parts == 2:
+		upper = SemVer{Major: 0, Minor: p.minor + 1}
+	default:
+		upper = SemVer{Major: 0, Minor: 0, Patch: p.patch + 1}
+	}
+	upper.Prerelease = []string{"0"}
+	return []comparator{lower, {op: "<", version: upper}}, nil
+}
+
+func parseHyphenRange(from, to string) ([]comparator, error) {
+	lowerPartial, err := parsePartialVersion(from)
+	if err != nil {
+		return nil, err
+	}
+	upperPartial, err := parsePartialVersion(to)
+	if err != nil {
+		return nil, err
+	}
+
+	set := []comparator{{op: ">=", version: lowerPartial.lower()}}
+	switch {
+	case upperPartial.parts == 0:
+	case upperPartial.complete():
+		set = append(set, comparator{op: "<=", version: upperPartial.lower()})
+	default:
+		set = append(set, comparator{op: "<", version: upperPartial.upperExclusive()})
+	}
+	return set, nil
+}
+
+// extractVersionAt returns the MAJOR.MINOR.PATCH[-prerelease][+build] token
+// starting at s[i], or "" when s[i:] does not start with a version
+func extractVersionAt(s string, i int) string {
+	j := i
+	for dots := 0; ; dots++ {
+		start := j
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		if j == start {
+			return ""
+		}
+		if dots == 2 {
+			break
+		}
+		if j >= len(s) || s[j] != '.' {
+			return ""
+		}
+		j++
+	}
+
+	if j < len(s) && (s[j] == '-' || s[j] == '+') {
+		k := j + 1
+		for k < len(s) {
+			c := s[k]
+			if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '.' || c == '-' || c == '+' {
+				k++
+				continue
+			}
+			break
+		}
+		if suffix := strings.TrimRight(s[j:k], ".-+"); len(suffix) > 1 {
+			j += len(suffix)
+		}
+	}
+	return s[i:j]
+}
@@ -0,0 +1,270 @@
+package main
+
+import "testing"
+
+func mustSemVer(t *testing.T, s string) SemVer {
+	t.Helper()
+	v, err := parseSemVer(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func mustRange(t *testing.T, s string) SemVerRange {
+	t.Helper()
+	r, err := parseSemVerRange(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestParseSemVer(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // normalized, "" for an error
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v1.2.3", "1.2.3"},
+		{"=1.2.3", "1.2.3"},
+		{" 1.2.3 ", "1.2.3"},
+		{"0.0.0", "0.0.0"},
+		{"1.2.3-beta.1", "1.2.3-beta.1"},
+		{"1.2.3-0", "1.2.3-0"},
+		{"1.2.3-x-y.z", "1.2.3-x-y.z"},
+		{"1.2.3+build.5", "1.2.3+build.5"},
+		{"1.2.3-rc.1+001", "1.2.3-rc.1+001"},
+		{"1.2", ""},
+		{"1.2.3.4", ""},
+		{"01.2.3", ""},
+		{"1.2.3-01", ""},
+		{"1.2.3-", ""},
+		{"1.2.3-beta..1", ""},
+		{"1.2.3+", ""},
+		{"1.2.x", ""},
+		{"9.0.4x", ""},
+		{"a.b.c", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		v, err := parseSemVer(tt.in)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("parseSemVer(%q) = %s, want an error", tt.in, v)
+		case tt.want != "" && err != nil:
+			t.Errorf("parseSemVer(%q) failed: %v", tt.in, err)
+		case tt.want != "" && v.String() != tt.want:
+			t.Errorf("parseSemVer(%q) = %s, want %s", tt.in, v, tt.want)
+		}
+	}
+}
+
+func TestSemVerCompare(t *testing.T) {
+	// Each version has lower precedence than the next
+	ordered := []string{
+		"1.0.0-0",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := mustSemVer(t, ordered[i]).Compare(mustSemVer(t, ordered[j])); got != want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+	if got := mustSemVer(t, "1.0.0+a").Compare(mustSemVer(t, "1.0.0+b")); got != 0 {
+		t.Errorf("build metadata changed precedence: %d", got)
+	}
+}
+
+func TestSemVerRangeMatches(t *testing.T) {
+	tests := []struct {
+		r     string
+		match []string
+		miss  []string
+	}{
+		{"1.2.3", []string{"1.2.3", "v1.2.3", "1.2.3+build"}, []string{"1.2.4", "1.2.3-beta"}},
+		{"=1.2.3", []string{"1.2.3"}, []string{"1.2.2"}},
+		{">=9.0.35 <=9.0.48", []string{"9.0.35", "9.0.40", "9.0.48"}, []string{"9.0.34", "9.0.49", "9.1.0"}},
+		{">= 1.0.0 < 2.0.0", []string{"1.0.0", "1.9.9"}, []string{"2.0.0", "0.9.9"}},
+		{">1.2.3", []string{"1.2.4", "2.0.0"}, []string{"1.2.3"}},
+		{"<1.2.3", []string{"1.2.2", "0.0.1"}, []string{"1.2.3"}},
+		// x-ranges
+		{"*", []string{"0.0.0", "99.1.1"}, []string{"1.0.0-beta"}},
+		{"", []string{"1.0.0"}, nil},
+		{"1.x", []string{"1.0.0", "1.99.99"}, []string{"2.0.0", "0.9.0"}},
+		{"1.2.x", []string{"1.2.0", "1.2.99"}, []string{"1.3.0"}},
+		{"1.2.*", []string{"1.2.7"}, []string{"1.1.9"}},
+		{"1", []string{"1.5.0"}, []string{"2.0.0"}},
+		{"1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{"<1.2", []string{"1.1.9"}, []string{"1.2.0"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		// Wildcard digits
+		{"9.0.4x", []string{"9.0.40", "9.0.45", "9.0.49"}, []string{"9.0.4", "9.0.39", "9.0.50", "9.0.400"}},
+		{"1.1x", []string{"1.10.0", "1.19.3"}, []string{"1.1.0", "1.20.0"}},
+		{"2x", []string{"20.0.0", "29.9.9"}, []string{"2.0.0", "30.0.0"}},
+		{"1.2.3xx", []string{"1.2.300", "1.2.399"}, []string{"1.2.3", "1.2.30", "1.2.400"}},
+		{"<9.0.4x", []string{"9.0.39"}, []string{"9.0.40"}},
+		{">9.0.4x", []string{"9.0.50"}, []string{"9.0.49"}},
+		{"<=9.0.4x", []string{"9.0.49"}, []string{"9.0.50"}},
+		{"9.0.3x - 9.0.4x", []string{"9.0.30", "9.0.49"}, []string{"9.0.29", "9.0.50"}},
+		// Tilde and caret
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"~1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0"}},
+		{"~1", []string{"1.9.0"}, []string{"2.0.0"}},
+		{"~>1.2.3", []string{"1.2.5"}, []string{"1.3.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.9.9"}, []string{"2.0.0", "1.2.2"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^0.x", []string{"0.9.9"}, []string{"1.0.0"}},
+		{"^1.x", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		// Hyphen ranges
+		{"1.2.3 - 2.3.4", []string{"1.2.3", "2.3.4"}, []string{"1.2.2", "2.3.5"}},
+		{"1.2 - 2.3", []string{"1.2.0", "2.3.9"}, []string{"2.4.0"}},
+		{"1.2.3 - *", []string{"99.0.0"}, []string{"1.2.2"}},
+		// Unions
+		{"1.2.x || 2.0.0 - 2.0.3", []string{"1.2.5", "2.0.3"}, []string{"2.0.4", "1.3.0"}},
+		{"1.0.0 || 1.0.2", []string{"1.0.0", "1.0.2"}, []string{"1.0.1"}},
+		// Prereleases only match a range naming a prerelease of the same
+		// MAJOR.MINOR.PATCH
+		{"3.0.1-beta.2", []string{"3.0.1-beta.2"}, []string{"3.0.1-beta.3", "3.0.1"}},
+		{">=1.2.3-alpha.1 <1.3.0", []string{"1.2.3-alpha.1", "1.2.3-beta", "1.2.3", "1.2.9"}, []string{"1.2.4-alpha.1", "1.2.3-alpha.0"}},
+		{"^1.2.3-beta.1", []string{"1.2.3-beta.2", "1.5.0"}, []string{"1.5.0-beta.1", "1.2.3-alpha"}},
+		{">1.0.0", []string{"1.0.1"}, []string{"1.0.1-rc.1", "2.0.0-0"}},
+		{"<2.0.0", []string{"1.9.9"}, []string{"1.9.9-rc.1"}},
+	}
+	for _, tt := range tests {
+		r := mustRange(t, tt.r)
+		for _, version := range tt.match {
+			if !r.Matches(mustSemVer(t, version)) {
+				t.Errorf("%q does not match %s", tt.r, version)
+			}
+		}
+		for _, version := range tt.miss {
+			if r.Matches(mustSemVer(t, version)) {
+				t.Errorf("%q matches %s", tt.r, version)
+			}
+		}
+	}
+}
+
+func TestParseSemVerRangeErrors(t *testing.T) {
+	for _, s := range []string{
+		"1.2.3.4",
+		"01.2.3",
+		"1.x.3",
+		"^1.2.3.4",
+		">=abc",
+		"9.0.4x.1",
+		"9.4x.1",
+		"9.0.0x",
+		"9.0.xx",
+		"9.0.4y",
+		"1.2.4x-beta",
+		"1.2-beta",
+		"1.2.3 - 2.3.4.5",
+		"1.2.3 || >=foo",
+		"4.4.2 ||",
+		"|| 4.4.2",
+		"4.4.2 || || 4.4.3",
+	} {
+		if r, err := parseSemVerRange(s); err == nil {
+			t.Errorf("parseSemVerRange(%q) = %v, want an error", s, r)
+		}
+	}
+}
+
+func TestSemVerRangeIntersects(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"^5.0.0", "5.6.1", true},
+		{"^5.0.0", "4.4.2", false},
+		{"~4.4.0", "4.4.2", true},
+		{"~4.3.0", "4.4.2", false},
+		{"*", "1.0.0", true},
+		{">=9.0.35 <=9.0.48", "^9.0.40", true},
+		{">=9.0.35 <=9.0.48", "9.0.4x", true},
+		{"<9.0.40", "9.0.4x", false},
+		{"9.0.49", "9.0.4x", true},
+		{"9.0.50", "9.0.4x", false},
+		{"1.2.3 - 1.2.5", "1.2.5 - 1.2.9", true},
+		{"<1.2.5", ">=1.2.5", false},
+		{"<=1.2.5", ">=1.2.5", true},
+		{">1.2.5", "<=1.2.5", false},
+		{"1.x || 3.x", "3.1.0", true},
+		{"1.x || 3.x", "2.x", false},
+		// Prerelease rules are not applied
+		{"^1.0.0", "1.5.0-beta", true},
+	}
+	for _, tt := range tests {
+		a, b := mustRange(t, tt.a), mustRange(t, tt.b)
+		if got := a.Intersects(b); got != tt.want {
+			t.Errorf("%q.Intersects(%q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := b.Intersects(a); got != tt.want {
+			t.Errorf("%q.Intersects(%q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestExtractVersionAt(t *testing.T) {
+	tests := []struct {
+		s    string
+		i    int
+		want string
+	}{
+		{"1.2.3", 0, "1.2.3"},
+		{"chalk@5.6.1,", 6, "5.6.1"},
+		{"chalk-5.6.1.tgz", 6, "5.6.1"},
+		{"1.2.3-beta.1\"", 0, "1.2.3-beta.1"},
+		{"1.2.3+build.7 ", 0, "1.2.3+build.7"},
+		{"1.2.3-.", 0, "1.2.3"},
+		{"1.2.3-", 0, "1.2.3"},
+		{"1.2", 0, ""},
+		{"1.2.", 0, ""},
+		{"v1.2.3", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := extractVersionAt(tt.s, tt.i); got != tt.want {
+			t.Errorf("extractVersionAt(%q, %d) = %q, want %q", tt.s, tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestCompromisedPackageMatchesVersion(t *testing.T) {
+	pkg := CompromisedPackage{Name: "@operato/input", Versions: []string{"9.0.4x", "9.1.0", "^10.0.0-beta.1"}}
+	if err := pkg.compile(); err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []string{"9.0.40", "9.0.49", "9.1.0", "v9.1.0", "10.0.0-beta.2", "10.2.0"} {
+		if !pkg.matchesVersion(version) {
+			t.Errorf("matchesVersion(%s) = false", version)
+		}
+	}
+	for _, version := range []string{"9.0.4", "9.0.50", "9.1.1", "10.0.0-alpha", "11.0.0", "latest", ""} {
+		if pkg.matchesVersion(version) {
+			t.Errorf("matchesVersion(%s) = true", version)
+		}
+	}
+}
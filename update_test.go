@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// iocFeedServer serves a bundle signed by the given key, answering
+// conditional requests for etag with 304
+func iocFeedServer(t *testing.T, bundle []byte, signer byte, etag string) (*httptest.Server, *int) {
+	t.Helper()
+	sig, err := json.Marshal(signIOCBundle(bundle, testIOCKey(signer)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var downloads int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/iocs.json":
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			downloads++
+			w.Header().Set("ETag", etag)
+			w.Write(bundle)
+		case "/iocs.json" + iocSignatureSuffix:
+			w.Write(sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &downloads
+}
+
+// useTempIOCCache points the per-user IOC cache at a fresh directory and
+// trusts only the key with seed 1
+func useTempIOCCache(t *testing.T) string {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv(iocFeedURLEnv, "")
+	saved := trustedIOCKeys
+	trustedIOCKeys = base64.StdEncoding.EncodeToString(testIOCKey(1).Public().(ed25519.PublicKey))
+	t.Cleanup(func() { trustedIOCKeys = saved })
+	dir, err := iocCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+const feedBundle = `{"schemaVersion": 2, "generated": "2099-01-01", "source": "feed", "packages": [{"name": "left-pad", "versions": ["1.3.1"]}]}`
+
+func TestUpdateIOCBundle(t *testing.T) {
+	cacheDir := useTempIOCCache(t)
+	server, downloads := iocFeedServer(t, []byte(feedBundle), 1, `"v1"`)
+	url := server.URL + "/iocs.json"
+
+	// A 200 stores the bundle, its signature and the ETag
+	result, err := updateIOCBundle(url, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NotModified || result.DB.Source != "feed" {
+		t.Errorf("first update = %+v, want the downloaded bundle", result)
+	}
+	if meta := readIOCFeedMeta(cacheDir); meta.URL != url || meta.ETag != `"v1"` {
+		t.Errorf("feed metadata = %+v, want the URL and ETag stored", meta)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, cachedIOCBundle+iocSignatureSuffix)); err != nil {
+		t.Errorf("signature not cached: %v", err)
+	}
+
+	// A 304 reuses the cached bundle without downloading it again
+	result, err = updateIOCBundle(url, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.NotModified || result.DB.Source != "feed" || *downloads != 1 {
+		t.Errorf("second update = %+v after %d downloads, want a cache hit", result, *downloads)
+	}
+
+	// Scans now prefer the newer cached bundle over the embedded one
+	db, err := selectIOCDatabase(ScanConfig{})
+	if err != nil || db.Source != "feed" {
+		t.Errorf("selectIOCDatabase = %v, %v, want the cached bundle", db, err)
+	}
+}
+
+func TestUpdateIOCBundleFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		serve func(t *testing.T) string
+	}{
+		{"untrusted signature", func(t *testing.T) string {
+			server, _ := iocFeedServer(t, []byte(feedBundle), 2, `"v1"`)
+			return server.URL + "/iocs.json"
+		}},
+		{"network error", func(t *testing.T) string {
+			server, _ := iocFeedServer(t, []byte(feedBundle), 1, `"v1"`)
+			server.Close()
+			return server.URL + "/iocs.json"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cacheDir := useTempIOCCache(t)
+			url := tt.serve(t)
+			if _, err := updateIOCBundle(url, nil, false); err == nil {
+				t.Fatal("update succeeded")
+			}
+			if _, err := os.Stat(cacheDir); !os.IsNotExist(err) {
+				t.Errorf("failed update touched the cache: %v", err)
+			}
+
+			// A scan asked to update falls back to the embedded bundle
+			t.Setenv(iocFeedURLEnv, url)
+			db, err := selectIOCDatabase(ScanConfig{UpdateIOC: true})
+			if err != nil || db.origin != "embedded" {
+				t.Errorf("selectIOCDatabase = %v, %v, want the embedded bundle", db, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// monorepo is a project whose package.json, pnpm-workspace.yaml or
+// lerna/nx/turbo config declares workspace packages
+type monorepo struct {
+	Root string
+	// Sources are the files the workspaces were declared in, such as
+	// "package.json" or "pnpm-workspace.yaml"
+	Sources  []string
+	Packages []workspacePackage
+}
+
+// workspacePackage is one package of a monorepo
+type workspacePackage struct {
+	Name string
+	Dir  string // absolute
+}
+
+// workspaceManifest is the part of package.json declaring workspaces, an
+// array of globs or Yarn's {"packages": [...]}
+type workspaceManifest struct {
+	Workspaces json.RawMessage `json:"workspaces"`
+}
+
+// detectWorkspaces returns the monorepo rooted at root, or nil if root
+// declares no workspace packages
+func detectWorkspaces(root string) *monorepo {
+	var patterns []string
+	var sources []string
+	addSource := func(source string, globs []string) {
+		sources = append(sources, source)
+		patterns = append(patterns, globs...)
+	}
+
+	// npm, Yarn and Bun workspaces
+	if data, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil {
+		var manifest workspaceManifest
+		if json.Unmarshal(data, &manifest) == nil && len(manifest.Workspaces) > 0 {
+			var globs []string
+			if json.Unmarshal(manifest.Workspaces, &globs) != nil {
+				var yarn struct {
+					Packages []string `json:"packages"`
+				}
+				json.Unmarshal(manifest.Workspaces, &yarn)
+				globs = yarn.Packages
+			}
+			if len(globs) > 0 {
+				addSource("package.json", globs)
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml")); err == nil {
+		if node, err := parseYAML(data); err == nil {
+			var globs []string
+			for _, item := range node.items("packages") {
+				if item.Scalar != "" {
+					globs = append(globs, item.Scalar)
+				}
+			}
+			addSource("pnpm-workspace.yaml", globs)
+		}
+	}
+
+	// Lerna defaults to packages/*
+	if data, err := os.ReadFile(filepath.Join(root, "lerna.json")); err == nil {
+		var lerna struct {
+			Packages []string `json:"packages"`
+		}
+		json.Unmarshal(data, &lerna)
+		if len(lerna.Packages) == 0 {
+			lerna.Packages = []string{"packages/*"}
+		}
+		addSource("lerna.json", lerna.Packages)
+	}
+
+	// Nx lists projects in workspace.json, or finds them by their
+	// project.json under the default apps/libs/packages layout
+	if _, err := os.Stat(filepath.Join(root, "nx.json")); err == nil {
+		globs := []string{"apps/*", "libs/*", "packages/*"}
+		if data, err := os.ReadFile(filepath.Join(root, "workspace.json")); err == nil {
+			var workspace struct {
+				Projects map[string]json.RawMessage `json:"projects"`
+			}
+			json.Unmarshal(data, &workspace)
+			for _, project := range workspace.Projects {
+				var dir string
+				if json.Unmarshal(project, &dir) != nil {
+					var config struct {
+						Root string `json:"root"`
+					}
+					json.Unmarshal(project, &config)
+					dir = config.Root
+				}
+				if dir != "" {
+					globs = append(globs, dir)
+				}
+			}
+		}
+		addSource("nx.json", globs)
+	}
+
+	// Turborepo reuses the package manager's workspaces
+	if _, err := os.Stat(filepath.Join(root, "turbo.json")); err == nil && len(sources) > 0 {
+		sources = append(sources, "turbo.json")
+	}
+
+	if len(patterns) == 0 {
+		return nil
+	}
+	repo := &monorepo{Root: root, Sources: sources}
+	seen := make(map[string]bool)
+	for _, dir := range expandWorkspaceGlobs(root, patterns) {
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		repo.Packages = append(repo.Packages, workspacePackage{Name: workspaceName(dir), Dir: dir})
+	}
+	if len(repo.Packages) == 0 {
+		return nil
+	}
+	sort.Slice(repo.Packages, func(i, j int) bool {
+		return repo.Packages[i].Dir < repo.Packages[j].Dir
+	})
+	return repo
+}
+
+// expandWorkspaceGlobs returns the package folders matching workspace
+// globs such as "packages/*" or "apps/**", minus those excluded with "!".
+// Only folders with a package.json or an Nx project.json are packages.
+func expandWorkspaceGlobs(root string, patterns []string) []string {
+	excluded := make(map[string]bool)
+	var dirs []string
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.Trim(strings.TrimPrefix(pattern, "!"), "/")
+		pattern = strings.TrimPrefix(pattern, "./")
+		if pattern == "" {
+			continue
+		}
+		for _, dir := range matchWorkspaceGlob(root, strings.Split(pattern, "/")) {
+			if negate {
+				excluded[dir] = true
+			} else if dir != root && isWorkspacePackage(dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	var kept []string
+	for _, dir := range dirs {
+		if !excluded[dir] {
+			kept = append(kept, dir)
+		}
+	}
+	return kept
+}
+
+// matchWorkspaceGlob returns the folders under dir matching the remaining
+// glob segments; "**" matches any number of folders
+func matchWorkspaceGlob(dir string, segments []string) []string {
+	if len(segments) == 0 {
+		return []string{dir}
+	}
+	segment := segments[0]
+	if segment == "**" {
+		matches := matchWorkspaceGlob(dir, segments[1:])
+		for _, child := range subdirectories(dir) {
+			matches = append(matches, matchWorkspaceGlob(child, segments)...)
+		}
+		return matches
+	}
+	if !strings.ContainsAny(segment, "*?[") {
+		child := filepath.Join(dir, segment)
+		if info, err := os.Stat(child); err != nil || !info.IsDir() {
+			return nil
+		}
+		return matchWorkspaceGlob(child, segments[1:])
+	}
+	var matches []string
+	for _, child := range subdirectories(dir) {
+		if ok, _ := filepath.Match(segment, filepath.Base(child)); ok {
+			matches = append(matches, matchWorkspaceGlob(child, segments[1:])...)
+		}
+	}
+	return matches
+}
+
+// subdirectories lists the folders of dir a workspace glob can match,
+// leaving out node_modules and hidden folders
+func subdirectories(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() && name != "node_modules" && !strings.HasPrefix(name, ".") {
+			dirs = append(dirs, filepath.Join(dir, name))
+		}
+	}
+	return dirs
+}
+
+func isWorkspacePackage(dir string) bool {
+	for _, name := range []string{"package.json", "project.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceName returns the name a workspace package is known by: its
+// package.json or project.json name, else its folder name
+func workspaceName(dir string) string {
+	if manifest, ok := readPackageManifest(dir); ok && manifest.Name != "" {
+		return manifest.Name
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "project.json")); err == nil {
+		var project struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(data, &project) == nil && project.Name != "" {
+			return project.Name
+		}
+	}
+	return filepath.Base(dir)
+}
+
+// packageFor returns the workspace package containing path, if any
+func (repo *monorepo) packageFor(path string) (workspacePackage, bool) {
+	var best workspacePackage
+	found := false
+	for _, pkg := range repo.Packages {
+		if isWithin(path, pkg.Dir) && (!found || len(pkg.Dir) > len(best.Dir)) {
+			best, found = pkg, true
+		}
+	}
+	return best, found
+}
+
+// packageDir returns the folder of the named workspace package, relative
+// to the monorepo root
+func (repo *monorepo) packageDir(name string) (string, bool) {
+	for _, pkg := range repo.Packages {
+		if pkg.Name == name {
+			if rel, err := filepath.Rel(repo.Root, pkg.Dir); err == nil {
+				return filepath.ToSlash(rel), true
+			}
+		}
+	}
+	return "", false
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// monorepoCache remembers detectWorkspaces results, since printResults
+// asks about the same folders for every finding
+var monorepoCache sync.Map // root -> *monorepo
+
+func cachedMonorepo(root string) *monorepo {
+	if repo, ok := monorepoCache.Load(root); ok {
+		return repo.(*monorepo)
+	}
+	repo := detectWorkspaces(root)
+	monorepoCache.Store(root, repo)
+	return repo
+}
+
+// enclosingMonorepo returns the monorepo dir belongs to: one rooted at
+// dir, or one further up that lists dir inside a workspace package
+func enclosingMonorepo(dir string) *monorepo {
+	for current := dir; ; current = filepath.Dir(current) {
+		if repo := cachedMonorepo(current); repo != nil {
+			if current == dir {
+				return repo
+			}
+			if _, ok := repo.packageFor(dir); ok {
+				return repo
+			}
+		}
+		if isRoot(current) || current == "." || filepath.Dir(current) == current {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// makeTree creates files under a fresh directory, with "{}" as the content
+// of any file given without one
+func makeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if data == "" {
+			data = "{}"
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestExpandWorkspaceGlobs(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"packages/web/package.json":                "",
+		"packages/api/package.json":                "",
+		"packages/legacy/package.json":             "",
+		"packages/docs/README.md":                  "",
+		"packages/web/node_modules/x/package.json": "",
+		"packages/.cache/package.json":             "",
+		"apps/site/package.json":                   "",
+		"apps/tools/cli/package.json":              "",
+		"apps/tools/cli/fixtures/app/package.json": "",
+		"libs/ui/project.json":                     "",
+		"services/node_modules/svc/package.json":   "",
+	})
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		// Folders without a manifest, hidden folders and node_modules are
+		// never packages
+		{[]string{"packages/*"}, []string{"packages/api", "packages/legacy", "packages/web"}},
+		{[]string{"./packages/*/", "!packages/legacy"}, []string{"packages/api", "packages/web"}},
+		// ** matches any depth, including none
+		{[]string{"apps/**"}, []string{"apps/site", "apps/tools/cli", "apps/tools/cli/fixtures/app"}},
+		{[]string{"apps/**", "!apps/**/fixtures/**"}, []string{"apps/site", "apps/tools/cli"}},
+		{[]string{"packages/[aw]*"}, []string{"packages/api", "packages/web"}},
+		// Literal paths, Nx project.json, and folders that don't exist
+		{[]string{"libs/ui", "missing/*", "services/*"}, []string{"libs/ui"}},
+		{[]string{"", "!", "."}, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, dir := range expandWorkspaceGlobs(root, tt.patterns) {
+			rel, _ := filepath.Rel(root, dir)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandWorkspaceGlobs(%q) = %q, want %q", tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestDetectWorkspaces(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string // "<sources>: <name> (<dir>), ..."
+	}{
+		{"npm", map[string]string{
+			"package.json":              `{"workspaces": ["packages/*"]}`,
+			"packages/web/package.json": `{"name": "@acme/web"}`,
+			"packages/api/package.json": `{}`,
+		}, "package.json: api (packages/api), @acme/web (packages/web)"},
+		{"yarn packages object", map[string]string{
+			"package.json":           `{"workspaces": {"packages": ["apps/*"], "nohoist": ["**/x"]}}`,
+			"apps/site/package.json": `{"name": "site"}`,
+		}, "package.json: site (apps/site)"},
+		{"pnpm", map[string]string{
+			"pnpm-workspace.yaml":       "packages:\n  - 'packages/*'\n  - '!packages/old'\n",
+			"packages/web/package.json": `{"name": "web"}`,
+			"packages/old/package.json": `{"name": "old"}`,
+		}, "pnpm-workspace.yaml: web (packages/web)"},
+		// Lerna defaults to packages/*, and Turborepo is noted alongside
+		{"lerna and turbo", map[string]string{
+			"package.json":              `{"workspaces": ["tools/*"]}`,
+			"lerna.json":                `{"version": "independent"}`,
+			"turbo.json":                `{}`,
+			"packages/web/package.json": `{"name": "web"}`,
+			"tools/lint/package.json":   `{"name": "lint"}`,
+		}, "package.json, lerna.json, turbo.json: web (packages/web), lint (tools/lint)"},
+		// Nx projects by project.json in the default layout or workspace.json
+		{"nx", map[string]string{
+			"nx.json":                  `{}`,
+			"workspace.json":           `{"projects": {"admin": "tools/admin", "ui": {"root": "shared/ui"}}}`,
+			"apps/site/project.json":   `{"name": "site"}`,
+			"tools/admin/project.json": `{}`,
+			"shared/ui/package.json":   `{"name": "@acme/ui"}`,
+		}, "nx.json: site (apps/site), @acme/ui (shared/ui), admin (tools/admin)"},
+		{"turbo alone", map[string]string{
+			"turbo.json":                `{}`,
+			"packages/web/package.json": `{}`,
+		}, ""},
+		{"no packages", map[string]string{
+			"package.json": `{"workspaces": ["packages/*"]}`,
+		}, ""},
+	}
+	for _, tt := range tests {
+		root := makeTree(t, tt.files)
+		repo := detectWorkspaces(root)
+		got := ""
+		if repo != nil {
+			var packages []string
+			for _, pkg := range repo.Packages {
+				dir, _ := repo.packageDir(pkg.Name)
+				packages = append(packages, pkg.Name+" ("+dir+")")
+			}
+			got = strings.Join(repo.Sources, ", ") + ": " + strings.Join(packages, ", ")
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEnclosingMonorepo(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"package.json":                 `{"workspaces": ["packages/*"]}`,
+		"packages/web/package.json":    `{"name": "web"}`,
+		"packages/web/src/index.js":    "",
+		"packages/webapp/package.json": `{"name": "webapp"}`,
+		"scripts/package.json":         `{"name": "scripts"}`,
+	})
+	web := filepath.Join(root, "packages", "web")
+	tests := []struct {
+		dir     string
+		repo    bool
+		pkgName string
+	}{
+		{root, true, ""},
+		{web, true, "web"},
+		{filepath.Join(web, "src"), true, "web"},
+		// A sibling sharing a prefix is its own package, and a folder that
+		// isn't a workspace package is not part of the monorepo
+		{filepath.Join(root, "packages", "webapp"), true, "webapp"},
+		{filepath.Join(root, "scripts"), false, ""},
+	}
+	for _, tt := range tests {
+		repo := enclosingMonorepo(tt.dir)
+		if (repo != nil) != tt.repo || repo != nil && repo.Root != root {
+			t.Errorf("enclosingMonorepo(%s) = %+v, want a repo: %v", tt.dir, repo, tt.repo)
+			continue
+		}
+		if repo == nil {
+			continue
+		}
+		pkg, ok := repo.packageFor(tt.dir)
+		if ok != (tt.pkgName != "") || pkg.Name != tt.pkgName {
+			t.Errorf("packageFor(%s) = %q, %v, want %q", tt.dir, pkg.Name, ok, tt.pkgName)
+		}
+	}
+}
+
+func TestLockfileDependents(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+	)
+	root := makeTree(t, map[string]string{
+		"package.json":              `{"name": "monorepo", "workspaces": ["packages/*"]}`,
+		"packages/web/package.json": `{"name": "@acme/web", "dependencies": {"chalk": "^5.0.0", "debug": "^4.0.0"}}`,
+		"packages/api/package.json": `{"name": "@acme/api", "dependencies": {"debug": "^4.0.0"}}`,
+		"yarn.lock": `# yarn lockfile v1
+
+
+chalk@^5.0.0:
+  version "5.6.1"
+
+debug@^4.0.0:
+  version "4.4.2"
+`,
+	})
+	path := filepath.Join(root, "yarn.lock")
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got := make(map[string][]string)
+	scanYarnLock(file, path, func(f Finding) { got[f.Package] = f.Dependents }, false)
+
+	// Yarn classic workspace manifests are roots of the lockfile graph
+	want := map[string][]string{
+		"chalk": {"@acme/web"},
+		"debug": {"@acme/api", "@acme/web"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependents = %v, want %v", got, want)
+	}
+}
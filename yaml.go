@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlNode is a node of the YAML subset lockfiles are written in: block
+// mappings and sequences, flow collections and plain or quoted scalars.
+// Anchors, tags and multi-line plain scalars are not supported.
+type yamlNode struct {
+	Scalar  string
+	Keys    []string // mapping keys in document order
+	Mapping map[string]*yamlNode
+	Items   []*yamlNode
+}
+
+// get returns the value of a mapping key, or nil
+func (n *yamlNode) get(key string) *yamlNode {
+	if n == nil || n.Mapping == nil {
+		return nil
+	}
+	return n.Mapping[key]
+}
+
+// scalar returns the scalar value of a mapping key, or ""
+func (n *yamlNode) scalar(key string) string {
+	if value := n.get(key); value != nil {
+		return value.Scalar
+	}
+	return ""
+}
+
+// items returns the items of a sequence under a mapping key, or nil
+func (n *yamlNode) items(key string) []*yamlNode {
+	if value := n.get(key); value != nil {
+		return value.Items
+	}
+	return nil
+}
+
+func (n *yamlNode) set(key string, value *yamlNode) {
+	if n.Mapping == nil {
+		n.Mapping = make(map[string]*yamlNode)
+	}
+	if _, exists := n.Mapping[key]; !exists {
+		n.Keys = append(n.Keys, key)
+	}
+	n.Mapping[key] = value
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+	num    int
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses the last document of a YAML stream
+func parseYAML(data []byte) (*yamlNode, error) {
+	var lines []yamlLine
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for num := 1; scanner.Scan(); num++ {
+		raw := strings.TrimRight(scanner.Text(), " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if raw == "---" || raw == "..." {
+			// pnpm writes an environment document before the lockfile
+			lines = nil
+			continue
+		}
+		lines = append(lines, yamlLine{indent: len(raw) - len(text), text: text, num: num})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	p := &yamlParser{lines: lines}
+	if len(lines) == 0 {
+		return &yamlNode{}, nil
+	}
+	node, err := p.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return node, nil
+}
+
+// parseBlock parses the block mapping or sequence starting at the current
+// line, whose indentation must be at least minIndent
+func (p *yamlParser) parseBlock(minIndent int) (*yamlNode, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent < minIndent {
+		return &yamlNode{}, nil
+	}
+	indent := p.lines[p.pos].indent
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseMapping(indent int) (*yamlNode, error) {
+	node := &yamlNode{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || (line.indent == indent && isYAMLSequenceItem(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		key, value, err := splitYAMLKeyValue(line.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line.num, err)
+		}
+		p.pos++
+
+		child, err := p.parseValue(indent, value)
+		if err != nil {
+			return nil, err
+		}
+		node.set(key, child)
+	}
+	return node, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (*yamlNode, error) {
+	node := &yamlNode{Items: []*yamlNode{}}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isYAMLSequenceItem(line.text) {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest != "" && !strings.HasPrefix(rest, "{") && !strings.HasPrefix(rest, "[") {
+			if _, _, err := splitYAMLKeyValue(rest); err == nil {
+				// "- key: value" starts a mapping indented past the dash
+				p.lines[p.pos] = yamlLine{indent: line.indent + len(line.text) - len(rest), text: rest, num: line.num}
+				item, err := p.parseMapping(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				node.Items = append(node.Items, item)
+				continue
+			}
+		}
+		p.pos++
+
+		item, err := p.parseValue(indent, rest)
+		if err != nil {
+			return nil, err
+		}
+		node.Items = append(node.Items, item)
+	}
+	return node, nil
+}
+
+// parseValue parses what follows "key:" or "-" on a line at indent: an
+// inline value, a block scalar or a nested block on the following lines
+func (p *yamlParser) parseValue(indent int, value string) (*yamlNode, error) {
+	switch {
+	case value == "":
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isYAMLSequenceItem(next.text)) {
+				return p.parseBlock(next.indent)
+			}
+		}
+		return &yamlNode{}, nil
+	case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+		var text []string
+		for p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+			text = append(text, p.lines[p.pos].text)
+			p.pos++
+		}
+		return &yamlNode{Scalar: strings.Join(text, "\n")}, nil
+	}
+	return parseYAMLInline(value)
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKeyValue splits "key: value" or "key:" into its parts
+func splitYAMLKeyValue(text string) (string, string, error) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := yamlQuoteEnd(text)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		key, err := unquoteYAML(text[:end+1])
+		if err != nil {
+			return "", "", err
+		}
+		rest := text[end+1:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("expected a colon after key %s", text[:end+1])
+		}
+		return key, strings.TrimSpace(rest[1:]), nil
+	}
+
+	if idx := strings.Index(text, ": "); idx >= 0 {
+		return text[:idx], strings.TrimSpace(text[idx+2:]), nil
+	}
+	if strings.HasSuffix(text, ":") {
+		return text[:len(text)-1], "", nil
+	}
+	return "", "", fmt.Errorf("expected a mapping key")
+}
+
+// yamlQuoteEnd returns the index of the quote closing the string that
+// starts text, or -1
+func yamlQuoteEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func unquoteYAML(quoted string) (string, error) {
+	if quoted[0] == '\'' {
+		return strings.ReplaceAll(quoted[1:len(quoted)-1], "''", "'"), nil
+	}
+	value, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted string %s", quoted)
+	}
+	return value, nil
+}
+
+// parseYAMLInline parses a value written on the same line as its key
+func parseYAMLInline(value string) (*yamlNode, error) {
+	switch value[0] {
+	case '{', '[':
+		flow := &yamlFlowParser{s: value}
+		node, err := flow.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	case '"', '\'':
+		end := yamlQuoteEnd(value)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated quoted string %s", value)
+		}
+		unquoted, err := unquoteYAML(value[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		return &yamlNode{Scalar: unquoted}, nil
+	}
+	if idx := strings.Index(value, " #"); idx >= 0 {
+		value = strings.TrimSpace(value[:idx])
+	}
+	return &yamlNode{Scalar: value}, nil
+}
+
+// yamlFlowParser parses flow collections such as
+// "{integrity: sha512-..., tarball: https://...}" and "[darwin, linux]"
+type yamlFlowParser struct {
+	s   string
+	pos int
+}
+
+func (f *yamlFlowParser) skipSpaces() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlowParser) parseValue() (*yamlNode, error) {
+	f.skipSpaces()
+	if f.pos >= len(f.s) {
+		return &yamlNode{}, nil
+	}
+	switch f.s[f.pos] {
+	case '{':
+		f.pos++
+		node := &yamlNode{Mapping: map[string]*yamlNode{}}
+		for {
+			f.skipSpaces()
+			if f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return node, nil
+			}
+			key, err := f.parseScalar(":,}")
+			if err != nil {
+				return nil, err
+			}
+			f.skipSpaces()
+			value := &yamlNode{}
+			if f.pos < len(f.s) && f.s[f.pos] == ':' {
+				f.pos++
+				if value, err = f.parseValue(); err != nil {
+					return nil, err
+				}
+			}
+			node.set(key, value)
+			if err := f.expectSeparator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '[':
+		f.pos++
+		node := &yamlNode{Items: []*yamlNode{}}
+		for {
+			f.skipSpaces()
+			if f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return node, nil
+			}
+			item, err := f.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			node.Items = append(node.Items, item)
+			if err := f.expectSeparator(']'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	scalar, err := f.parseScalar(",}]")
+	if err != nil {
+		return nil, err
+	}
+	return &yamlNode{Scalar: scalar}, nil
+}
+
+// expectSeparator consumes the comma between flow entries; the closing
+// bracket is left for the caller
+func (f *yamlFlowParser) expectSeparator(closing byte) error {
+	f.skipSpaces()
+	if f.pos >= len(f.s) {
+		return fmt.Errorf("unterminated flow collection %s", f.s)
+	}
+	switch f.s[f.pos] {
+	case ',':
+		f.pos++
+		return nil
+	case closing:
+		return nil
+	}
+	return fmt.Errorf("unexpected %q in flow collection %s", f.s[f.pos], f.s)
+}
+
+// parseScalar reads a quoted scalar or a plain one ending before any of
+// the stop characters
+func (f *yamlFlowParser) parseScalar(stops string) (string, error) {
+	f.skipSpaces()
+	if f.pos < len(f.s) && (f.s[f.pos] == '"' || f.s[f.pos] == '\'') {
+		end := yamlQuoteEnd(f.s[f.pos:])
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted string in %s", f.s)
+		}
+		value, err := unquoteYAML(f.s[f.pos : f.pos+end+1])
+		f.pos += end + 1
+		return value, err
+	}
+	start := f.pos
+	for f.pos < len(f.s) && !strings.ContainsRune(stops, rune(f.s[f.pos])) {
+		f.pos++
+	}
+	return strings.TrimSpace(f.s[start:f.pos]), nil
+}
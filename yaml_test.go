@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// yamlString renders a node compactly so parse results can be compared:
+// scalars as-is, mappings as {k: v, ...} in document order, sequences as
+// [a, b]
+func yamlString(n *yamlNode) string {
+	switch {
+	case n == nil:
+		return "<nil>"
+	case n.Mapping != nil:
+		parts := make([]string, 0, len(n.Keys))
+		for _, key := range n.Keys {
+			parts = append(parts, key+": "+yamlString(n.Mapping[key]))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case n.Items != nil:
+		parts := make([]string, 0, len(n.Items))
+		for _, item := range n.Items {
+			parts = append(parts, yamlString(item))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return n.Scalar
+}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", "", ""},
+		{"comments only", "# nothing\n\n", ""},
+		{"scalars", "a: 1\nb: two words\nc:\n", "{a: 1, b: two words, c: }"},
+		{"nested", "a:\n  b:\n    c: d\n  e: f\ng: h\n", "{a: {b: {c: d}, e: f}, g: h}"},
+		{"quoted keys and values",
+			"'/@ctrl/tinycolor@4.1.1':\n  \"version\": '4.1.1'\n  note: \"a \\\"b\\\"\"\n  it: 'it''s'\n",
+			`{/@ctrl/tinycolor@4.1.1: {version: 4.1.1, note: a "b", it: it's}}`},
+		{"colon in key", "/chalk@5.6.1:\n  resolution: x\n", "{/chalk@5.6.1: {resolution: x}}"},
+		{"trailing comment", "a: b # c\nd: 'e # f'\n", "{a: b, d: e # f}"},
+		{"sequence", "os:\n  - darwin\n  - linux\n", "{os: [darwin, linux]}"},
+		{"sequence at key indent", "os:\n- darwin\n- linux\nnext: 1\n", "{os: [darwin, linux], next: 1}"},
+		{"sequence of mappings", "items:\n  - name: a\n    version: 1\n  - name: b\n", "{items: [{name: a, version: 1}, {name: b}]}"},
+		{"flow mapping",
+			"resolution: {integrity: sha512-abc==, tarball: 'https://x/y.tgz'}\n",
+			"{resolution: {integrity: sha512-abc==, tarball: https://x/y.tgz}}"},
+		{"flow sequence", "cpu: [x64, 'arm64']\nempty: []\n", "{cpu: [x64, arm64], empty: []}"},
+		{"nested flow", "a: {b: [1, {c: d}], e: {}}\n", "{a: {b: [1, {c: d}], e: {}}}"},
+		{"block scalar", "script: |\n  echo a\n  echo b\nnext: 1\n", "{script: echo a\necho b, next: 1}"},
+		{"last document", "---\nstore: x\n---\nlockfileVersion: '9.0'\n", "{lockfileVersion: 9.0}"},
+		{"windows line endings", "a: 1\r\nb: 2\r\n", "{a: 1, b: 2}"},
+	}
+	for _, tt := range tests {
+		node, err := parseYAML([]byte(tt.in))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := yamlString(node); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"not a mapping", "a: 1\njust text\n"},
+		{"over-indented", "a: 1\n    b: 2\n"},
+		{"dedent below root", "  a: 1\nb: 2\n"},
+		{"unterminated quoted key", "'a: 1\n"},
+		{"unterminated quoted value", "a: 'b\n"},
+		{"unterminated flow", "a: {b: c\n"},
+		{"bad flow separator", "a: {b: 'c' d}\n"},
+	}
+	for _, tt := range tests {
+		if node, err := parseYAML([]byte(tt.in)); err == nil {
+			t.Errorf("%s: got %s, want an error", tt.name, yamlString(node))
+		}
+	}
+}
+
+func TestYAMLNodeAccessors(t *testing.T) {
+	node, err := parseYAML([]byte("a:\n  b: c\n  list:\n    - x\n    - y\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := node.get("a").scalar("b"); got != "c" {
+		t.Errorf("scalar = %q", got)
+	}
+	if got := yamlString(node.get("a").get("list")); got != "[x, y]" {
+		t.Errorf("list = %s", got)
+	}
+	if !reflect.DeepEqual(node.get("a").Keys, []string{"b", "list"}) {
+		t.Errorf("Keys = %v", node.get("a").Keys)
+	}
+	// Missing keys and nil nodes are empty rather than panicking
+	var missing *yamlNode
+	if missing.get("x") != nil || node.get("nope").scalar("x") != "" {
+		t.Error("missing keys are not empty")
+	}
+}
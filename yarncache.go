@@ -0,0 +1,281 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Yarn names cached packages after a "slug" of the package name, with the
+// "/" of scoped names written as "-": "@ctrl/tinycolor" is "@ctrl-tinycolor".
+//
+//   - Yarn classic caches unpacked folders, "npm-chalk-5.6.1-<sha1>-integrity"
+//     (older caches drop "-integrity"), holding node_modules/chalk
+//   - Yarn Berry caches zip archives, "chalk-npm-5.6.1-<hash>-<checksum>.zip"
+//     ("-<cache key>.zip" since Yarn 4), in a global cache and in each
+//     repository's .yarn/cache
+//   - Offline mirrors keep the registry tarballs as "chalk-5.6.1.tgz"
+
+// parseYarnClassicCacheName decodes a Yarn classic cache folder name into
+// a package slug and version
+func parseYarnClassicCacheName(name string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(name, "npm-")
+	if !ok {
+		return "", "", false
+	}
+	rest = strings.TrimSuffix(rest, "-integrity")
+	idx := strings.LastIndex(rest, "-")
+	if idx < 0 || !isHexString(rest[idx+1:]) {
+		return "", "", false
+	}
+	return splitYarnSlugVersion(rest[:idx])
+}
+
+// parseYarnBerryCacheName decodes a Yarn Berry cache archive name into a
+// package slug and version. Only npm packages are decoded; patches, git and
+// file dependencies have other protocols.
+func parseYarnBerryCacheName(name string) (string, string, bool) {
+	rest, ok := strings.CutSuffix(name, ".zip")
+	if !ok {
+		return "", "", false
+	}
+	// The slug itself may contain "-npm-", as in "is-npm-npm-1.0.0-..."
+	for offset := 0; ; {
+		idx := strings.Index(rest[offset:], "-npm-")
+		if idx < 0 {
+			return "", "", false
+		}
+		slug := rest[:offset+idx]
+		parts := strings.Split(rest[offset+idx+len("-npm-"):], "-")
+		// The version is followed by the 10-character locator hash and the
+		// checksum, or the cache key since Yarn 4. Prerelease tags may
+		// contain "-" and hex too, so those segments are cut off first.
+		for _, trailing := range []int{2, 1} {
+			if len(parts) <= trailing || !allHexStrings(parts[len(parts)-trailing:]) {
+				continue
+			}
+			if trailing == 2 && len(parts[len(parts)-2]) != 10 {
+				continue
+			}
+			version := strings.Join(parts[:len(parts)-trailing], "-")
+			if _, err := parseSemVer(version); err == nil && slug != "" {
+				return slug, version, true
+			}
+		}
+		offset += idx + 1
+	}
+}
+
+// parseYarnMirrorName decodes an offline mirror tarball name into a package
+// slug and version
+func parseYarnMirrorName(name string) (string, string, bool) {
+	rest, ok := strings.CutSuffix(name, ".tgz")
+	if !ok {
+		return "", "", false
+	}
+	return splitYarnSlugVersion(rest)
+}
+
+// splitYarnSlugVersion splits "left-pad-1.3.0" at the first "-" that
+// starts a valid version
+func splitYarnSlugVersion(s string) (string, string, bool) {
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] != '-' || !isDigit(s[i+1]) {
+			continue
+		}
+		if _, err := parseSemVer(s[i+1:]); err == nil {
+			return s[:i], s[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// yarnSlugPackage returns the package name a slug stands for. Scopes may
+// contain "-" too, so a scoped slug is matched against the IOC list before
+// falling back to its first "-".
+func yarnSlugPackage(slug string) string {
+	if !strings.HasPrefix(slug, "@") {
+		return slug
+	}
+	first := ""
+	for i := 2; i < len(slug)-1; i++ {
+		if slug[i] != '-' {
+			continue
+		}
+		name := slug[:i] + "/" + slug[i+1:]
+		if activeMatcher.lookup(name) != nil {
+			return name
+		}
+		if first == "" {
+			first = name
+		}
+	}
+	if first == "" {
+		return slug
+	}
+	return first
+}
+
+func isHexString(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !isDigit(c) && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// allHexStrings reports whether every segment is a hash; Yarn 4 also
+// appends the cache version, such as "10c0"
+func allHexStrings(segments []string) bool {
+	for _, segment := range segments {
+		if !isHexString(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+// scanYarnClassicCacheEntry reports a compromised package in a Yarn classic
+// cache folder, confirmed by the package.json unpacked inside it. It
+// reports whether the folder was a cache entry.
+func scanYarnClassicCacheEntry(dir string, addFinding func(Finding), verbose bool) bool {
+	slug, version, ok := parseYarnClassicCacheName(filepath.Base(dir))
+	if !ok {
+		return false
+	}
+	name := yarnSlugPackage(slug)
+	if installed, ok := readInstalledManifest(filepath.Join(dir, "node_modules", filepath.FromSlash(name))); ok {
+		name, version = installed.Name, installed.Version
+	}
+	reportYarnCacheEntry(name, version, dir, "", addFinding, verbose)
+	return true
+}
+
+// scanYarnBerryCacheArchive reports a compromised package in a Yarn Berry
+// cache archive, confirmed by the package.json inside the zip. It reports
+// whether the file was a cache archive.
+func scanYarnBerryCacheArchive(filePath string, addFinding func(Finding), verbose bool) bool {
+	slug, version, ok := parseYarnBerryCacheName(filepath.Base(filePath))
+	if !ok {
+		return false
+	}
+	name := yarnSlugPackage(slug)
+	location := ""
+	if manifest, inner := readZipManifest(filePath); manifest != nil {
+		name, version, location = manifest.Name, manifest.Version, inner
+	} else if verbose {
+		fmt.Printf("  ⚠️  Could not read package.json in %s, using its file name\n", filePath)
+	}
+	reportYarnCacheEntry(name, version, filePath, location, addFinding, verbose)
+	return true
+}
+
+// scanYarnMirrorName reports an offline mirror tarball by its file name,
+// for tarballs that could not be read as archives
+func scanYarnMirrorName(filePath string, addFinding func(Finding), verbose bool) {
+	if slug, version, ok := parseYarnMirrorName(filepath.Base(filePath)); ok {
+		reportYarnCacheEntry(yarnSlugPackage(slug), version, filePath, "", addFinding, verbose)
+	}
+}
+
+func reportYarnCacheEntry(name, version, filePath, location string, addFinding func(Finding), verbose bool) {
+	pkg := activeMatcher.lookup(name)
+	if pkg == nil || !pkg.matchesVersion(version) {
+		return
+	}
+	addFinding(Finding{
+		Package:  pkg.Name,
+		Version:  version,
+		File:     filePath,
+		Location: location,
+		Type:     "cache",
+		IOC:      pkg,
+	})
+	if verbose {
+		fmt.Printf("  Found %s@%s in yarn cache: %s\n", pkg.Name, version, filePath)
+	}
+}
+
+// readZipManifest reads the package.json of a Yarn Berry archive, stored
+// as node_modules/<name>/package.json, and returns its path in the zip
+func readZipManifest(filePath string) (*installedPackage, string) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, ""
+	}
+	defer archive.Close()
+	for _, file := range archive.File {
+		inner, ok := strings.CutPrefix(file.Name, "node_modules/")
+		if !ok {
+			continue
+		}
+		name, ok := strings.CutSuffix(inner, "/package.json")
+		if !ok || !isValidPackageName(name) {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			return nil, ""
+		}
+		manifest := readTarballManifest(io.LimitReader(r, maxTarballManifestSize))
+		r.Close()
+		return manifest, file.Name
+	}
+	return nil, ""
+}
+
+// getYarnBerryCacheDir returns Yarn Berry's global cache, shared by every
+// project since Yarn 4 unless enableGlobalCache is turned off
+func getYarnBerryCacheDir() string {
+	if folder := os.Getenv("YARN_GLOBAL_FOLDER"); folder != "" {
+		return filepath.Join(folder, "cache")
+	}
+	if runtime.GOOS == "windows" {
+		if local := os.Getenv("LOCALAPPDATA"); local != "" {
+			return filepath.Join(local, "Yarn", "Berry", "cache")
+		}
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".yarn", "berry", "cache")
+}
+
+// scanRepoYarnCaches scans each repository's own Yarn Berry cache,
+// .yarn/cache, where zero-install projects commit their archives
+func scanRepoYarnCaches(baseDir string, jobs chan<- func(), wg *sync.WaitGroup, addFinding func(Finding), verbose bool) {
+	filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		switch info.Name() {
+		case "node_modules", ".git":
+			return filepath.SkipDir
+		case "cache":
+			if filepath.Base(filepath.Dir(path)) != ".yarn" {
+				return nil
+			}
+			if verbose {
+				fmt.Printf("  📦 Found Yarn cache: %s\n", path)
+			}
+			wg.Add(1)
+			jobs <- func() {
+				defer wg.Done()
+				scanCacheDir(path, addFinding, verbose)
+			}
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
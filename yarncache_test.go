@@ -0,0 +1,175 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseYarnBerryCacheName(t *testing.T) {
+	tests := []struct {
+		name          string
+		slug, version string
+		ok            bool
+	}{
+		// Yarn 2 and 3: locator hash, then checksum
+		{"chalk-npm-4.1.2-ba8c8f6ec2-db8a2f3e2a.zip", "chalk", "4.1.2", true},
+		{"@ctrl-tinycolor-npm-4.1.1-6c2e5e7f3d-e8f3a10b2c.zip", "@ctrl-tinycolor", "4.1.1", true},
+		{"typescript-npm-5.0.0-beta-1f4c7f8a52-43f6c2a5d9.zip", "typescript", "5.0.0-beta", true},
+		// Yarn 4: locator hash, then cache key
+		{"chalk-npm-5.6.1-79b1b3c1bf-10c0.zip", "chalk", "5.6.1", true},
+		{"debug-npm-4.4.2-4bd3b1f2a4-10c0.zip", "debug", "4.4.2", true},
+		{"@babel-core-npm-7.24.0-8c3a1a0b4e-10c0.zip", "@babel-core", "7.24.0", true},
+		// Prerelease tags with "-" and hex-looking identifiers
+		{"react-npm-19.0.0-rc-6230622a1a-20240610-4b0d3e9c1f-10c0.zip", "react", "19.0.0-rc-6230622a1a-20240610", true},
+		{"next-npm-15.0.0-canary.1-a1b2c3d4e5-10c0.zip", "next", "15.0.0-canary.1", true},
+		{"pkg-npm-1.0.0-0-a1b2c3d4e5-10c0.zip", "pkg", "1.0.0-0", true},
+		{"pkg-npm-1.0.0-beta.2-a1b2c3d4e5-2a5f1c9b8e.zip", "pkg", "1.0.0-beta.2", true},
+		// Slugs containing "-npm-"
+		{"is-npm-npm-1.0.0-cf7c2ae1d6-10c0.zip", "is-npm", "1.0.0", true},
+		{"is-npm-npm-2.0.0-b1d0e2f3a4-c4d25c8f4b.zip", "is-npm", "2.0.0", true},
+		{"@npm-cli-arborist-npm-7.0.0-0a1b2c3d4e-10c0.zip", "@npm-cli-arborist", "7.0.0", true},
+		// A single hash segment
+		{"chalk-npm-5.6.1-79b1b3c1bf.zip", "chalk", "5.6.1", true},
+		// Not npm packages, or not cache archives
+		{"typescript-patch-5a1b2c3d4e-10c0.zip", "", "", false},
+		{"my-lib-file-1a2b3c4d5e-10c0.zip", "", "", false},
+		{"chalk-npm-5.6.1-79b1b3c1bf-10c0.tgz", "", "", false},
+		{"chalk-npm-latest-79b1b3c1bf-10c0.zip", "", "", false},
+		{"-npm-1.0.0-79b1b3c1bf-10c0.zip", "", "", false},
+	}
+	for _, tt := range tests {
+		slug, version, ok := parseYarnBerryCacheName(tt.name)
+		if slug != tt.slug || version != tt.version || ok != tt.ok {
+			t.Errorf("parseYarnBerryCacheName(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.name, slug, version, ok, tt.slug, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestParseYarnClassicCacheName(t *testing.T) {
+	tests := []struct {
+		name          string
+		slug, version string
+		ok            bool
+	}{
+		{"npm-chalk-5.6.1-5b1c6b0a6e0d2c1a7b2d3c4e5f6a7b8c9d0e1f2a-integrity", "chalk", "5.6.1", true},
+		{"npm-chalk-5.6.1-5b1c6b0a6e0d2c1a7b2d3c4e5f6a7b8c9d0e1f2a", "chalk", "5.6.1", true},
+		{"npm-@ctrl-tinycolor-4.1.1-0a1b2c3d4e5f60718293a4b5c6d7e8f901234567-integrity", "@ctrl-tinycolor", "4.1.1", true},
+		{"npm-is-npm-1.0.0-0a1b2c3d4e5f60718293a4b5c6d7e8f901234567", "is-npm", "1.0.0", true},
+		{"npm-pkg-2.0.0-rc.1-0a1b2c3d4e5f60718293a4b5c6d7e8f901234567", "pkg", "2.0.0-rc.1", true},
+		{"chalk-5.6.1-0a1b2c3d4e5f60718293a4b5c6d7e8f901234567", "", "", false},
+		{"npm-chalk-5.6.1-integrity", "", "", false},
+		{".tmp", "", "", false},
+	}
+	for _, tt := range tests {
+		slug, version, ok := parseYarnClassicCacheName(tt.name)
+		if slug != tt.slug || version != tt.version || ok != tt.ok {
+			t.Errorf("parseYarnClassicCacheName(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.name, slug, version, ok, tt.slug, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestParseYarnMirrorName(t *testing.T) {
+	tests := []struct {
+		name          string
+		slug, version string
+		ok            bool
+	}{
+		{"chalk-5.6.1.tgz", "chalk", "5.6.1", true},
+		{"left-pad-1.3.0.tgz", "left-pad", "1.3.0", true},
+		{"@ctrl-tinycolor-4.1.1.tgz", "@ctrl-tinycolor", "4.1.1", true},
+		{"es5-ext-0.10.64.tgz", "es5-ext", "0.10.64", true},
+		{"pkg-1.0.0-beta.1.tgz", "pkg", "1.0.0-beta.1", true},
+		{"chalk.tgz", "", "", false},
+		{"chalk-5.6.1.zip", "", "", false},
+	}
+	for _, tt := range tests {
+		slug, version, ok := parseYarnMirrorName(tt.name)
+		if slug != tt.slug || version != tt.version || ok != tt.ok {
+			t.Errorf("parseYarnMirrorName(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.name, slug, version, ok, tt.slug, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestYarnSlugPackage(t *testing.T) {
+	setTestIOCs(t, CompromisedPackage{Name: "@my-org/ui-kit", Versions: []string{"1.0.0"}})
+	tests := []struct {
+		slug, want string
+	}{
+		{"chalk", "chalk"},
+		{"left-pad", "left-pad"},
+		{"@ctrl-tinycolor", "@ctrl/tinycolor"},
+		{"@babel-plugin-syntax-jsx", "@babel/plugin-syntax-jsx"},
+		// A scope with "-" is only recognised through the IOC list
+		{"@my-org-ui-kit", "@my-org/ui-kit"},
+		{"@broken", "@broken"},
+	}
+	for _, tt := range tests {
+		if got := yarnSlugPackage(tt.slug); got != tt.want {
+			t.Errorf("yarnSlugPackage(%q) = %q, want %q", tt.slug, got, tt.want)
+		}
+	}
+}
+
+func TestScanCacheDirYarn(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "@ctrl/tinycolor", Versions: []string{"4.1.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+	)
+	cacheDir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(cacheDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Yarn classic: the unpacked package.json confirms the folder name, and
+	// the tarball kept beside it is not reported again
+	const classic = "v6/npm-@ctrl-tinycolor-4.1.1-0a1b2c3d4e5f60718293a4b5c6d7e8f901234567-integrity"
+	write(classic+"/node_modules/@ctrl/tinycolor/package.json", `{"name": "@ctrl/tinycolor", "version": "4.1.1"}`)
+	write(classic+"/node_modules/@ctrl/tinycolor/.yarn-tarball.tgz", "")
+	write("v6/npm-chalk-5.6.1-0a1b2c3d4e5f60718293a4b5c6d7e8f901234567-integrity/node_modules/chalk/package.json",
+		`{"name": "chalk", "version": "5.3.0"}`)
+
+	// Yarn Berry: the package.json inside the zip is the identity
+	var buf strings.Builder
+	archive := zip.NewWriter(&buf)
+	w, err := archive.Create("node_modules/debug/package.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(`{"name": "debug", "version": "4.4.2"}`))
+	archive.Close()
+	write("berry/debug-npm-4.4.2-4bd3b1f2a4-e8f3a10b2c.zip", buf.String())
+
+	// Offline mirror: a tarball that is not a readable archive is known by
+	// its name
+	write("mirror/chalk-5.6.1.tgz", "")
+
+	var got []string
+	scanCacheDir(cacheDir, func(f Finding) {
+		rel, _ := filepath.Rel(cacheDir, f.File)
+		got = append(got, f.Type+" "+f.Package+"@"+f.Version+" "+filepath.ToSlash(rel)+" "+f.Location)
+	}, false)
+	sort.Strings(got)
+	want := []string{
+		"cache @ctrl/tinycolor@4.1.1 " + classic + " ",
+		"cache chalk@5.6.1 mirror/chalk-5.6.1.tgz ",
+		"cache debug@4.4.2 berry/debug-npm-4.4.2-4bd3b1f2a4-e8f3a10b2c.zip node_modules/debug/package.json",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
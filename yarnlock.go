@@ -0,0 +1,613 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// yarnLockRecord is one block of a yarn.lock: every spec that resolved to
+// the same package and what it resolved to
+type yarnLockRecord struct {
+	Specs      []string
+	Version    string
+	Resolved   string // Yarn classic tarball URL
+	Integrity  string // Yarn classic SRI digest
+	Resolution string // Yarn Berry "resolution:", e.g. "chalk@npm:5.6.1"
+	// Dependencies maps each dependency and optional dependency to the range
+	// it is requested with, "debug": "^4.1.0"; Berry ranges carry their
+	// protocol, "npm:^4.1.0"
+	Dependencies map[string]string
+}
+
+// addDependency records a line of a dependencies or optionalDependencies map
+func (record *yarnLockRecord) addDependency(section, name, rng string) {
+	if section != "dependencies" && section != "optionalDependencies" {
+		return
+	}
+	if record.Dependencies == nil {
+		record.Dependencies = make(map[string]string)
+	}
+	record.Dependencies[name] = rng
+}
+
+func scanYarnLock(file *os.File, filePath string, addFinding func(Finding), verbose bool) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return
+	}
+	if isYarnBerryLock(data) {
+		scanYarnBerryLock(data, filePath, addFinding, verbose)
+		return
+	}
+
+	records, err := parseYarnClassicLock(string(data))
+	if err != nil {
+		// Hand-edited or conflicted lockfiles still get a best-effort scan
+		if verbose {
+			fmt.Printf("  ⚠️  Could not parse %s (%v), scanning it as text\n", filePath, err)
+		}
+		scanFile(filePath, addFinding, verbose)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			scanIntegrityLine(scanner.Text(), filePath, addFinding, verbose)
+		}
+		return
+	}
+
+	graph := yarnClassicGraph(records, filepath.Dir(filePath))
+	for _, record := range records {
+		location := strings.Join(record.Specs, ", ")
+		pkg, _ := record.compromisedPackage()
+		reportLockedIntegrity(pkg, record.Version, record.Integrity, filePath, location, addFinding, verbose)
+		reportYarnClassicRecord(record, filePath, location, graph, addFinding, verbose)
+	}
+}
+
+// yarnClassicGraph links Yarn classic blocks, keyed by their joined specs,
+// through the specs their dependencies request. yarn.lock does not record
+// the project's own dependencies, so they are read from the package.json
+// of the project and of each workspace package. The project also depends
+// on every block nothing else requests.
+func yarnClassicGraph(records []yarnLockRecord, dir string) *depGraph {
+	graph := newDepGraph()
+	graph.addRoot("", projectLabel(dir))
+
+	bySpec := make(map[string]string)
+	for _, record := range records {
+		id := strings.Join(record.Specs, ", ")
+		for _, spec := range record.Specs {
+			graph.addNode(id, yarnSpecName(spec))
+			bySpec[spec] = id
+		}
+	}
+
+	requested := make(map[string]bool)
+	for _, record := range records {
+		id := strings.Join(record.Specs, ", ")
+		for _, name := range sortedKeys(record.Dependencies) {
+			if to, ok := bySpec[name+"@"+record.Dependencies[name]]; ok {
+				graph.addEdge(id, to)
+				requested[to] = true
+			}
+		}
+	}
+	// Workspace packages are roots of their own
+	manifests := make(map[string]string) // root -> folder
+	manifests[""] = dir
+	if repo := cachedMonorepo(dir); repo != nil {
+		for _, pkg := range repo.Packages {
+			graph.addRoot("workspace:"+pkg.Dir, pkg.Name)
+			manifests["workspace:"+pkg.Dir] = pkg.Dir
+		}
+	}
+	for _, root := range sortedKeys(manifests) {
+		manifest, ok := readPackageManifest(manifests[root])
+		if !ok {
+			continue
+		}
+		deps := manifest.allDependencies()
+		for _, name := range sortedKeys(deps) {
+			if to, ok := bySpec[name+"@"+deps[name]]; ok {
+				graph.addEdge(root, to)
+				requested[to] = true
+			}
+		}
+	}
+	for _, record := range records {
+		if id := strings.Join(record.Specs, ", "); !requested[id] {
+			graph.addEdge("", id)
+		}
+	}
+	return graph
+}
+
+// reportYarnClassicRecord reports a Yarn classic block that resolved to a
+// compromised version, with its dependency chains when graph is not nil
+func reportYarnClassicRecord(record yarnLockRecord, filePath, location string, graph *depGraph, addFinding func(Finding), verbose bool) {
+	pkg, alias := record.compromisedPackage()
+	if pkg == nil || !pkg.matchesVersion(record.Version) {
+		return
+	}
+	addFinding(Finding{
+		Package:    pkg.Name,
+		Version:    record.Version,
+		Alias:      alias,
+		File:       filePath,
+		Location:   location,
+		Type:       "resolved",
+		IOC:        pkg,
+		Paths:      graph.paths(location, pkg.Name+"@"+record.Version),
+		Dependents: graph.dependents(location),
+	})
+	if verbose {
+		fmt.Printf("  Found resolved %s@%s (%s) in %s\n", pkg.Name, record.Version, location, filePath)
+	}
+}
+
+// compromisedPackage returns the IOC entry for the package a Yarn classic
+// record resolves, if any, and the alias it was installed under. Aliases are
+// requested as "colors-safe@npm:chalk@^5.0.0".
+func (record *yarnLockRecord) compromisedPackage() (*CompromisedPackage, string) {
+	for _, spec := range record.Specs {
+		name, alias := record.specPackage(spec)
+		if pkg := activeMatcher.lookup(name); pkg != nil {
+			return pkg, alias
+		}
+	}
+	return nil, ""
+}
+
+// specPackage returns the package one of a Yarn classic record's specs
+// installs, and the alias it is installed under if it is an npm: alias
+func (record *yarnLockRecord) specPackage(spec string) (string, string) {
+	name := yarnSpecName(spec)
+	realName, _, isAlias := parseNPMAlias(strings.TrimPrefix(spec, name+"@"))
+	if !isAlias {
+		realName, isAlias = registryTarballPackage(record.Resolved)
+		isAlias = isAlias && realName != name
+	}
+	if !isAlias {
+		return name, ""
+	}
+	return realName, name
+}
+
+// yarnSpecName returns the package name of a spec such as "debug@^4.1.0" or
+// "@ctrl/tinycolor@^4.0.0"
+func yarnSpecName(spec string) string {
+	if spec == "" {
+		return ""
+	}
+	at := strings.Index(spec[1:], "@") + 1
+	if at <= 0 {
+		return spec
+	}
+	return spec[:at]
+}
+
+// yarnTokenKind classifies the tokens of the Yarn classic lockfile grammar
+type yarnTokenKind int
+
+const (
+	yarnTokenString yarnTokenKind = iota
+	yarnTokenColon
+	yarnTokenComma
+	yarnTokenNewline
+	yarnTokenIndent
+)
+
+type yarnToken struct {
+	Kind   yarnTokenKind
+	Value  string // unquoted text of a string token
+	Indent int    // spaces of an indent token
+	Line   int
+}
+
+// tokenizeYarnLock splits a Yarn classic lockfile into strings, colons,
+// commas, newlines and the indentation that opens each line, the way yarn's
+// own lexer does. Comments are dropped.
+func tokenizeYarnLock(input string) ([]yarnToken, error) {
+	var tokens []yarnToken
+	line := 1
+	atLineStart := true
+
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == '\n':
+			tokens = append(tokens, yarnToken{Kind: yarnTokenNewline, Line: line})
+			line++
+			i++
+			atLineStart = true
+			continue
+		case c == '\r':
+			i++
+			continue
+		case c == ' ' && atLineStart:
+			start := i
+			for i < len(input) && input[i] == ' ' {
+				i++
+			}
+			tokens = append(tokens, yarnToken{Kind: yarnTokenIndent, Indent: i - start, Line: line})
+		case c == ' ' || c == '\t':
+			i++
+		case c == '#':
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+		case c == ':':
+			tokens = append(tokens, yarnToken{Kind: yarnTokenColon, Line: line})
+			i++
+		case c == ',':
+			tokens = append(tokens, yarnToken{Kind: yarnTokenComma, Line: line})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(input) && input[end] != '"' {
+				if input[end] == '\\' {
+					end++
+				}
+				if end < len(input) && input[end] == '\n' {
+					break
+				}
+				end++
+			}
+			if end >= len(input) || input[end] != '"' {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			value, err := strconv.Unquote(input[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string %s", line, input[i:end+1])
+			}
+			tokens = append(tokens, yarnToken{Kind: yarnTokenString, Value: value, Line: line})
+			i = end + 1
+		default:
+			// Unquoted strings run until a separator
+			end := i
+			for end < len(input) && !strings.ContainsRune(" \t\r\n:,", rune(input[end])) {
+				end++
+			}
+			tokens = append(tokens, yarnToken{Kind: yarnTokenString, Value: input[i:end], Line: line})
+			i = end
+		}
+		atLineStart = false
+	}
+	return tokens, nil
+}
+
+// parseYarnClassicLock reads the blocks of a Yarn v1 lockfile. A block is an
+// unindented, comma-separated list of specs ending in a colon, followed by
+// indented `field value` lines and nested maps such as dependencies.
+func parseYarnClassicLock(input string) ([]yarnLockRecord, error) {
+	tokens, err := tokenizeYarnLock(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []yarnLockRecord
+	var current *yarnLockRecord
+	section := "" // the nested map being read, such as "dependencies"
+	for start := 0; start < len(tokens); {
+		// Split the token stream into lines
+		end := start
+		for end < len(tokens) && tokens[end].Kind != yarnTokenNewline {
+			end++
+		}
+		lineTokens := tokens[start:end]
+		start = end + 1
+
+		indent := 0
+		if len(lineTokens) > 0 && lineTokens[0].Kind == yarnTokenIndent {
+			indent = lineTokens[0].Indent
+			lineTokens = lineTokens[1:]
+		}
+		if len(lineTokens) == 0 {
+			continue
+		}
+
+		switch {
+		case indent == 0:
+			// spec, spec, ...:
+			last := lineTokens[len(lineTokens)-1]
+			if last.Kind != yarnTokenColon {
+				return nil, fmt.Errorf("line %d: expected a colon after the package specs", last.Line)
+			}
+			var specs []string
+			for i, token := range lineTokens[:len(lineTokens)-1] {
+				want := yarnTokenString
+				if i%2 == 1 {
+					want = yarnTokenComma
+				}
+				if token.Kind != want {
+					return nil, fmt.Errorf("line %d: invalid package specs", token.Line)
+				}
+				if token.Kind == yarnTokenString {
+					specs = append(specs, token.Value)
+				}
+			}
+			records = append(records, yarnLockRecord{Specs: specs})
+			current = &records[len(records)-1]
+			section = ""
+
+		case current == nil:
+			return nil, fmt.Errorf("line %d: indented line outside a block", lineTokens[0].Line)
+
+		case indent <= 2:
+			// field "value", or field: opening a nested map
+			if len(lineTokens) != 2 || lineTokens[0].Kind != yarnTokenString {
+				return nil, fmt.Errorf("line %d: expected a field and its value", lineTokens[0].Line)
+			}
+			if lineTokens[1].Kind != yarnTokenString {
+				section = lineTokens[0].Value
+				continue
+			}
+			section = ""
+			switch value := lineTokens[1].Value; lineTokens[0].Value {
+			case "version":
+				current.Version = value
+			case "resolved":
+				current.Resolved = value
+			case "integrity":
+				current.Integrity = value
+			}
+
+		default:
+			// name "range" inside a nested map
+			if len(lineTokens) == 2 && lineTokens[0].Kind == yarnTokenString && lineTokens[1].Kind == yarnTokenString {
+				current.addDependency(section, lineTokens[0].Value, lineTokens[1].Value)
+			}
+		}
+	}
+	return records, nil
+}
+
+// isYarnBerryLock reports whether a yarn.lock was written by Yarn 2 or
+// later, which always starts with a __metadata block
+func isYarnBerryLock(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "__metadata:") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseYarnBerryLock reads the package blocks of a Yarn Berry lockfile, a
+// YAML document of `"spec, spec":` keys with indented `field: value` lines
+func parseYarnBerryLock(data []byte) []yarnLockRecord {
+	var records []yarnLockRecord
+	var current *yarnLockRecord
+	section := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Unindented keys start a new block
+		if !strings.HasPrefix(line, " ") {
+			current = nil
+			header := strings.TrimSuffix(trimmed, ":")
+			if header == "__metadata" {
+				continue
+			}
+			var specs []string
+			for _, spec := range strings.Split(unquoteYarnValue(header), ",") {
+				if spec = unquoteYarnValue(strings.TrimSpace(spec)); spec != "" {
+					specs = append(specs, spec)
+				}
+			}
+			records = append(records, yarnLockRecord{Specs: specs})
+			current = &records[len(records)-1]
+			section = ""
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		// Keys may be quoted and contain colons: "@scope/a": "npm:^1.0.0"
+		key, value := trimmed, ""
+		if strings.HasPrefix(trimmed, `"`) {
+			if end := strings.Index(trimmed[1:], `"`) + 1; end > 0 {
+				key, value = trimmed[:end+1], strings.TrimPrefix(trimmed[end+1:], ":")
+			}
+		} else if k, v, ok := strings.Cut(trimmed, ":"); ok {
+			key, value = k, v
+		}
+		key = unquoteYarnValue(key)
+		value = unquoteYarnValue(strings.TrimSpace(value))
+
+		// Lines of nested maps like dependencies
+		if strings.HasPrefix(line, "   ") {
+			current.addDependency(section, key, value)
+			continue
+		}
+		section = ""
+		if value == "" {
+			section = key
+			continue
+		}
+		switch key {
+		case "version":
+			current.Version = value
+		case "resolution":
+			current.Resolution = value
+		}
+	}
+	return records
+}
+
+// unquoteYarnValue strips the double quotes yarn puts around keys and
+// values that need them
+func unquoteYarnValue(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseBerryLocator splits a Berry locator such as "chalk@npm:5.6.1" or
+// "@ctrl/tinycolor@npm:4.1.1" into name, protocol and reference. A patch:
+// locator is unwrapped to the package it patches, since the patch is applied
+// on top of the original tarball.
+func parseBerryLocator(locator string) (name, protocol, reference string, ok bool) {
+	if locator == "" {
+		return "", "", "", false
+	}
+	// Skip the scope's own "@"
+	at := strings.Index(locator[1:], "@") + 1
+	if at <= 0 {
+		return "", "", "", false
+	}
+	name = locator[:at]
+	protocol, reference, ok = strings.Cut(locator[at+1:], ":")
+	if !ok {
+		return "", "", "", false
+	}
+
+	if protocol == "patch" {
+		// patch:chalk@npm%3A5.6.1#./patches/chalk.patch::version=5.6.1&hash=...
+		inner, _, _ := strings.Cut(reference, "#")
+		if unescaped, err := url.PathUnescape(inner); err == nil {
+			inner = unescaped
+		}
+		return parseBerryLocator(inner)
+	}
+	return name, protocol, reference, true
+}
+
+// berryAlias returns the name a Berry record was requested under when
+// that is an npm: alias of name, such as "colors-safe@npm:chalk@^5.0.0"
+func berryAlias(specs []string, name string) string {
+	for _, spec := range specs {
+		if alias := yarnSpecName(spec); alias != name {
+			return alias
+		}
+	}
+	return ""
+}
+
+// yarnBerryGraph links Berry records, keyed by resolution, through the
+// descriptors their dependencies request. Workspaces are the roots; the
+// project itself is the "workspace:." one.
+func yarnBerryGraph(records []yarnLockRecord) *depGraph {
+	graph := newDepGraph()
+	bySpec := make(map[string]string)
+	for _, record := range records {
+		name, protocol, _, ok := parseBerryLocator(record.Resolution)
+		if !ok {
+			continue
+		}
+		if protocol == "workspace" {
+			graph.addRoot(record.Resolution, name)
+		} else {
+			graph.addNode(record.Resolution, name)
+		}
+		for _, spec := range record.Specs {
+			bySpec[spec] = record.Resolution
+		}
+	}
+	for _, record := range records {
+		for _, name := range sortedKeys(record.Dependencies) {
+			rng := record.Dependencies[name]
+			to, ok := bySpec[name+"@"+rng]
+			if !ok {
+				// Ranges without a protocol default to npm:
+				to, ok = bySpec[name+"@npm:"+rng]
+			}
+			if ok {
+				graph.addEdge(record.Resolution, to)
+			}
+		}
+	}
+	return graph
+}
+
+// scanYarnBerryLock reports npm packages resolved to a compromised version,
+// including aliases and patched packages. A patched package has a record
+// for its patch: locator and one for the npm: package it patches; both are
+// the same install, so each name@version is reported once with the chains
+// through either.
+func scanYarnBerryLock(data []byte, filePath string, addFinding func(Finding), verbose bool) {
+	records := parseYarnBerryLock(data)
+	graph := yarnBerryGraph(records)
+
+	var findings []*Finding
+	resolutions := make(map[*Finding][]string)
+	byID := make(map[string]*Finding)
+	for _, record := range records {
+		name, protocol, reference, ok := parseBerryLocator(record.Resolution)
+		// Workspaces, links and git or file dependencies are not registry
+		// packages
+		if !ok || protocol != "npm" {
+			continue
+		}
+		pkg := activeMatcher.lookup(name)
+		if pkg == nil {
+			continue
+		}
+
+		// npm references may carry parameters: 5.6.1::__archiveUrl=...
+		version, _, _ := strings.Cut(reference, "::")
+		if version == "" {
+			version = record.Version
+		}
+		if !pkg.matchesVersion(version) {
+			continue
+		}
+		finding := byID[pkg.Name+"@"+version]
+		if finding == nil {
+			finding = &Finding{
+				Package:  pkg.Name,
+				Version:  version,
+				File:     filePath,
+				Location: record.Resolution,
+				Type:     "resolved",
+				IOC:      pkg,
+			}
+			byID[pkg.Name+"@"+version] = finding
+			findings = append(findings, finding)
+		}
+		if finding.Alias == "" {
+			finding.Alias = berryAlias(record.Specs, name)
+		}
+		resolutions[finding] = append(resolutions[finding], record.Resolution)
+	}
+
+	for _, finding := range findings {
+		ids := resolutions[finding]
+		finding.Paths = graph.pathsFrom(graph.isRoot, ids, finding.Package+"@"+finding.Version)
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			for _, label := range graph.dependents(id) {
+				if !seen[label] {
+					seen[label] = true
+					finding.Dependents = append(finding.Dependents, label)
+				}
+			}
+		}
+		sort.Strings(finding.Dependents)
+		addFinding(*finding)
+		if verbose {
+			fmt.Printf("  Found resolved %s@%s (%s) in %s\n", finding.Package, finding.Version, finding.Location, filePath)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// yarnTokensString renders tokens compactly: strings quoted, then ":", ",",
+// "\n" and ">N" for an indent of N spaces
+func yarnTokensString(tokens []yarnToken) string {
+	var parts []string
+	for _, token := range tokens {
+		switch token.Kind {
+		case yarnTokenString:
+			parts = append(parts, fmt.Sprintf("%q", token.Value))
+		case yarnTokenColon:
+			parts = append(parts, ":")
+		case yarnTokenComma:
+			parts = append(parts, ",")
+		case yarnTokenNewline:
+			parts = append(parts, `\n`)
+		case yarnTokenIndent:
+			parts = append(parts, fmt.Sprintf(">%d", token.Indent))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestTokenizeYarnLock(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`chalk@^5.0.0:`, `"chalk@^5.0.0" :`},
+		{`"debug@^4.1.0", "debug@^4.3.4":`, `"debug@^4.1.0" , "debug@^4.3.4" :`},
+		{`"@ctrl/tinycolor@^4.0.0":`, `"@ctrl/tinycolor@^4.0.0" :`},
+		{"  version \"5.6.1\"\n", `>2 "version" "5.6.1" \n`},
+		{"  dependencies:\n    ms \"^2.1.3\"", `>2 "dependencies" : \n >4 "ms" "^2.1.3"`},
+		{"# yarn lockfile v1\n\n", `\n \n`},
+		{"a:\r\n  b c\r\n", `"a" : \n >2 "b" "c" \n`},
+		{`  resolved "https://r.example/a/-/a-1.0.0.tgz#abc"`, `>2 "resolved" "https://r.example/a/-/a-1.0.0.tgz#abc"`},
+		{`"quote\"d":`, `"quote\"d" :`},
+	}
+	for _, tt := range tests {
+		tokens, err := tokenizeYarnLock(tt.in)
+		if err != nil {
+			t.Errorf("tokenizeYarnLock(%q): %v", tt.in, err)
+			continue
+		}
+		if got := yarnTokensString(tokens); got != tt.want {
+			t.Errorf("tokenizeYarnLock(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{`"chalk@^5.0.0:`, "\"a\nb\":", `"bad \q":`} {
+		if tokens, err := tokenizeYarnLock(in); err == nil {
+			t.Errorf("tokenizeYarnLock(%q) = %s, want an error", in, yarnTokensString(tokens))
+		}
+	}
+}
+
+const yarnClassicLock = `# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@ctrl/tinycolor@^4.0.0":
+  version "4.1.1"
+  resolved "https://registry.yarnpkg.com/@ctrl/tinycolor/-/tinycolor-4.1.1.tgz#0a1b2c"
+  integrity sha512-AAAA
+
+chalk@^5.0.0, chalk@^5.6.0:
+  version "5.6.1"
+  resolved "https://registry.yarnpkg.com/chalk/-/chalk-5.6.1.tgz"
+
+"colors-safe@npm:chalk@^5.0.0":
+  version "5.6.1"
+  resolved "https://registry.yarnpkg.com/chalk/-/chalk-5.6.1.tgz"
+
+"debug@^4.1.0", "debug@^4.3.4":
+  version "4.4.2"
+  resolved "https://registry.yarnpkg.com/debug/-/debug-4.4.2.tgz"
+  dependencies:
+    ms "^2.1.3"
+  optionalDependencies:
+    supports-color "^8.1.1"
+  peerDependenciesMeta:
+    supports-color:
+      optional true
+
+ms@^2.1.3:
+  version "2.1.3"
+  resolved "https://registry.yarnpkg.com/ms/-/ms-2.1.3.tgz"
+
+old-chalk@^1.0.0:
+  version "1.0.0"
+  resolved "https://registry.yarnpkg.com/chalk/-/chalk-5.6.1.tgz"
+`
+
+func TestParseYarnClassicLock(t *testing.T) {
+	records, err := parseYarnClassicLock(yarnClassicLock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, record := range records {
+		s := fmt.Sprintf("%s => %s", strings.Join(record.Specs, " | "), record.Version)
+		if record.Integrity != "" {
+			s += " " + record.Integrity
+		}
+		for _, name := range sortedKeys(record.Dependencies) {
+			s += fmt.Sprintf(" +%s@%s", name, record.Dependencies[name])
+		}
+		got = append(got, s)
+	}
+	// Nested maps such as dependencies don't leak into the block's fields;
+	// dependencies and optional dependencies are kept, peer metadata isn't
+	want := []string{
+		"@ctrl/tinycolor@^4.0.0 => 4.1.1 sha512-AAAA",
+		"chalk@^5.0.0 | chalk@^5.6.0 => 5.6.1",
+		"colors-safe@npm:chalk@^5.0.0 => 5.6.1",
+		"debug@^4.1.0 | debug@^4.3.4 => 4.4.2 +ms@^2.1.3 +supports-color@^8.1.1",
+		"ms@^2.1.3 => 2.1.3",
+		"old-chalk@^1.0.0 => 1.0.0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestParseYarnClassicLockErrors(t *testing.T) {
+	for _, lock := range []string{
+		"chalk@^5.0.0\n  version \"5.6.1\"\n",
+		"  version \"5.6.1\"\n",
+		"chalk@^5.0.0 debug@^4.0.0:\n",
+		"chalk@^5.0.0,,debug@^4.0.0:\n",
+		"chalk@^5.0.0:\n  version \"5.6.1\" extra\n",
+		"<<<<<<< HEAD\nchalk@^5.0.0:\n",
+	} {
+		if records, err := parseYarnClassicLock(lock); err == nil {
+			t.Errorf("parseYarnClassicLock(%q) = %v, want an error", lock, records)
+		}
+	}
+}
+
+func TestScanYarnClassicLock(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+	)
+	dir := t.TempDir()
+	scan := func(lock string) []string {
+		path := filepath.Join(dir, "yarn.lock")
+		if err := os.WriteFile(path, []byte(lock), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		var got []string
+		scanYarnLock(file, path, func(f Finding) {
+			s := fmt.Sprintf("%s@%s (%s)", f.Package, f.Version, f.Location)
+			if f.Alias != "" {
+				s += " as " + f.Alias
+			}
+			got = append(got, s)
+		}, false)
+		return got
+	}
+
+	// Each block is judged once by the package it resolved to and that
+	// version: old-chalk's chalk tarball is an older release, and the debug
+	// ranges listed under dependencies are not installs
+	want := []string{
+		"chalk@5.6.1 (chalk@^5.0.0, chalk@^5.6.0)",
+		"chalk@5.6.1 (colors-safe@npm:chalk@^5.0.0) as colors-safe",
+		"debug@4.4.2 (debug@^4.1.0, debug@^4.3.4)",
+	}
+	if got := scan(yarnClassicLock); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A conflicted lockfile still gets a text scan
+	conflicted := "<<<<<<< HEAD\nchalk@^5.0.0:\n  version \"5.3.0\"\n=======\nchalk@^5.0.0:\n  version \"5.6.1\"\n  resolved \"https://registry.yarnpkg.com/chalk/-/chalk-5.6.1.tgz\"\n>>>>>>> main\n"
+	if got := scan(conflicted); len(got) != 1 || !strings.HasPrefix(got[0], "chalk@5.6.1 ") {
+		t.Errorf("conflicted lockfile: got %q, want chalk@5.6.1", got)
+	}
+}
+
+func TestYarnSpecName(t *testing.T) {
+	tests := []struct {
+		spec, want string
+	}{
+		{"chalk@^5.0.0", "chalk"},
+		{"@ctrl/tinycolor@^4.0.0", "@ctrl/tinycolor"},
+		{"@acme/web@workspace:packages/web", "@acme/web"},
+		{"chalk", "chalk"},
+		{"@ctrl/tinycolor", "@ctrl/tinycolor"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := yarnSpecName(tt.spec); got != tt.want {
+			t.Errorf("yarnSpecName(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestIsYarnBerryLock(t *testing.T) {
+	if !isYarnBerryLock([]byte("# This file is generated by running \"yarn install\"\n\n__metadata:\n  version: 8\n")) {
+		t.Error("Berry lockfile not detected")
+	}
+	if isYarnBerryLock([]byte(yarnClassicLock)) {
+		t.Error("classic lockfile detected as Berry")
+	}
+}
+
+func TestParseBerryLocator(t *testing.T) {
+	tests := []struct {
+		locator                   string
+		name, protocol, reference string
+		ok                        bool
+	}{
+		{"chalk@npm:5.6.1", "chalk", "npm", "5.6.1", true},
+		{"@ctrl/tinycolor@npm:4.1.1", "@ctrl/tinycolor", "npm", "4.1.1", true},
+		{"chalk@npm:5.6.1::__archiveUrl=https%3A%2F%2Fr.example%2Fchalk.tgz", "chalk", "npm", "5.6.1::__archiveUrl=https%3A%2F%2Fr.example%2Fchalk.tgz", true},
+		{"app@workspace:.", "app", "workspace", ".", true},
+		{"chalk@patch:chalk@npm%3A5.6.1#~/.yarn/patches/chalk.patch::version=5.6.1&hash=abc123", "chalk", "npm", "5.6.1", true},
+		{"@ctrl/tinycolor@patch:@ctrl/tinycolor@npm%3A4.1.1#./tinycolor.patch", "@ctrl/tinycolor", "npm", "4.1.1", true},
+		{"chalk", "", "", "", false},
+		{"chalk@5.6.1", "", "", "", false},
+		{"", "", "", "", false},
+	}
+	for _, tt := range tests {
+		name, protocol, reference, ok := parseBerryLocator(tt.locator)
+		if name != tt.name || protocol != tt.protocol || reference != tt.reference || ok != tt.ok {
+			t.Errorf("parseBerryLocator(%q) = %q, %q, %q, %v, want %q, %q, %q, %v",
+				tt.locator, name, protocol, reference, ok, tt.name, tt.protocol, tt.reference, tt.ok)
+		}
+	}
+}
+
+// yarnBerryLock patches chalk, so it has both the patch: record the
+// workspaces depend on and the npm: record it patches
+const yarnBerryLock = `# This file is generated by running "yarn install" inside your project.
+# Manual changes might be lost - proceed with caution!
+
+__metadata:
+  version: 8
+  cacheKey: 10c0
+
+"@acme/web@workspace:packages/web":
+  version: 0.0.0-use.local
+  resolution: "@acme/web@workspace:packages/web"
+  dependencies:
+    chalk: "npm:^5.0.0"
+  languageName: unknown
+  linkType: soft
+
+"@ctrl/tinycolor@npm:^4.0.0":
+  version: 4.1.1
+  resolution: "@ctrl/tinycolor@npm:4.1.1::__archiveUrl=https%3A%2F%2Fnpm.example.com%2Ftinycolor-4.1.1.tgz"
+  checksum: 10c0/aaaa
+  languageName: node
+  linkType: hard
+
+"app@workspace:.":
+  version: 0.0.0-use.local
+  resolution: "app@workspace:."
+  dependencies:
+    "@ctrl/tinycolor": "npm:^4.0.0"
+    chalk: "patch:chalk@npm%3A^5.0.0#~/.yarn/patches/chalk.patch"
+    my-debug: "npm:debug@^4.0.0"
+  languageName: unknown
+  linkType: soft
+
+"chalk@npm:^5.0.0":
+  version: 5.6.1
+  resolution: "chalk@npm:5.6.1"
+  dependencies:
+    debug: "npm:^4.1.0"
+  checksum: 10c0/bbbb
+  languageName: node
+  linkType: hard
+
+"chalk@patch:chalk@npm%3A^5.0.0#~/.yarn/patches/chalk.patch":
+  version: 5.6.1
+  resolution: "chalk@patch:chalk@npm%3A5.6.1#~/.yarn/patches/chalk.patch::version=5.6.1&hash=abc123"
+  dependencies:
+    debug: "npm:^4.1.0"
+  checksum: 10c0/cccc
+  languageName: node
+  linkType: hard
+
+"debug@npm:^4.1.0, my-debug@npm:debug@^4.0.0":
+  version: 4.4.2
+  resolution: "debug@npm:4.4.2"
+  checksum: 10c0/dddd
+  languageName: node
+  linkType: hard
+
+"fork@https://git.example.com/chalk.tgz":
+  version: 5.6.1
+  resolution: "fork@https://git.example.com/chalk.tgz#abc"
+  languageName: node
+  linkType: hard
+`
+
+func TestParseYarnBerryLock(t *testing.T) {
+	var got []string
+	for _, record := range parseYarnBerryLock([]byte(yarnBerryLock)) {
+		got = append(got, fmt.Sprintf("%s => %s %s", strings.Join(record.Specs, " | "), record.Resolution, record.Version))
+	}
+	want := []string{
+		"@acme/web@workspace:packages/web => @acme/web@workspace:packages/web 0.0.0-use.local",
+		"@ctrl/tinycolor@npm:^4.0.0 => @ctrl/tinycolor@npm:4.1.1::__archiveUrl=https%3A%2F%2Fnpm.example.com%2Ftinycolor-4.1.1.tgz 4.1.1",
+		"app@workspace:. => app@workspace:. 0.0.0-use.local",
+		"chalk@npm:^5.0.0 => chalk@npm:5.6.1 5.6.1",
+		"chalk@patch:chalk@npm%3A^5.0.0#~/.yarn/patches/chalk.patch => chalk@patch:chalk@npm%3A5.6.1#~/.yarn/patches/chalk.patch::version=5.6.1&hash=abc123 5.6.1",
+		"debug@npm:^4.1.0 | my-debug@npm:debug@^4.0.0 => debug@npm:4.4.2 4.4.2",
+		"fork@https://git.example.com/chalk.tgz => fork@https://git.example.com/chalk.tgz#abc 5.6.1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestScanYarnBerryLock(t *testing.T) {
+	setTestIOCs(t,
+		CompromisedPackage{Name: "chalk", Versions: []string{"5.6.1"}},
+		CompromisedPackage{Name: "debug", Versions: []string{"4.4.2"}},
+		CompromisedPackage{Name: "@ctrl/tinycolor", Versions: []string{"4.1.1"}},
+	)
+	var got []string
+	scanYarnBerryLock([]byte(yarnBerryLock), "yarn.lock", func(f Finding) {
+		got = append(got, fmt.Sprintf("%s %s@%s %q", f.Location, f.Package, f.Version, f.Paths))
+	}, false)
+	sort.Strings(got)
+
+	// Hits are keyed by resolution: archive parameters don't hide the
+	// version, and the git fork is not a registry package even though its
+	// version matches. The patched chalk and the chalk it patches are one
+	// install, reported once with the chains through either.
+	want := []string{
+		`@ctrl/tinycolor@npm:4.1.1::__archiveUrl=https%3A%2F%2Fnpm.example.com%2Ftinycolor-4.1.1.tgz @ctrl/tinycolor@4.1.1 ["app > @ctrl/tinycolor@4.1.1"]`,
+		`chalk@npm:5.6.1 chalk@5.6.1 ["@acme/web > chalk@5.6.1" "app > chalk@5.6.1"]`,
+		`debug@npm:4.4.2 debug@4.4.2 ["app > debug@4.4.2" "@acme/web > chalk > debug@4.4.2" "app > chalk > debug@4.4.2"]`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}